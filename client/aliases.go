@@ -0,0 +1,191 @@
+package client
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+)
+
+const (
+	maxAliasDepth    = 8
+	defaultSeparator = ";"
+)
+
+var aliasArgPattern = regexp.MustCompile("\\$(\\d+|\\*)")
+
+type alias struct {
+	name      string
+	expansion string
+	fn        otto.Value
+	owner     string
+}
+
+func (self *alias) String() string {
+	if self.fn.IsFunction() {
+		return "<function>"
+	}
+	return self.expansion
+}
+
+// splitCommands splits line at sep, where a doubled sep stands for itself, dropping blank commands.
+func splitCommands(line, sep string) (result []string) {
+	if sep == "" || !strings.Contains(line, sep) {
+		return []string{line}
+	}
+	current := ""
+	for i := strings.Index(line, sep); i != -1; i = strings.Index(line, sep) {
+		if strings.HasPrefix(line[i+len(sep):], sep) {
+			current += line[:i+len(sep)]
+			line = line[i+2*len(sep):]
+			continue
+		}
+		if current += line[:i]; strings.TrimSpace(current) != "" {
+			result = append(result, current)
+		}
+		current, line = "", line[i+len(sep):]
+	}
+	if current += line; strings.TrimSpace(current) != "" {
+		result = append(result, current)
+	}
+	return
+}
+
+// command sends each of the separated commands in line, expanding speedwalks and aliases.
+func (self *Client) command(line string) (err error) {
+	for _, cmd := range splitCommands(line, self.separator) {
+		if steps, ok, e := parseSpeedwalk(cmd, self.bareSpeedwalk); e != nil {
+			return e
+		} else if ok {
+			err = self.speedwalk(steps)
+		} else {
+			err = self.expandCommand(cmd)
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// expandCommand sends line to the server after expanding any alias named by its first word.
+// An alias is not expanded again within its own expansion.
+func (self *Client) expandCommand(line string) (err error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return self.sendln(line)
+	}
+	a, found := self.aliases[fields[0]]
+	if !found || self.expanding[a.name] {
+		return self.sendln(line)
+	}
+	if len(self.expanding) >= maxAliasDepth {
+		return fmt.Errorf("Alias expansion of %#v deeper than %v levels", line, maxAliasDepth)
+	}
+	self.expanding[a.name] = true
+	defer delete(self.expanding, a.name)
+	if self.origin == originUser {
+		self.origin = originAlias
+		defer func() {
+			self.origin = originUser
+		}()
+	}
+	args := fields[1:]
+	rest := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), fields[0]))
+	if a.fn.IsFunction() {
+		argv := make([]interface{}, len(args))
+		for i, arg := range args {
+			argv[i] = arg
+		}
+		arr, _ := self.ot.ToValue(argv)
+		result, e := self.callScript(a.fn, arr, rest)
+		if e != nil {
+			self.reportError(fmt.Sprintf("alias %#v%v", a.name, registeredIn(a.owner)), e)
+			return reportedError{e}
+		}
+		if result.IsString() {
+			return self.command(result.String())
+		}
+		return
+	}
+	expanded := aliasArgPattern.ReplaceAllStringFunc(a.expansion, func(match string) string {
+		if match == "$*" {
+			return rest
+		}
+		if n, _ := strconv.Atoi(match[1:]); n > 0 && n <= len(args) {
+			return args[n-1]
+		}
+		return ""
+	})
+	return self.command(expanded)
+}
+
+// scriptCommand sends a line on behalf of a script, expanding aliases only if enabled.
+func (self *Client) scriptCommand(line string) error {
+	self.lock.RLock()
+	aliasSends := self.aliasSends
+	self.lock.RUnlock()
+	if aliasSends {
+		return self.command(line)
+	}
+	return self.sendln(line)
+}
+
+func (self *Client) bindAliases() {
+	self.bind("alias(name, expansion)", "Expand commands starting with name, either with a string where $1, $2... and $* are replaced with arguments, or by calling a function with the argument array.", func(call otto.FunctionCall) (result otto.Value) {
+		name := call.Argument(0).String()
+		if name == "" || strings.ContainsAny(name, " \t") {
+			result, _ = otto.ToValue(fmt.Errorf("Invalid alias name %#v", name))
+			return
+		}
+		a := &alias{name: name, owner: self.currentOwner()}
+		if call.Argument(1).IsFunction() {
+			a.fn = call.Argument(1)
+		} else {
+			a.expansion = call.Argument(1).String()
+		}
+		self.aliases[name] = a
+		return
+	})
+	self.bind("unalias(name)", "Remove an alias.", func(call otto.FunctionCall) (result otto.Value) {
+		name := call.Argument(0).String()
+		_, found := self.aliases[name]
+		delete(self.aliases, name)
+		result, _ = otto.ToValue(found)
+		return
+	})
+	self.bind("aliases()", "List aliases.", func(call otto.FunctionCall) (result otto.Value) {
+		names := make([]string, 0, len(self.aliases))
+		for name := range self.aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		items := []map[string]interface{}{}
+		for _, name := range names {
+			items = append(items, map[string]interface{}{
+				"name":      name,
+				"expansion": self.aliases[name].String(),
+			})
+		}
+		return self.jsArray(items)
+	})
+	self.bind("aliasSends(enabled)", "Get or set whether lines sent by scripts with sendln() are alias expanded.", func(call otto.FunctionCall) (result otto.Value) {
+		self.lock.Lock()
+		defer self.lock.Unlock()
+		if arg := call.Argument(0); arg.IsDefined() {
+			self.aliasSends, _ = arg.ToBoolean()
+		}
+		result, _ = otto.ToValue(self.aliasSends)
+		return
+	})
+	self.bind("separator(text)", "Get or set what separates commands typed on one line, e.g. \"n;n;e\". A doubled separator sends it literally, and separator(\"\") turns splitting off.", func(call otto.FunctionCall) (result otto.Value) {
+		if arg := call.Argument(0); arg.IsDefined() {
+			self.separator = arg.String()
+		}
+		result, _ = otto.ToValue(self.separator)
+		return
+	})
+}
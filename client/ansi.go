@@ -0,0 +1,86 @@
+package client
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	ansiReset   = "\033[0m"
+	ansiReverse = "\033[7m"
+)
+
+var ansiPattern = regexp.MustCompile("\033\\[[0-9;?]*[A-Za-z]")
+
+func stripANSI(s string) string {
+	if !strings.Contains(s, "\033") {
+		return s
+	}
+	return ansiPattern.ReplaceAllString(s, "")
+}
+
+var ansiAttributes = map[string]int{
+	"bold":      1,
+	"dim":       2,
+	"underline": 4,
+	"reverse":   7,
+	"black":     30,
+	"red":       31,
+	"green":     32,
+	"yellow":    33,
+	"blue":      34,
+	"magenta":   35,
+	"cyan":      36,
+	"white":     37,
+}
+
+// parseColor turns specs like "red" or "yellow,bold" into an SGR escape sequence.
+func parseColor(spec string) (result string, err error) {
+	codes := []string{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+		code, found := ansiAttributes[part]
+		if !found {
+			err = fmt.Errorf("Unknown color %#v", part)
+			return
+		}
+		codes = append(codes, fmt.Sprint(code))
+	}
+	if len(codes) > 0 {
+		result = "\033[" + strings.Join(codes, ";") + "m"
+	}
+	return
+}
+
+var markupPattern = regexp.MustCompile("\\{([a-zA-Z, ]+)\\}")
+
+// expandMarkup replaces color markup like {red} or {yellow,bold} with escape sequences, and {reset} with a reset. Unknown colors are left alone.
+func expandMarkup(s string) string {
+	if !strings.Contains(s, "{") {
+		return s
+	}
+	return markupPattern.ReplaceAllStringFunc(s, func(match string) string {
+		spec := match[1 : len(match)-1]
+		if strings.TrimSpace(spec) == "reset" {
+			return ansiReset
+		}
+		if sgr, err := parseColor(spec); err == nil {
+			return sgr
+		}
+		return match
+	})
+}
+
+func colorize(text, spec string) (result string, err error) {
+	sgr, err := parseColor(spec)
+	if err != nil || sgr == "" {
+		result = text
+		return
+	}
+	result = sgr + text + ansiReset
+	return
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+// armAntiIdle restarts the anti-idle timer of the session, or stops it if anti-idle is off or the session is not connected.
+func (self *session) armAntiIdle() {
+	connected := self.getConn() != nil
+	self.client.lock.Lock()
+	defer self.client.lock.Unlock()
+	if self.idleTimer != nil {
+		self.idleTimer.Stop()
+		self.idleTimer = nil
+	}
+	if interval := self.client.antiIdleInterval; interval > 0 && connected {
+		self.idleTimer = time.AfterFunc(interval, self.antiIdle)
+	}
+}
+
+func (self *session) antiIdle() {
+	if self.getConn() == nil {
+		return
+	}
+	self.client.lock.RLock()
+	command, echo := self.client.antiIdleCommand, self.client.config.localEcho
+	self.client.lock.RUnlock()
+	if echo {
+		self.outputf("%v\n", self.client.themed(themeScriptEcho, "(anti-idle) "+command))
+	}
+	if err := self.write(command+"\n", originClient); err != nil {
+		self.outputErrorf("Anti-idle failed: %v\n", err)
+	}
+	self.armAntiIdle()
+}
+
+func (self *Client) bindAntiIdle() {
+	self.bind("antiidle(seconds, command)", "Send command, by default an empty line, whenever nothing typed has been sent for seconds. antiidle(0) turns it off.", func(call otto.FunctionCall) (result otto.Value) {
+		self.lock.Lock()
+		if arg := call.Argument(0); arg.IsDefined() {
+			seconds, err := arg.ToFloat()
+			if err != nil || seconds < 0 {
+				self.lock.Unlock()
+				result, _ = otto.ToValue(fmt.Errorf("Invalid interval %#v", arg.String()))
+				return
+			}
+			self.antiIdleInterval = time.Duration(seconds * float64(time.Second))
+			self.antiIdleCommand = ""
+			if arg := call.Argument(1); arg.IsDefined() {
+				self.antiIdleCommand = arg.String()
+			}
+		}
+		interval, command := self.antiIdleInterval, self.antiIdleCommand
+		sessions := self.sessions
+		self.lock.Unlock()
+		for _, sess := range sessions {
+			sess.armAntiIdle()
+		}
+		if interval == 0 {
+			result, _ = otto.ToValue("Anti-idle disabled")
+		} else {
+			result, _ = otto.ToValue(fmt.Sprintf("Sending %#v after %v idle", command, interval))
+		}
+		return
+	})
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"github.com/zond/gocui"
+)
+
+// question is something asked in the input view instead of sending the next line entered.
+type question struct {
+	title  string
+	secret bool
+	answer func(string)
+}
+
+// ask shows title over the input view and passes the next line entered to answer in script context. With secret the input is masked
+// like a password.
+func (self *Client) ask(title string, secret bool, answer func(string)) {
+	self.question = &question{
+		title:  title,
+		secret: secret,
+		answer: answer,
+	}
+	self.redraw()
+}
+
+func (self *Client) secretQuestion() bool {
+	return self.question != nil && self.question.secret
+}
+
+func (self *Client) answerQuestion(v *gocui.View, line string) {
+	q := self.question
+	self.question = nil
+	v.Title = ""
+	self.schedule(func() {
+		q.answer(line)
+	})
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+// expandLogPattern replaces %h with host and %Y, %m and %d with the date of at.
+func expandLogPattern(pattern, host string, at time.Time) string {
+	return strings.NewReplacer(
+		"%h", strings.Replace(host, string(filepath.Separator), "_", -1),
+		"%Y", at.Format("2006"),
+		"%m", at.Format("01"),
+		"%d", at.Format("02"),
+		"%%", "%",
+	).Replace(pattern)
+}
+
+// rotateAutolog switches the session to the log file the autolog pattern currently names, unless it is logging manually. It is
+// called when connecting and before each received line, so files only change at line boundaries.
+func (self *session) rotateAutolog(connecting bool) {
+	self.logLock.Lock()
+	defer self.logLock.Unlock()
+	self.client.lock.RLock()
+	pattern, opts, host := self.client.autologPattern, self.client.autologOptions, self.host
+	l, current := self.logger, self.autologPath
+	self.client.lock.RUnlock()
+	if pattern == "" || host == "" || (l != nil && current == "") {
+		return
+	}
+	now := time.Now()
+	path := expandLogPattern(pattern, host, now)
+	separator := fmt.Sprintf("--- reconnected at %v ---", now.Format("15:04"))
+	if l != nil && path == current {
+		if connecting {
+			l.line(separator, true)
+		}
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		self.outputErrorf("Unable to create log directory for %#v: %v\n", path, err)
+		return
+	}
+	info, statErr := os.Stat(path)
+	if err := self.startLog(path, opts); err != nil {
+		self.outputErrorf("Unable to log to %#v: %v\n", path, err)
+		return
+	}
+	self.client.lock.Lock()
+	self.autologPath = path
+	l = self.logger
+	self.client.lock.Unlock()
+	if connecting && statErr == nil && info.Size() > 0 {
+		l.line(separator, true)
+	}
+}
+
+func (self *Client) bindAutolog() {
+	self.bind("autolog(pattern, options)", "Log every session to the file pattern names, where %h is the host and %Y, %m and %d the date, rotating at midnight and when connecting elsewhere. autolog(false) turns it off. Options as for log().", func(call otto.FunctionCall) (result otto.Value) {
+		arg := call.Argument(0)
+		if !arg.IsDefined() {
+			self.lock.RLock()
+			defer self.lock.RUnlock()
+			if self.autologPattern == "" {
+				result, _ = otto.ToValue("Not logging automatically")
+			} else {
+				result, _ = otto.ToValue(fmt.Sprintf("Logging automatically to %#v", self.autologPattern))
+			}
+			return
+		}
+		pattern := ""
+		var opts logOptions
+		if b, _ := arg.ToBoolean(); !arg.IsNull() && !(arg.IsBoolean() && !b) {
+			var err error
+			if opts, err = parseLogOptions(call.Argument(1)); err != nil {
+				result, _ = otto.ToValue(err)
+				return
+			}
+			pattern = self.resolvePath(arg.String())
+		}
+		self.lock.Lock()
+		self.autologPattern, self.autologOptions = pattern, opts
+		sessions := self.sessions
+		self.lock.Unlock()
+		for _, sess := range sessions {
+			self.lock.RLock()
+			auto := sess.autologPath != ""
+			self.lock.RUnlock()
+			if auto {
+				sess.stopLog()
+			}
+			sess.rotateAutolog(false)
+		}
+		if pattern == "" {
+			result, _ = otto.ToValue("Automatic logging stopped")
+		} else {
+			result, _ = otto.ToValue(fmt.Sprintf("Logging automatically to %#v", pattern))
+		}
+		return
+	})
+	self.bind("logfile()", "Return the path the current session is logged to, if any.", func(call otto.FunctionCall) (result otto.Value) {
+		if l := self.target().getLogger(); l != nil {
+			result, _ = otto.ToValue(l.path)
+		}
+		return
+	})
+}
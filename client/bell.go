@@ -0,0 +1,76 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+const (
+	bellVisual  = "visual"
+	bellAudible = "audible"
+	bellBoth    = "both"
+	bellOff     = "off"
+	// bellFlash is how long the visual bell colors the status line, and bellInterval how often a bell flood may ring.
+	bellFlash    = 300 * time.Millisecond
+	bellInterval = time.Second
+)
+
+// takeBells removes BEL characters from data and returns whether there were any.
+func takeBells(data []byte) ([]byte, bool) {
+	if bytes.IndexByte(data, '\a') == -1 {
+		return data, false
+	}
+	return bytes.Replace(data, []byte{'\a'}, nil, -1), true
+}
+
+// ring rings the bell the configured way and fires the bell hook, at most once every bellInterval.
+func (self *session) ring() {
+	self.client.lock.Lock()
+	if self.client.active != self || self.scroll > 0 {
+		self.urgent = true
+	}
+	now := time.Now()
+	if now.Sub(self.client.bellAt) < bellInterval {
+		self.client.lock.Unlock()
+		return
+	}
+	self.client.bellAt = now
+	mode := self.client.bellMode
+	self.client.lock.Unlock()
+	if mode == bellAudible || mode == bellBoth {
+		fmt.Fprint(self.client.gui, "\a")
+	}
+	if mode == bellVisual || mode == bellBoth {
+		self.client.redraw()
+		time.AfterFunc(bellFlash, self.client.redraw)
+	}
+	self.scheduleHook("bell")
+}
+
+// flashing tells whether the visual bell is showing.
+func (self *Client) flashing() bool {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return (self.bellMode == bellVisual || self.bellMode == bellBoth) && time.Now().Sub(self.bellAt) < bellFlash
+}
+
+func (self *Client) bindBell() {
+	self.bind("bell(mode)", "Get or set what a bell from the server does: \"visual\" flashes the status line, \"audible\" rings the terminal bell, \"both\" or \"off\". on(\"bell\", fn) handlers run either way.", func(call otto.FunctionCall) (result otto.Value) {
+		self.lock.Lock()
+		defer self.lock.Unlock()
+		if arg := call.Argument(0); arg.IsDefined() {
+			switch mode := arg.String(); mode {
+			case bellVisual, bellAudible, bellBoth, bellOff:
+				self.bellMode = mode
+			default:
+				result, _ = otto.ToValue(fmt.Errorf("Invalid bell mode %#v", mode))
+				return
+			}
+		}
+		result, _ = otto.ToValue(self.bellMode)
+		return
+	})
+}
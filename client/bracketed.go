@@ -0,0 +1,99 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zond/gocui"
+)
+
+const (
+	bracketedPasteOn  = "\033[?2004h"
+	bracketedPasteOff = "\033[?2004l"
+	pasteStartMarker  = "200~"
+	pasteEndMarker    = "201~"
+)
+
+// bracketedPaste tracks a paste the terminal wraps in ESC[200~ and ESC[201~. With Alt keys enabled the ESC[ of each marker is
+// reported as Alt-[, and the rest of the marker arrives as ordinary runes in the input view, so that is where it is stripped.
+type bracketedPaste struct {
+	active bool
+	ending bool
+	before string
+	lines  []string
+}
+
+func (self *Client) enableBracketedPaste() {
+	fmt.Fprint(self.gui, bracketedPasteOn)
+}
+
+func (self *Client) disableBracketedPaste() {
+	fmt.Fprint(self.gui, bracketedPasteOff)
+}
+
+// filterPaste drops control characters, turning tabs into spaces.
+func filterPaste(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return ' '
+		}
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// pasteMarker starts or ends a bracketed paste, setting aside what was already typed.
+func (self *Client) pasteMarker(g *gocui.Gui, v *gocui.View) error {
+	input := g.View("input")
+	if input == nil || self.masked {
+		return nil
+	}
+	if !self.bracketed.active {
+		self.bracketed = bracketedPaste{
+			active: true,
+			before: strings.Join(inputLines(input), ""),
+		}
+		input.Clear()
+		input.SetCursor(0, 0)
+		return nil
+	}
+	self.collectPaste(input)
+	self.bracketed.active, self.bracketed.ending = false, true
+	return nil
+}
+
+// collectPaste moves what has been pasted into the input view so far into the paste, so that pasted newlines never submit anything.
+func (self *Client) collectPaste(v *gocui.View) {
+	self.bracketed.lines = append(self.bracketed.lines, strings.Join(inputLines(v), ""))
+	v.Clear()
+	v.SetCursor(0, 0)
+}
+
+// finishPaste waits for the tail of the end marker, then puts a single pasted line in the input view and hands several to paste.
+func (self *Client) finishPaste(v *gocui.View) {
+	if !self.bracketed.ending {
+		return
+	}
+	typed := strings.Join(inputLines(v), "")
+	if len(typed) < len(pasteEndMarker) && strings.HasPrefix(pasteEndMarker, typed) {
+		return
+	}
+	lines, before := self.bracketed.lines, self.bracketed.before
+	self.bracketed = bracketedPaste{}
+	lines[0] = strings.TrimPrefix(lines[0], pasteStartMarker)
+	for i := range lines {
+		lines[i] = filterPaste(lines[i])
+	}
+	lines[0] = before + lines[0]
+	lines[len(lines)-1] += strings.TrimPrefix(typed, pasteEndMarker)
+	v.Clear()
+	v.SetCursor(0, 0)
+	if len(lines) == 1 {
+		fmt.Fprint(v, lines[0])
+		v.SetCursor(stringWidth(lines[0]), 0)
+		return
+	}
+	self.paste(v, lines)
+}
@@ -0,0 +1,160 @@
+package client
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+	"github.com/zond/gocui"
+)
+
+const (
+	defaultCaptureHeight = 5
+	// minOutputRows is how many rows the main output keeps however many capture windows are open.
+	minOutputRows = 5
+)
+
+// captureWindow is a view stacked above the output that received lines matching pattern are moved, or with copy copied, to.
+type captureWindow struct {
+	name    string
+	pattern *regexp.Regexp
+	copy    bool
+	height  int
+	lines   []string
+	owner   string
+}
+
+func (self *captureWindow) viewName() string {
+	return "capture-" + self.name
+}
+
+// capture adds text to the first capture window matching line and returns whether it should still be shown in the main output.
+func (self *Client) capture(line, text string) (show bool) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for _, w := range self.captures {
+		if w.pattern.MatchString(line) {
+			w.lines = append(w.lines, text)
+			if over := len(w.lines) - self.config.scrollback; over > 0 {
+				w.lines = append([]string{}, w.lines[over:]...)
+			}
+			return w.copy
+		}
+	}
+	return true
+}
+
+func (self *Client) removeCapture(name string) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for i, w := range self.captures {
+		if w.name == name {
+			self.captures = append(self.captures[:i:i], self.captures[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// layoutCaptures stacks the capture windows that fit from the top of the screen down, deletes the views of closed ones, and returns
+// the row the main output starts at.
+func (self *Client) layoutCaptures(g *gocui.Gui, maxX, outputBottom int) (top int, err error) {
+	self.lock.RLock()
+	captures := append([]*captureWindow{}, self.captures...)
+	self.lock.RUnlock()
+	shown := map[string]bool{}
+	for _, w := range captures {
+		bottom := top + w.height + 1
+		if outputBottom-bottom-1 < minOutputRows {
+			break
+		}
+		v, err := g.SetView(w.viewName(), 0, top, maxX-1, bottom)
+		if err != nil && err != gocui.ErrorUnkView {
+			return 0, err
+		}
+		v.Title = w.name
+		self.renderCapture(v, w)
+		shown[w.viewName()] = true
+		top = bottom + 1
+	}
+	for name := range self.captureViews {
+		if !shown[name] {
+			if err = g.DeleteView(name); err != nil {
+				return
+			}
+		}
+	}
+	self.captureViews = shown
+	return
+}
+
+func (self *Client) renderCapture(v *gocui.View, w *captureWindow) {
+	width, height := v.Size()
+	result := []string{}
+	self.lock.RLock()
+	for i := len(w.lines) - 1; i >= 0 && len(result) < height; i-- {
+		result = append(wrapLine(w.lines[i], width, self.wrapIndent), result...)
+	}
+	self.lock.RUnlock()
+	if len(result) > height {
+		result = result[len(result)-height:]
+	}
+	v.Clear()
+	fmt.Fprint(v, strings.Join(result, "\n"))
+}
+
+func (self *Client) bindCapture() {
+	self.bind("capture(name, pattern, options)", "Move received lines matching pattern to a window called name above the output. Options are {copy: true} to keep them in the output too, and {height: rows}.", func(call otto.FunctionCall) (result otto.Value) {
+		name := call.Argument(0).String()
+		if name == "" {
+			result, _ = otto.ToValue(fmt.Errorf("No capture window name given"))
+			return
+		}
+		pattern, err := regexp.Compile(call.Argument(1).String())
+		if err != nil {
+			result, _ = otto.ToValue(fmt.Errorf("Invalid capture pattern %#v: %v", call.Argument(1).String(), err))
+			return
+		}
+		w := &captureWindow{
+			name:    name,
+			pattern: pattern,
+			height:  defaultCaptureHeight,
+			owner:   self.currentOwner(),
+		}
+		if opts := call.Argument(2); opts.IsObject() {
+			if v, _ := opts.Object().Get("copy"); v.IsDefined() {
+				w.copy, _ = v.ToBoolean()
+			}
+			if v, _ := opts.Object().Get("height"); v.IsDefined() {
+				height, err := v.ToInteger()
+				if err != nil || height < 1 {
+					result, _ = otto.ToValue(fmt.Errorf("Invalid capture window height %#v", v.String()))
+					return
+				}
+				w.height = int(height)
+			}
+		}
+		self.lock.Lock()
+		replaced := false
+		for i, old := range self.captures {
+			if old.name == name {
+				w.lines = old.lines
+				self.captures[i] = w
+				replaced = true
+			}
+		}
+		if !replaced {
+			self.captures = append(self.captures, w)
+		}
+		self.lock.Unlock()
+		self.redraw()
+		return
+	})
+	self.bind("uncapture(name)", "Close a capture window.", func(call otto.FunctionCall) (result otto.Value) {
+		removed := self.removeCapture(call.Argument(0).String())
+		self.redraw()
+		result, _ = otto.ToValue(removed)
+		return
+	})
+}
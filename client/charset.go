@@ -0,0 +1,149 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/robertkrimen/otto"
+)
+
+const (
+	optCharset = 42
+)
+
+const (
+	charsetRequest  = 1
+	charsetAccepted = 2
+	charsetRejected = 3
+)
+
+const (
+	charsetUTF8   = "UTF-8"
+	charsetLatin1 = "ISO-8859-1"
+	charsetCP1252 = "WINDOWS-1252"
+)
+
+// charsetNames maps the names servers and scripts use for the supported charsets to the canonical ones.
+var charsetNames = map[string]string{
+	"UTF-8":        charsetUTF8,
+	"UTF8":         charsetUTF8,
+	"ISO-8859-1":   charsetLatin1,
+	"ISO_8859-1":   charsetLatin1,
+	"ISO8859-1":    charsetLatin1,
+	"LATIN1":       charsetLatin1,
+	"LATIN-1":      charsetLatin1,
+	"WINDOWS-1252": charsetCP1252,
+	"CP1252":       charsetCP1252,
+}
+
+// charsetPreference is the order charsets offered by a server are picked in.
+var charsetPreference = []string{charsetUTF8, charsetLatin1, charsetCP1252}
+
+// cp1252High holds the characters of 0x80-0x9f in Windows-1252, with U+FFFD for the bytes it leaves undefined.
+var cp1252High = [32]rune{
+	'€', '�', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '�', 'Ž', '�',
+	'�', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '�', 'ž', 'Ÿ',
+}
+
+func canonicalCharset(name string) (string, bool) {
+	result, found := charsetNames[strings.ToUpper(strings.TrimSpace(name))]
+	return result, found
+}
+
+// decodeCharset turns data in the single byte charset into UTF-8.
+func decodeCharset(charset string, data []byte) []byte {
+	out := &bytes.Buffer{}
+	for _, b := range data {
+		if charset == charsetCP1252 && b >= 0x80 && b < 0xa0 {
+			out.WriteRune(cp1252High[b-0x80])
+		} else {
+			out.WriteRune(rune(b))
+		}
+	}
+	return out.Bytes()
+}
+
+// encodeCharset turns text into the charset, replacing characters it can't represent with '?'.
+func encodeCharset(charset, text string) string {
+	if charset == charsetUTF8 {
+		return text
+	}
+	out := make([]byte, 0, len(text))
+	for _, r := range text {
+		b := byte('?')
+		switch {
+		case r < 0x80 || (r >= 0xa0 && r < 0x100):
+			b = byte(r)
+		case r >= 0x80 && r < 0xa0:
+			if charset == charsetLatin1 {
+				b = byte(r)
+			}
+		case charset == charsetCP1252 && r != utf8.RuneError:
+			for i, high := range cp1252High {
+				if high == r {
+					b = byte(0x80 + i)
+				}
+			}
+		}
+		out = append(out, b)
+	}
+	return string(out)
+}
+
+func (self *session) getCharset() string {
+	self.client.lock.RLock()
+	defer self.client.lock.RUnlock()
+	return self.charset
+}
+
+func (self *session) setCharset(charset string) {
+	self.client.lock.Lock()
+	defer self.client.lock.Unlock()
+	self.charset = charset
+}
+
+// charsetRequest picks the first preferred charset among those the server offers in data, which begins with the separator, and
+// accepts or rejects the request.
+func (self *telnet) charsetRequest(data []byte) {
+	if bytes.HasPrefix(data, []byte("[TTABLE]")) && len(data) > 9 {
+		data = data[9:]
+	}
+	if len(data) < 2 {
+		self.sendSub(optCharset, []byte{charsetRejected})
+		return
+	}
+	offered := map[string]string{}
+	for _, name := range bytes.Split(data[1:], data[:1]) {
+		if charset, found := canonicalCharset(string(name)); found {
+			if _, seen := offered[charset]; !seen {
+				offered[charset] = string(name)
+			}
+		}
+	}
+	for _, charset := range charsetPreference {
+		if name, found := offered[charset]; found {
+			self.sendSub(optCharset, append([]byte{charsetAccepted}, name...))
+			self.session.setCharset(charset)
+			return
+		}
+	}
+	self.sendSub(optCharset, []byte{charsetRejected})
+}
+
+func (self *Client) bindCharset() {
+	self.bind("charset(name)", "Get or set the charset of the current session: \"utf-8\", \"latin1\" or \"cp1252\". Servers negotiating a charset set it themselves.", func(call otto.FunctionCall) (result otto.Value) {
+		sess := self.target()
+		if arg := call.Argument(0); arg.IsDefined() {
+			charset, found := canonicalCharset(arg.String())
+			if !found {
+				result, _ = otto.ToValue(fmt.Errorf("Unsupported charset %#v", arg.String()))
+				return
+			}
+			sess.setCharset(charset)
+		}
+		result, _ = otto.ToValue(sess.getCharset())
+		return
+	})
+}
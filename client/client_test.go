@@ -0,0 +1,198 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTrimInput(t *testing.T) {
+	for _, tc := range []struct {
+		line string
+		want string
+	}{
+		{"", ""},
+		{"   ", ""},
+		{"look", "look"},
+		{"look   ", "look"},
+		{"say hi\t\n", "say hi"},
+		{"  kill rat", "  kill rat"},
+		{"x", "x"},
+		{"é", "é"},
+		{"say 日本語\x00\x00", "say 日本語"},
+		{"say ÅÄÖ ", "say ÅÄÖ"},
+	} {
+		if got := trimInput(tc.line); got != tc.want {
+			t.Errorf("trimInput(%q) = %q, want %q", tc.line, got, tc.want)
+		}
+	}
+}
+
+func TestHeadlessTypedLines(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := attachServer(t, c)
+	for _, tc := range []struct {
+		typed string
+		sent  string
+	}{
+		{"look", "look"},
+		{"look   ", "look"},
+		{"x", "x"},
+		{"é", "é"},
+		{"say 日本語", "say 日本語"},
+		{"say ÅÄÖ", "say ÅÄÖ"},
+	} {
+		if err := c.Input(tc.typed); err != nil {
+			t.Fatal(err)
+		}
+		server.expect(tc.sent)
+	}
+}
+
+// An empty line is sent like any other, unless repeatEmpty makes it send the previous one again.
+func TestHeadlessEmptyLine(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := attachServer(t, c)
+	if err := c.Input("n"); err != nil {
+		t.Fatal(err)
+	}
+	server.expect("n")
+	if err := c.Input(""); err != nil {
+		t.Fatal(err)
+	}
+	server.expect("n")
+	c.lock.Lock()
+	c.repeatEmpty = false
+	c.lock.Unlock()
+	if err := c.Input(""); err != nil {
+		t.Fatal(err)
+	}
+	server.expect("")
+}
+
+func TestSendlnOrder(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := connectServer(t, c)
+	// Like a script calling sendln in a loop, in script context.
+	c.schedule(func() {
+		for i := 0; i < 100; i++ {
+			if err := c.scriptCommand(fmt.Sprintf("line %v", i)); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	c.redraw()
+	for i := 0; i < 100; i++ {
+		server.expect(fmt.Sprintf("line %v", i))
+	}
+}
+
+func TestSendNowhere(t *testing.T) {
+	c := New()
+	if err := c.send("look"); err == nil || !strings.Contains(err.Error(), "Nowhere to send") {
+		t.Errorf("send without a connection returned %v", err)
+	}
+	if err := c.sendln("look"); err == nil || !strings.Contains(err.Error(), "Nowhere to send") {
+		t.Errorf("sendln without a connection returned %v", err)
+	}
+}
+
+func TestScriptSendlnOrder(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := connectServer(t, c)
+	if err := c.Input(`/for (var i = 0; i < 100; i++) { sendln("line " + i); }`); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		server.expect(fmt.Sprintf("line %v", i))
+	}
+}
+
+func TestScriptSendNowhere(t *testing.T) {
+	c, _ := startHeadless(t)
+	if err := c.Input(`/sendln("look")`); err != nil {
+		t.Fatal(err)
+	}
+	waitOutput(t, c, `Nowhere to send "look"`)
+}
+
+func TestOutputBeforeViews(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	c := NewHeadless(80, 6, WithStartupScript(""))
+	c.Outputf("Written before Run.\n")
+	done := make(chan struct{})
+	go func() {
+		c.Run()
+		close(done)
+	}()
+	defer func() {
+		c.Close()
+		<-done
+	}()
+	<-c.started
+	// Six rows are too few for the views.
+	c.Outputf("Written while too small.\n")
+	c.redraw()
+	if _, err := c.View("output"); err == nil {
+		t.Fatal("The output view exists on a screen too small for it")
+	}
+	c.guiLock.Lock()
+	c.gui.(*headlessDisplay).height = 24
+	c.guiLock.Unlock()
+	c.redraw()
+	eventually(t, "the early output in the output view", func() bool {
+		text, err := c.View("output")
+		return err == nil && strings.Contains(text, "Written before Run.") && strings.Contains(text, "Written while too small.")
+	})
+}
+
+// Run with -race: output from several goroutines, typed lines and redraws of the views must not race.
+func TestConcurrentOutput(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := attachServer(t, c)
+	const writers, lines = 4, 500
+	wg := sync.WaitGroup{}
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < lines; j++ {
+				c.Outputf("writer %v line %v\n", i, j)
+			}
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 20; j++ {
+			if err := c.Input(fmt.Sprintf("say %v", j)); err != nil {
+				t.Error(err)
+			}
+			if _, err := c.View("output"); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	for j := 0; j < 20; j++ {
+		server.expect(fmt.Sprintf("say %v", j))
+	}
+	wg.Wait()
+	// Every line is stored whole, and the lines of each writer in the order it wrote them.
+	next := make([]int, writers)
+	for _, line := range c.Output() {
+		var i, j int
+		if n, _ := fmt.Sscanf(line, "writer %d line %d", &i, &j); n != 2 {
+			continue
+		}
+		if j != next[i] {
+			t.Fatalf("Writer %v line %v came after line %v", i, j, next[i]-1)
+		}
+		next[i]++
+	}
+	for i, count := range next {
+		if count != lines {
+			t.Errorf("Writer %v has %v lines shown, want %v", i, count, lines)
+		}
+	}
+}
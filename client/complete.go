@@ -0,0 +1,98 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/zond/gocui"
+)
+
+const (
+	completionLines     = 1000
+	minCompletionLength = 3
+)
+
+// completion remembers the candidates of the last Tab, so pressing it again with the line untouched cycles to the next one.
+type completion struct {
+	start      int
+	end        int
+	line       string
+	candidates []string
+	index      int
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '\''
+}
+
+// completionWords returns the words of lines, newest first, skipping numbers and short words.
+func completionWords(lines []string) (result []string) {
+	for i := len(lines) - 1; i >= 0; i-- {
+		words := strings.FieldsFunc(stripANSI(lines[i]), func(r rune) bool {
+			return !isWordRune(r)
+		})
+		for j := len(words) - 1; j >= 0; j-- {
+			word := strings.Trim(words[j], "-'")
+			if _, err := strconv.Atoi(word); err == nil || len([]rune(word)) < minCompletionLength {
+				continue
+			}
+			result = append(result, word)
+		}
+	}
+	return
+}
+
+// completions returns the words in the recent output of the active session and in the history that start with prefix, ignoring case.
+func (self *Client) completions(prefix string) (result []string) {
+	self.lock.RLock()
+	output := self.active.output()
+	if len(output) > completionLines {
+		output = output[len(output)-completionLines:]
+	}
+	words := completionWords(output)
+	self.lock.RUnlock()
+	words = append(words, completionWords(self.history)...)
+	prefix = strings.ToLower(prefix)
+	seen := map[string]bool{}
+	for _, word := range words {
+		lower := strings.ToLower(word)
+		if len(lower) > len(prefix) && strings.HasPrefix(lower, prefix) && !seen[lower] {
+			seen[lower] = true
+			result = append(result, word)
+		}
+	}
+	return
+}
+
+func (self *Client) complete(g *gocui.Gui, v *gocui.View) error {
+	input := g.View("input")
+	if input == nil || self.masked {
+		return nil
+	}
+	runes, pos := inputState(input)
+	comp := &self.completion
+	if comp.candidates == nil || string(runes) != comp.line || pos != comp.end {
+		start := pos
+		for start > 0 && isWordRune(runes[start-1]) {
+			start--
+		}
+		self.completion = completion{start: start, index: -1}
+		if start < pos {
+			comp.candidates = self.completions(string(runes[start:pos]))
+		}
+		if len(comp.candidates) == 0 {
+			comp.candidates = nil
+			fmt.Fprint(self.gui, "\a")
+			return nil
+		}
+	}
+	comp.index = (comp.index + 1) % len(comp.candidates)
+	candidate := []rune(comp.candidates[comp.index])
+	runes = append(append(append([]rune{}, runes[:comp.start]...), candidate...), runes[pos:]...)
+	comp.end = comp.start + len(candidate)
+	comp.line = string(runes)
+	setInputState(input, runes, comp.end)
+	return nil
+}
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+	"github.com/zond/gocui"
+)
+
+// inputPosition returns the lines of the input view, padded to reach the cursor, along with the line and rune index of the cursor.
+func inputPosition(v *gocui.View) (lines []string, line, pos int) {
+	for y := 0; ; y++ {
+		text, err := v.Line(y)
+		if err != nil {
+			break
+		}
+		lines = append(lines, trimInput(text))
+	}
+	cx, cy := v.Cursor()
+	ox, oy := v.Origin()
+	line = cy + oy
+	for len(lines) <= line {
+		lines = append(lines, "")
+	}
+	runes := []rune(lines[line])
+	for width := 0; width < cx+ox && pos < len(runes); pos++ {
+		width += runeWidth(runes[pos])
+	}
+	return
+}
+
+// setInputPosition fills the input view with lines and puts the cursor at rune pos of line, scrolling the view to keep it visible.
+func setInputPosition(v *gocui.View, lines []string, line, pos int) {
+	v.Clear()
+	fmt.Fprint(v, strings.Join(lines, "\n"))
+	_, height := v.Size()
+	origin := 0
+	if height > 0 && line >= height {
+		origin = line - height + 1
+	}
+	v.SetOrigin(0, origin)
+	v.SetCursor(stringWidth(string([]rune(lines[line])[:pos])), line-origin)
+}
+
+// setInputText fills the input view with text, which may have several lines, and puts the cursor at its end.
+func setInputText(v *gocui.View, text string) {
+	lines := strings.Split(text, "\n")
+	setInputPosition(v, lines, len(lines)-1, len([]rune(lines[len(lines)-1])))
+}
+
+func inputText(v *gocui.View) string {
+	return strings.Join(inputLines(v), "\n")
+}
+
+// newline splits the input line at the cursor, to compose several lines that Enter sends together.
+func (self *Client) newline(g *gocui.Gui, v *gocui.View) error {
+	if self.masked || self.copy.active {
+		return nil
+	}
+	lines, line, pos := inputPosition(v)
+	runes := []rune(lines[line])
+	lines = append(lines[:line+1], append([]string{string(runes[pos:])}, lines[line+1:]...)...)
+	lines[line] = string(runes[:pos])
+	setInputPosition(v, lines, line+1, 0)
+	return nil
+}
+
+// moveInputLine moves the cursor delta lines within a composed input and returns whether it could.
+func moveInputLine(v *gocui.View, delta int) bool {
+	lines, line, pos := inputPosition(v)
+	if line+delta < 0 || line+delta >= len(inputLines(v)) {
+		return false
+	}
+	line += delta
+	if length := len([]rune(lines[line])); pos > length {
+		pos = length
+	}
+	setInputPosition(v, lines, line, pos)
+	return true
+}
+
+func (self *Client) bindCompose() {
+	self.bind("inputHeight(rows)", "Get or set the number of rows of the input view.", func(call otto.FunctionCall) (result otto.Value) {
+		if arg := call.Argument(0); arg.IsDefined() {
+			rows, err := arg.ToInteger()
+			if err != nil || rows < 1 {
+				result, _ = otto.ToValue(fmt.Errorf("Invalid input height %#v", arg.String()))
+				return
+			}
+			self.Configure(WithInputHeight(int(rows)))
+		}
+		result, _ = otto.ToValue(self.getConfig().inputHeight)
+		return
+	})
+}
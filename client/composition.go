@@ -0,0 +1,71 @@
+package client
+
+import (
+	"unicode/utf8"
+
+	"github.com/zond/gocui"
+)
+
+const (
+	zeroWidthJoiner = '\u200d'
+	composingTitle  = "Composing, Enter again to send as it is"
+)
+
+// composing tells whether runes end in the middle of a character an input method or the terminal is still putting together: an
+// incomplete UTF-8 sequence, an emoji sequence ending in a zero width joiner, half a flag, or a Hangul leading consonant waiting for its
+// vowel, as a conjoining or a compatibility jamo, which is what input methods put in the buffer.
+func composing(runes []rune) bool {
+	if len(runes) == 0 {
+		return false
+	}
+	last := runes[len(runes)-1]
+	switch {
+	case last == utf8.RuneError, last == zeroWidthJoiner:
+		return true
+	case (last >= 0x1100 && last <= 0x115f) || (last >= 0xa960 && last <= 0xa97f) || (last >= 0x3131 && last <= 0x318e):
+		return true
+	}
+	indicators := 0
+	for i := len(runes) - 1; i >= 0 && runes[i] >= 0x1f1e6 && runes[i] <= 0x1f1ff; i-- {
+		indicators++
+	}
+	return indicators%2 == 1
+}
+
+// inputComposing tells whether the input view has a character being composed just before the cursor. The cursor is placed by width,
+// so zero width runes at it, like a joiner just typed, are before it too.
+func inputComposing(v *gocui.View) bool {
+	lines, line, pos := inputPosition(v)
+	runes := []rune(lines[line])
+	for pos < len(runes) && runeWidth(runes[pos]) == 0 {
+		pos++
+	}
+	return composing(runes[:pos])
+}
+
+// deferEnter holds back Enter while a character is being composed at the cursor, so an input method never gets a partial character
+// sent, and returns whether it did. finishComposition presses it once the character is complete, and Enter again sends the line as
+// it is.
+func (self *Client) deferEnter(v *gocui.View) bool {
+	if self.enterDeferred {
+		self.enterDeferred = false
+		return false
+	}
+	if !inputComposing(v) {
+		return false
+	}
+	self.enterDeferred = true
+	return true
+}
+
+// finishComposition sends the input line held back by deferEnter once the character at the cursor is complete. Emptying the input
+// line forgets about it.
+func (self *Client) finishComposition(g *gocui.Gui, v *gocui.View) {
+	if !self.enterDeferred || inputComposing(v) {
+		return
+	}
+	self.enterDeferred = false
+	if len(inputLines(v)) > 0 {
+		self.handleLine(g, v)
+	}
+}
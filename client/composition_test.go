@@ -0,0 +1,91 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zond/gocui"
+)
+
+func TestComposing(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{"empty", "", false},
+		{"ascii", "look", false},
+		{"complete hangul syllables", "안녕", false},
+		{"conjoining leading consonant", "안ᄂ", true},
+		{"conjoining leading consonant and vowel", "안나", false},
+		{"extended leading consonant", "ꥠ", true},
+		{"compatibility consonant", "안ㄴ", true},
+		{"compatibility vowel", "안ㅏ", true},
+		{"compatibility jamo only", "ㅎ", true},
+		{"incomplete utf-8", "안\xec\x95", true},
+		{"emoji", "👍", false},
+		{"emoji with trailing zwj", "👨‍", true},
+		{"zwj sequence", "👨‍👩‍👧", false},
+		{"zwj sequence waiting for its last emoji", "👨‍👩‍", true},
+		{"half a flag", "go \U0001f1f8", true},
+		{"flag", "go 🇸🇪", false},
+		{"flag and half a flag", "🇸🇪\U0001f1f3", true},
+		{"two flags", "🇸🇪🇳🇴", false},
+	} {
+		if got := composing([]rune(tc.input)); got != tc.want {
+			t.Errorf("%v: composing(%q) = %v, want %v", tc.name, tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestCompositionWire(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := attachServer(t, c)
+	for _, line := range []string{
+		"say 안녕하세요",
+		"say 👍",
+		"say 👨‍👩‍👧 family",
+		"say 🇸🇪🇳🇴",
+		"say é, ß and ✓",
+	} {
+		if err := c.Input(line); err != nil {
+			t.Fatal(err)
+		}
+		server.expect(line)
+		waitOutput(t, c, line)
+	}
+}
+
+func TestCompositionDefersEnter(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := attachServer(t, c)
+	if err := c.Input("say 한ㄱ"); err != nil {
+		t.Fatal(err)
+	}
+	server.expectNothing()
+	if text, _ := c.View("input"); !strings.Contains(text, "say 한ㄱ") {
+		t.Fatalf("Input view is %q after Enter while composing", text)
+	}
+	// The input method completes the syllable, and the held back Enter sends it.
+	c.guiLock.Lock()
+	input := c.gui.View("input")
+	input.Clear()
+	fmt.Fprint(input, "say 한가")
+	c.guiLock.Unlock()
+	c.redraw()
+	server.expect("say 한가")
+}
+
+func TestCompositionEnterTwice(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := attachServer(t, c)
+	if err := c.Input("say 👨‍"); err != nil {
+		t.Fatal(err)
+	}
+	server.expectNothing()
+	if err := c.Press(gocui.KeyEnter, 0); err != nil {
+		t.Fatal(err)
+	}
+	server.expect("say 👨‍")
+}
@@ -0,0 +1,187 @@
+package client
+
+import (
+	"os"
+	"time"
+)
+
+const (
+	defaultInputHeight = 4
+	defaultQuitTimeout = time.Second
+)
+
+// config holds the settings New and Configure take as options. It is guarded by the client lock.
+type config struct {
+	host            string
+	startupScript   string
+	logFile         string
+	scrollback      int
+	inputHeight     int
+	quitTimeout     time.Duration
+	timestampFormat string
+	localEcho       bool
+	echoPrefix      string
+	splitRatio      float64
+	historySize     int
+	quitCommand     string
+	paging          bool
+}
+
+func defaultConfig() config {
+	return config{
+		startupScript: defaultStartupScript(),
+		scrollback:    defaultScrollback,
+		inputHeight:   defaultInputHeight,
+		quitTimeout:   defaultQuitTimeout,
+		localEcho:     true,
+		splitRatio:    defaultSplitRatio,
+		historySize:   defaultHistorySize,
+	}
+}
+
+// Option changes a setting of a Client, see New and Configure.
+type Option func(*config)
+
+// WithHost connects to host once the startup script has run, so its connect hooks are in place.
+func WithHost(host string) Option {
+	return func(c *config) {
+		c.host = host
+	}
+}
+
+// WithStartupScript runs path at startup instead of ~/.mug.js.
+func WithStartupScript(path string) Option {
+	return func(c *config) {
+		c.startupScript = path
+	}
+}
+
+// WithLogFile logs the session to path from the start.
+func WithLogFile(path string) Option {
+	return func(c *config) {
+		c.logFile = path
+	}
+}
+
+// WithScrollback keeps lines output lines in each session.
+func WithScrollback(lines int) Option {
+	return func(c *config) {
+		if lines > 0 {
+			c.scrollback = lines
+		}
+	}
+}
+
+// WithInputHeight makes the input view rows high.
+func WithInputHeight(rows int) Option {
+	return func(c *config) {
+		if rows > 0 {
+			c.inputHeight = rows
+		}
+	}
+}
+
+// WithQuitTimeout sets how soon Ctrl-Q has to be pressed again to quit.
+func WithQuitTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.quitTimeout = timeout
+	}
+}
+
+// WithQuitCommand sends command to connected servers when quitting, see quitCommand in the script API.
+func WithQuitCommand(command string) Option {
+	return func(c *config) {
+		c.quitCommand = command
+	}
+}
+
+// WithTimestamps prefixes received lines with their arrival time in the Go time layout format, or turns timestamps off if it is empty.
+func WithTimestamps(format string) Option {
+	return func(c *config) {
+		c.timestampFormat = format
+	}
+}
+
+// WithLocalEcho turns showing sent lines in the output on or off.
+func WithLocalEcho(enabled bool) Option {
+	return func(c *config) {
+		c.localEcho = enabled
+	}
+}
+
+// WithEchoPrefix shows prefix before locally echoed lines.
+func WithEchoPrefix(prefix string) Option {
+	return func(c *config) {
+		c.echoPrefix = prefix
+	}
+}
+
+// WithSplitRatio makes the frozen scrollback take ratio of the output area while scrolled up, the rest showing live output. A ratio of
+// 0 or 1 turns the split off.
+func WithSplitRatio(ratio float64) Option {
+	return func(c *config) {
+		if ratio >= 0 && ratio <= 1 {
+			c.splitRatio = ratio
+		}
+	}
+}
+
+// WithHistorySize keeps lines lines of input history.
+func WithHistorySize(lines int) Option {
+	return func(c *config) {
+		if lines > 0 {
+			c.historySize = lines
+		}
+	}
+}
+
+// WithPaging holds the display once more than a screenful of lines arrives at once, until a key shows more.
+func WithPaging(enabled bool) Option {
+	return func(c *config) {
+		c.paging = enabled
+	}
+}
+
+// Configure changes settings while the client is running. WithHost and WithLogFile only have an effect before Run.
+func (self *Client) Configure(opts ...Option) {
+	self.lock.Lock()
+	for _, opt := range opts {
+		opt(&self.config)
+	}
+	for _, sess := range self.sessions {
+		sess.trimOutput(self.config.scrollback)
+	}
+	self.lock.Unlock()
+	self.redraw()
+}
+
+func (self *Client) getConfig() config {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.config
+}
+
+// autoConnect starts the log and the connection asked for in the options, reporting failures in the output view.
+func (self *Client) autoConnect() {
+	sess := self.activeSession()
+	config := self.getConfig()
+	if path := config.logFile; path != "" {
+		if err := sess.startLog(path, logOptions{}); err != nil {
+			self.OutputErrorf("Error logging to %#v: %v\n", path, err)
+		}
+	}
+	if host := config.host; host != "" {
+		go func() {
+			if err := sess.connect(host, dialOptions{}); err != nil {
+				sess.outputErrorf("Error connecting to %#v: %v\n", host, err)
+			}
+		}()
+	}
+}
+
+// startupScriptMissing tells whether the startup script doesn't exist and that is fine, because it is the default one.
+func (self *Client) startupScriptMissing() bool {
+	path := self.getConfig().startupScript
+	_, err := os.Stat(path)
+	return os.IsNotExist(err) && path == defaultStartupScript()
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"io"
+	"net"
+	"time"
+)
+
+// Conn is what a session talks to a server through. Dialed connections are wrapped network connections, but anything readable and
+// writable can be attached.
+type Conn interface {
+	io.ReadWriteCloser
+	// Description names the other end, e.g. its address.
+	Description() string
+}
+
+type netConn struct {
+	net.Conn
+}
+
+func (self netConn) Description() string {
+	return self.RemoteAddr().String()
+}
+
+type attachedConn struct {
+	io.ReadWriteCloser
+	label string
+}
+
+func (self attachedConn) Description() string {
+	return self.label
+}
+
+// readDeadliner and writeDeadliner are implemented by connections that can time out.
+type readDeadliner interface {
+	SetReadDeadline(time.Time) error
+}
+
+type writeDeadliner interface {
+	SetWriteDeadline(time.Time) error
+}
+
+// Attach makes rwc the connection of the active session, running it through the same telnet decoding, triggers, logging and output
+// as a dialed connection. label names it in the status line and messages, and defaults to its description if rwc is a Conn.
+func (self *Client) Attach(rwc io.ReadWriteCloser, label string) {
+	conn, ok := rwc.(Conn)
+	if !ok {
+		conn = attachedConn{
+			ReadWriteCloser: rwc,
+			label:           label,
+		}
+	}
+	if label == "" {
+		label = conn.Description()
+	}
+	sess := self.activeSession()
+	sess.stopReconnect()
+	sess.attach(conn, label, nil)
+}
@@ -0,0 +1,307 @@
+package client
+
+import (
+	"strings"
+
+	"github.com/robertkrimen/otto"
+	"github.com/zond/gocui"
+)
+
+const (
+	copyTitle     = "Copy: arrows or hjkl move, 0/$ line start/end, Space or v marks, V marks lines, y or Enter yanks, Esc leaves"
+	copyCursor    = "\033[7m"
+	copySelection = "\033[4m"
+	maxCopyCol    = 1 << 30
+)
+
+// copyMode is the state of selecting output text with the keyboard. Lines are numbered from the first line ever stored in the session,
+// so positions stay put while old lines are evicted. It is only used by the gui goroutine.
+type copyMode struct {
+	active   bool
+	session  *session
+	line     int
+	col      int
+	marked   bool
+	lineWise bool
+	markLine int
+	markCol  int
+	// end is the number of the line after the last one shown, which only moves to keep the cursor visible.
+	end      int
+	input    []rune
+	inputPos int
+}
+
+// span returns the selected columns of line, from inclusive to to exclusive, and whether any of it is selected.
+func (self *copyMode) span(line, length int) (from, to int, selected bool) {
+	if !self.marked {
+		return
+	}
+	startLine, startCol, endLine, endCol := self.markLine, self.markCol, self.line, self.col
+	if startLine > endLine || (startLine == endLine && startCol > endCol) {
+		startLine, startCol, endLine, endCol = endLine, endCol, startLine, startCol
+	}
+	if line < startLine || line > endLine {
+		return
+	}
+	from, to = 0, length
+	if !self.lineWise {
+		if line == startLine {
+			from = startCol
+		}
+		if line == endLine && endCol+1 < to {
+			to = endCol + 1
+		}
+	}
+	return from, to, true
+}
+
+// decorate shows the selection and the cursor in the number line line of the output, as plain text since positions are in plain text.
+func (self *copyMode) decorate(number int, line string) string {
+	plain := []rune(stripANSI(line))
+	from, to, selected := self.span(number, len(plain))
+	if !selected && number != self.line {
+		return line
+	}
+	if number == self.line && self.col >= len(plain) {
+		plain = append(plain, ' ')
+	}
+	out := &strings.Builder{}
+	for i, r := range plain {
+		sgr := ""
+		switch {
+		case number == self.line && i == self.col:
+			sgr = copyCursor
+		case selected && i >= from && i < to:
+			sgr = copySelection
+		}
+		if sgr != "" {
+			out.WriteString(sgr + string(r) + ansiReset)
+		} else {
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
+
+// selection returns the selected plain text, or the cursor line if nothing is marked.
+func (self *copyMode) selection(all []string, evicted int) string {
+	if !self.marked {
+		if i := self.line - evicted; i >= 0 && i < len(all) {
+			return stripANSI(all[i])
+		}
+		return ""
+	}
+	result := []string{}
+	for i, line := range all {
+		plain := []rune(stripANSI(line))
+		if from, to, selected := self.span(i+evicted, len(plain)); selected {
+			if from > to {
+				from = to
+			}
+			result = append(result, string(plain[from:to]))
+		}
+	}
+	return strings.Join(result, "\n")
+}
+
+// enterCopy freezes the output of the active session with a cursor on its last shown line, putting the input line aside until copy
+// mode is left.
+func (self *Client) enterCopy(g *gocui.Gui, v *gocui.View) error {
+	return self.startCopy(g.View("input"))
+}
+
+// startCopy enters copy mode like enterCopy, setting aside the input view input, and does nothing when there is none.
+func (self *Client) startCopy(input *gocui.View) error {
+	if self.copy.active || self.masked {
+		return nil
+	}
+	if input == nil {
+		return nil
+	}
+	self.lock.RLock()
+	sess := self.active
+	end := len(sess.output()) - sess.scroll + sess.evicted
+	self.lock.RUnlock()
+	if end == sess.evicted {
+		self.OutputErrorf("Nothing to copy\n")
+		return nil
+	}
+	runes, pos := inputState(input)
+	self.copy = copyMode{
+		active:   true,
+		session:  sess,
+		line:     end - 1,
+		end:      end,
+		input:    runes,
+		inputPos: pos,
+	}
+	input.Clear()
+	input.SetCursor(0, 0)
+	input.Title = copyTitle
+	return nil
+}
+
+func (self *Client) leaveCopy(g *gocui.Gui) {
+	if !self.copy.active {
+		return
+	}
+	if input := g.View("input"); input != nil {
+		setInputState(input, self.copy.input, self.copy.inputPos)
+		input.Title = ""
+	}
+	self.copy = copyMode{}
+}
+
+// moveCopy moves the copy mode cursor lines down and cols right, staying within the stored lines and the text of the line.
+func (self *Client) moveCopy(lines, cols int) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	sess := self.copy.session
+	all := sess.output()
+	line := self.copy.line + lines
+	if line < sess.evicted {
+		line = sess.evicted
+	}
+	if line >= sess.evicted+len(all) {
+		line = sess.evicted + len(all) - 1
+	}
+	length := len([]rune(stripANSI(all[line-sess.evicted])))
+	col := self.copy.col + cols
+	if col > length {
+		col = length
+	}
+	if col < 0 {
+		col = 0
+	}
+	self.copy.line, self.copy.col = line, col
+}
+
+// yank puts the selection in the clipboard register and the system clipboard, and leaves copy mode.
+func (self *Client) yank(g *gocui.Gui) {
+	self.lock.Lock()
+	sess := self.copy.session
+	text := self.copy.selection(sess.output(), sess.evicted)
+	self.clipboard = text
+	self.lock.Unlock()
+	self.copyToClipboard(text)
+	self.leaveCopy(g)
+	self.OutputNoticef("Copied %v characters\n", len([]rune(text)))
+}
+
+// copyKeys takes the runes typed into the input view in copy mode as commands.
+func (self *Client) copyKeys(g *gocui.Gui, v *gocui.View) {
+	if !self.copy.active {
+		return
+	}
+	if self.copy.session != self.activeSession() {
+		self.leaveCopy(g)
+		return
+	}
+	line, _ := v.Line(0)
+	v.Clear()
+	v.SetCursor(0, 0)
+	for _, r := range trimInput(line) {
+		switch r {
+		case 'h':
+			self.moveCopy(0, -1)
+		case 'l':
+			self.moveCopy(0, 1)
+		case 'j':
+			self.moveCopy(1, 0)
+		case 'k':
+			self.moveCopy(-1, 0)
+		case '0':
+			self.moveCopy(0, -self.copy.col)
+		case '$':
+			self.moveCopy(0, maxCopyCol)
+		case ' ', 'v', 'V':
+			self.copy.marked = true
+			self.copy.lineWise = r == 'V'
+			self.copy.markLine, self.copy.markCol = self.copy.line, self.copy.col
+		case 'y':
+			self.yank(g)
+			return
+		case 'q':
+			self.leaveCopy(g)
+			return
+		}
+	}
+}
+
+// copyOutput returns the rows of the frozen output in copy mode, moving the shown lines just enough to keep the cursor visible. It must
+// be called with the client lock held.
+func (self *Client) copyOutput(width, height int) []string {
+	sess := self.copy.session
+	all := sess.output()
+	cursor, end := self.copy.line-sess.evicted, self.copy.end-sess.evicted
+	if end > len(all) {
+		end = len(all)
+	}
+	if cursor >= end {
+		end = cursor + 1
+	}
+	if top := end - sess.fitLines(all, end-1, -1, width, height); cursor < top {
+		end = cursor + sess.fitLines(all, cursor, 1, width, height)
+	}
+	self.copy.end = end + sess.evicted
+	return sess.visibleOutput(len(all)-end, width, height, func(i int, line string) string {
+		return self.copy.decorate(i+sess.evicted, line)
+	})
+}
+
+// copyHandler runs handler outside of copy mode, and inCopy in it.
+func (self *Client) copyHandler(handler gocui.KeybindingHandler, inCopy func(g *gocui.Gui)) gocui.KeybindingHandler {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if self.copy.active {
+			inCopy(g)
+			return nil
+		}
+		return handler(g, v)
+	}
+}
+
+func (self *Client) bindCopyKeys() (err error) {
+	if err = self.setKeybinding(gocui.KeyCtrlSpace, 0, "Ctrl-Space", "Enter copy mode, to select output text with the keyboard and copy it.", self.enterCopy); err != nil {
+		return
+	}
+	if err = self.setKeybinding(gocui.KeyEsc, 0, "Esc", "Leave copy mode.", func(g *gocui.Gui, v *gocui.View) error {
+		self.leaveCopy(g)
+		return nil
+	}); err != nil {
+		return
+	}
+	moveInput := func(delta int) gocui.KeybindingHandler {
+		return self.editInput(func(runes []rune, pos int) ([]rune, int) {
+			if pos += delta; pos < 0 {
+				pos = 0
+			}
+			if limit := len([]rune(trimInput(string(runes)))); pos > limit {
+				pos = limit
+			}
+			return runes, pos
+		})
+	}
+	if err = self.setKeybinding(gocui.KeyArrowLeft, 0, "Left", "Move the cursor left.", self.copyHandler(moveInput(-1), func(g *gocui.Gui) {
+		self.moveCopy(0, -1)
+	})); err != nil {
+		return
+	}
+	return self.setKeybinding(gocui.KeyArrowRight, 0, "Right", "Move the cursor right.", self.copyHandler(moveInput(1), func(g *gocui.Gui) {
+		self.moveCopy(0, 1)
+	}))
+}
+
+func (self *Client) bindCopy() {
+	self.bind("copy()", "Enter copy mode, like Ctrl-Space.", func(call otto.FunctionCall) (result otto.Value) {
+		self.schedule(func() {
+			self.startCopy(self.gui.View("input"))
+		})
+		return
+	})
+	self.bind("clipboard()", "Return the text last copied in copy mode.", func(call otto.FunctionCall) (result otto.Value) {
+		self.lock.RLock()
+		defer self.lock.RUnlock()
+		result, _ = otto.ToValue(self.clipboard)
+		return
+	})
+}
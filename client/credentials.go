@@ -0,0 +1,285 @@
+package client
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/robertkrimen/otto"
+)
+
+const (
+	credentialsFileName   = "credentials.json"
+	credentialsIterations = 200000
+	credentialsKeySize    = 32
+	credentialsSaltSize   = 16
+)
+
+type credential struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+// credentialsFile is the on-disk form of the credentials, with the entries either in the clear or sealed with a passphrase derived key.
+type credentialsFile struct {
+	Entries map[string]credential `json:"entries,omitempty"`
+	Salt    []byte                `json:"salt,omitempty"`
+	Sealed  []byte                `json:"sealed,omitempty"`
+}
+
+// credentials are the login names and passwords per host, kept outside of scripts. Encrypted credentials stay locked, with nil
+// entries, until the passphrase has been given once. They are only used in script context.
+type credentials struct {
+	path    string
+	entries map[string]credential
+	salt    []byte
+	sealed  []byte
+	key     []byte
+}
+
+func loadCredentials(path string) (result *credentials, err error) {
+	result = &credentials{
+		path:    path,
+		entries: map[string]credential{},
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	file := credentialsFile{}
+	if err = json.Unmarshal(data, &file); err != nil {
+		return
+	}
+	if file.Sealed != nil {
+		result.entries = nil
+		result.salt, result.sealed = file.Salt, file.Sealed
+	} else if file.Entries != nil {
+		result.entries = file.Entries
+	}
+	return
+}
+
+func (self *credentials) locked() bool {
+	return self.entries == nil
+}
+
+func (self *credentials) cipher(passphrase string) (result cipher.AEAD, err error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, self.salt, credentialsIterations, credentialsKeySize)
+	if err != nil {
+		return
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return
+	}
+	return cipher.NewGCM(block)
+}
+
+func (self *credentials) unlock(passphrase string) (err error) {
+	gcm, err := self.cipher(passphrase)
+	if err != nil {
+		return
+	}
+	if len(self.sealed) < gcm.NonceSize() {
+		return fmt.Errorf("Corrupt %v", self.path)
+	}
+	plain, err := gcm.Open(nil, self.sealed[:gcm.NonceSize()], self.sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return fmt.Errorf("Wrong passphrase for %v", self.path)
+	}
+	entries := map[string]credential{}
+	if err = json.Unmarshal(plain, &entries); err != nil {
+		return
+	}
+	self.entries = entries
+	self.key = []byte(passphrase)
+	return
+}
+
+// encrypt makes future saves seal the entries with a key derived from passphrase and a fresh salt.
+func (self *credentials) encrypt(passphrase string) (err error) {
+	self.salt = make([]byte, credentialsSaltSize)
+	if _, err = rand.Read(self.salt); err != nil {
+		return
+	}
+	self.key = []byte(passphrase)
+	return self.save()
+}
+
+// save writes the credentials readable only by the user, sealed with a fresh nonce if they are encrypted.
+func (self *credentials) save() (err error) {
+	file := credentialsFile{}
+	if self.key == nil {
+		file.Entries = self.entries
+	} else {
+		plain, err := json.Marshal(self.entries)
+		if err != nil {
+			return err
+		}
+		gcm, err := self.cipher(string(self.key))
+		if err != nil {
+			return err
+		}
+		nonce := make([]byte, gcm.NonceSize())
+		if _, err = rand.Read(nonce); err != nil {
+			return err
+		}
+		file.Salt = self.salt
+		file.Sealed = gcm.Seal(nonce, nonce, plain, nil)
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return
+	}
+	return writeFileAtomic(self.path, data, 0600)
+}
+
+// withCredentials runs f with unlocked credentials, asking for the passphrase first if they are still locked.
+func (self *Client) withCredentials(f func(*credentials)) {
+	if !self.credentials.locked() {
+		f(self.credentials)
+		return
+	}
+	self.ask("Passphrase for saved credentials", true, func(passphrase string) {
+		if err := self.credentials.unlock(passphrase); err != nil {
+			self.OutputErrorf("%v\n", err)
+			return
+		}
+		f(self.credentials)
+	})
+}
+
+// credentialsHost returns the key credentials for host are stored under, the address connect() would end up at.
+func (self *Client) credentialsHost(host string) (string, error) {
+	opts := dialOptions{}
+	self.lock.RLock()
+	port := self.defaultPort
+	self.lock.RUnlock()
+	return normalizeAddress(parseScheme(host, &opts), port)
+}
+
+// autoLogin sends the name stored for host once connected, and arms the session to send the password when the server turns echo off.
+// Neither is echoed, logged or added to history.
+func (self *Client) autoLogin(sess *session, host string) {
+	self.withCredentials(func(c *credentials) {
+		cred, found := c.entries[host]
+		if !found {
+			return
+		}
+		sess.setLoginPassword(cred.Password)
+		if err := sess.write(cred.Name+"\n", originClient); err != nil {
+			sess.outputErrorf("Unable to log in: %v\n", err)
+			return
+		}
+		sess.outputNoticef("Logging in as %v\n", cred.Name)
+	})
+}
+
+func (self *Client) bindCredentials() {
+	var err error
+	if self.credentials, err = loadCredentials(filepath.Join(mugDir(), credentialsFileName)); err != nil {
+		self.OutputErrorf("Unable to load saved credentials: %v\n", err)
+	}
+	saveCredentials := func(c *credentials) {
+		if err := c.save(); err != nil {
+			self.OutputErrorf("Unable to save %v: %v\n", c.path, err)
+		}
+	}
+	obj, _ := self.ot.Object("({})")
+	self.bindMethod(obj, "credentials", "set(host, name, password)", "Save the login for host, sent automatically after connecting to it.", func(call otto.FunctionCall) (result otto.Value) {
+		host, err := self.credentialsHost(call.Argument(0).String())
+		if err != nil {
+			result, _ = otto.ToValue(err)
+			return
+		}
+		cred := credential{
+			Name:     call.Argument(1).String(),
+			Password: call.Argument(2).String(),
+		}
+		self.withCredentials(func(c *credentials) {
+			c.entries[host] = cred
+			saveCredentials(c)
+		})
+		return
+	})
+	self.bindMethod(obj, "credentials", "get(host)", "Return {name, password} saved for host, or undefined.", func(call otto.FunctionCall) (result otto.Value) {
+		host, err := self.credentialsHost(call.Argument(0).String())
+		if err != nil {
+			result, _ = otto.ToValue(err)
+			return
+		}
+		if self.credentials.locked() {
+			self.withCredentials(func(*credentials) {})
+			result, _ = otto.ToValue(fmt.Errorf("Saved credentials are locked, enter the passphrase and try again"))
+			return
+		}
+		cred, found := self.credentials.entries[host]
+		if !found {
+			return
+		}
+		obj, _ := self.ot.Object("({})")
+		obj.Set("name", cred.Name)
+		obj.Set("password", cred.Password)
+		return obj.Value()
+	})
+	self.bindMethod(obj, "credentials", "remove(host)", "Forget the login saved for host.", func(call otto.FunctionCall) (result otto.Value) {
+		host, err := self.credentialsHost(call.Argument(0).String())
+		if err != nil {
+			result, _ = otto.ToValue(err)
+			return
+		}
+		self.withCredentials(func(c *credentials) {
+			if _, found := c.entries[host]; found {
+				delete(c.entries, host)
+				saveCredentials(c)
+			}
+		})
+		return
+	})
+	self.bindMethod(obj, "credentials", "hosts()", "List the hosts with saved logins.", func(call otto.FunctionCall) (result otto.Value) {
+		if self.credentials.locked() {
+			result, _ = otto.ToValue(fmt.Errorf("Saved credentials are locked, enter the passphrase and try again"))
+			self.withCredentials(func(*credentials) {})
+			return
+		}
+		hosts := make([]string, 0, len(self.credentials.entries))
+		for host := range self.credentials.entries {
+			hosts = append(hosts, host)
+		}
+		sort.Strings(hosts)
+		arr, _ := self.ot.Object("[]")
+		for i, host := range hosts {
+			arr.Set(strconv.Itoa(i), host)
+		}
+		return arr.Value()
+	})
+	self.bindMethod(obj, "credentials", "encrypt()", "Ask for a passphrase and encrypt the saved logins with it. The passphrase is asked for once per run.", func(call otto.FunctionCall) (result otto.Value) {
+		self.withCredentials(func(c *credentials) {
+			self.ask("New passphrase for saved credentials", true, func(passphrase string) {
+				if passphrase == "" {
+					self.OutputNoticef("Empty passphrase, credentials left as they were\n")
+					return
+				}
+				if err := c.encrypt(passphrase); err != nil {
+					self.OutputErrorf("Unable to encrypt %v: %v\n", c.path, err)
+					return
+				}
+				self.OutputNoticef("Encrypted %v\n", c.path)
+			})
+		})
+		return
+	})
+	self.ot.Set("credentials", obj)
+}
@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+type dialOptions struct {
+	tls      bool
+	insecure bool
+	proxy    *socks5Proxy
+}
+
+func parseDialOptions(value otto.Value) (result dialOptions, err error) {
+	if !value.IsObject() {
+		return
+	}
+	obj := value.Object()
+	for _, key := range obj.Keys() {
+		v, _ := obj.Get(key)
+		switch key {
+		case "tls":
+			result.tls, err = v.ToBoolean()
+		case "insecure":
+			result.insecure, err = v.ToBoolean()
+		case "proxy":
+			result.proxy, err = parseProxy(v.String())
+		default:
+			err = fmt.Errorf("Unknown connect option %#v", key)
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func parseScheme(host string, opts *dialOptions) string {
+	if strings.HasPrefix(host, "tls://") {
+		opts.tls = true
+		return host[len("tls://"):]
+	}
+	return strings.TrimPrefix(host, "telnet://")
+}
+
+// normalizeAddress accepts host, host:port, [v6]:port and bare IP literals. A bare IPv6 literal is always taken to be an address without port.
+func normalizeAddress(host string, defaultPort int) (result string, err error) {
+	if host == "" {
+		err = fmt.Errorf("No host given")
+		return
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return net.JoinHostPort(host, strconv.Itoa(defaultPort)), nil
+	}
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return net.JoinHostPort(host[1:len(host)-1], strconv.Itoa(defaultPort)), nil
+	}
+	if !strings.Contains(host, ":") {
+		return net.JoinHostPort(host, strconv.Itoa(defaultPort)), nil
+	}
+	h, p, err := net.SplitHostPort(host)
+	if err != nil {
+		return
+	}
+	if port, e := strconv.Atoi(p); e != nil || port < 1 || port > 65535 {
+		err = fmt.Errorf("Invalid port %#v", p)
+		return
+	}
+	return net.JoinHostPort(h, p), nil
+}
+
+func dialDirect(ctx context.Context, host string, timeout time.Duration) (result net.Conn, addr string, err error) {
+	h, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return
+	}
+	addrs, err := net.DefaultResolver.LookupHost(ctx, h)
+	if err != nil {
+		return
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	for _, a := range addrs {
+		addr = net.JoinHostPort(a, port)
+		if result, err = dialer.DialContext(ctx, "tcp", addr); err == nil {
+			return
+		}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+			return
+		}
+	}
+	return
+}
+
+// dialTLS runs a TLS handshake over conn, giving up when ctx is done or after timeout.
+func dialTLS(ctx context.Context, conn net.Conn, host string, opts dialOptions, timeout time.Duration) (result net.Conn, err error) {
+	serverName, _, err := net.SplitHostPort(host)
+	if err != nil {
+		return
+	}
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: opts.insecure,
+	})
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err = tlsConn.HandshakeContext(ctx); err != nil {
+		if subject, found := certificateSubject(err); found {
+			err = fmt.Errorf("Untrusted certificate %#v (use {insecure:true} to connect anyway): %v", subject, err)
+		} else if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("TLS handshake timed out after %v", timeout)
+		}
+		return
+	}
+	result = tlsConn
+	return
+}
+
+func certificateSubject(err error) (subject string, found bool) {
+	var verificationErr *tls.CertificateVerificationError
+	if errors.As(err, &verificationErr) && len(verificationErr.UnverifiedCertificates) > 0 {
+		return verificationErr.UnverifiedCertificates[0].Subject.String(), true
+	}
+	var authorityErr x509.UnknownAuthorityError
+	if errors.As(err, &authorityErr) && authorityErr.Cert != nil {
+		return authorityErr.Cert.Subject.String(), true
+	}
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &hostnameErr) && hostnameErr.Certificate != nil {
+		return hostnameErr.Certificate.Subject.String(), true
+	}
+	return
+}
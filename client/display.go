@@ -0,0 +1,270 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/zond/gocui"
+)
+
+// display is what the client uses of the screen. Layout and key handlers get the gocui gui from the display calling them, so a display
+// that never calls them runs the client without any views at all.
+type display interface {
+	// Write takes escape sequences for the terminal itself, like bells, notifications and clipboard requests.
+	io.Writer
+	Init() error
+	Close()
+	Size() (width, height int)
+	View(name string) *gocui.View
+	SetLayout(layout func(*gocui.Gui) error)
+	SetKeybinding(viewname string, key interface{}, mod gocui.Modifier, handler gocui.KeybindingHandler) error
+	SetShowCursor(show bool)
+	Flush() error
+	MainLoop() error
+}
+
+// terminalDisplay shows the client in the terminal through gocui.
+type terminalDisplay struct {
+	*gocui.Gui
+}
+
+func (self terminalDisplay) Write(b []byte) (int, error) {
+	return os.Stdout.Write(b)
+}
+
+func (self terminalDisplay) SetShowCursor(show bool) {
+	self.ShowCursor = show
+}
+
+// headlessDisplay keeps the client off the terminal. It lays the views out in a gocui gui that is never initialized, which keeps them
+// in memory, and presses keys by calling the editor and the handlers bound to them and then flushing, like the gocui main loop, so
+// everything but the drawing works as in the terminal.
+type headlessDisplay struct {
+	gui *gocui.Gui
+	// views is the lock the client holds while it writes views.
+	views         sync.Locker
+	width, height int
+	layout        func(*gocui.Gui) error
+	keyLock       sync.Mutex
+	keys          map[headlessKey][]gocui.KeybindingHandler
+	closeOnce     sync.Once
+	closed        chan struct{}
+}
+
+type headlessKey struct {
+	key interface{}
+	mod gocui.Modifier
+}
+
+func (self *headlessDisplay) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (self *headlessDisplay) Init() error {
+	return nil
+}
+
+func (self *headlessDisplay) Close() {
+	self.closeOnce.Do(func() {
+		close(self.closed)
+	})
+}
+
+func (self *headlessDisplay) Size() (width, height int) {
+	return self.width, self.height
+}
+
+func (self *headlessDisplay) View(name string) *gocui.View {
+	return self.gui.View(name)
+}
+
+func (self *headlessDisplay) SetLayout(layout func(*gocui.Gui) error) {
+	self.layout = layout
+}
+
+func (self *headlessDisplay) SetKeybinding(viewname string, key interface{}, mod gocui.Modifier, handler gocui.KeybindingHandler) error {
+	self.keyLock.Lock()
+	defer self.keyLock.Unlock()
+	k := headlessKey{key: key, mod: mod}
+	self.keys[k] = append(self.keys[k], handler)
+	return nil
+}
+
+func (self *headlessDisplay) SetShowCursor(show bool) {
+	self.gui.ShowCursor = show
+}
+
+// Flush lays the views out, and ends the main loop when layout asks for it like gocui does.
+func (self *headlessDisplay) Flush() (err error) {
+	if self.layout == nil {
+		return nil
+	}
+	if err = self.layout(self.gui); err == gocui.ErrorQuit {
+		self.Close()
+	}
+	return
+}
+
+func (self *headlessDisplay) MainLoop() error {
+	<-self.closed
+	return gocui.ErrorQuit
+}
+
+func (self *headlessDisplay) currentView() *gocui.View {
+	self.views.Lock()
+	defer self.views.Unlock()
+	return self.gui.CurrentView()
+}
+
+// press calls the handlers bound to key with the current view and then flushes, like the gocui main loop does when the key is pressed.
+func (self *headlessDisplay) press(key interface{}, mod gocui.Modifier) error {
+	self.keyLock.Lock()
+	handlers := append([]gocui.KeybindingHandler{}, self.keys[headlessKey{key: key, mod: mod}]...)
+	self.keyLock.Unlock()
+	for _, handler := range handlers {
+		if err := handler(self.gui, self.currentView()); err != nil {
+			if err == gocui.ErrorQuit {
+				self.Close()
+			}
+			return err
+		}
+	}
+	return self.Flush()
+}
+
+// edit passes ch to the editor of the current view if it is editable and then flushes, like the gocui main loop does for typed text.
+func (self *headlessDisplay) edit(ch rune) error {
+	self.views.Lock()
+	v := self.gui.CurrentView()
+	var editor gocui.Editor
+	if v != nil && v.Editable {
+		editor = v.Editor
+	}
+	self.views.Unlock()
+	if editor != nil {
+		editor.Edit(v, 0, ch, 0)
+	}
+	return self.Flush()
+}
+
+// NewHeadless creates a client like New whose views are only kept in memory, which leaves the terminal alone, for driving it from
+// tests. Run it in a goroutine, Attach one end of a net.Pipe as its connection, type with Input, Type and Press and look at what it shows
+// with Output and View. The screen is width by height.
+func NewHeadless(width, height int, opts ...Option) (result *Client) {
+	result = New(opts...)
+	result.gui = &headlessDisplay{
+		gui:    gocui.NewGui(),
+		views:  &result.guiLock,
+		width:  width,
+		height: height,
+		keys:   map[headlessKey][]gocui.KeybindingHandler{},
+		closed: make(chan struct{}),
+	}
+	return
+}
+
+// headless returns the display of a client made by NewHeadless, once Run has set it up.
+func (self *Client) headless() (result *headlessDisplay, err error) {
+	result, ok := self.gui.(*headlessDisplay)
+	if !ok {
+		return nil, fmt.Errorf("Not a headless client")
+	}
+	<-self.started
+	return
+}
+
+// Press presses key, a gocui.Key or a rune, with mod in a headless client, and returns once its handlers have run. The views are
+// laid out first, so it acts on what the screen would show.
+func (self *Client) Press(key interface{}, mod gocui.Modifier) error {
+	display, err := self.headless()
+	if err != nil {
+		return err
+	}
+	if err = display.Flush(); err != nil {
+		return err
+	}
+	return display.press(key, mod)
+}
+
+// Type types text into the focused view of a headless client through its editor, a rune at a time, and returns once it has been
+// handled.
+func (self *Client) Type(text string) error {
+	display, err := self.headless()
+	if err != nil {
+		return err
+	}
+	if err = display.Flush(); err != nil {
+		return err
+	}
+	for _, r := range text {
+		if err = display.edit(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Input types line into the empty input line of a headless client and presses Enter, and returns once it has been handled.
+func (self *Client) Input(line string) error {
+	display, err := self.headless()
+	if err != nil {
+		return err
+	}
+	if err = display.Flush(); err != nil {
+		return err
+	}
+	self.guiLock.Lock()
+	if input := display.View("input"); input != nil {
+		input.Clear()
+		fmt.Fprint(input, line)
+		width := 0
+		for _, r := range line {
+			width += runeWidth(r)
+		}
+		input.SetCursor(width, 0)
+	}
+	self.guiLock.Unlock()
+	return display.press(gocui.KeyEnter, 0)
+}
+
+// View returns the text of the named view of a headless client as of the last layout, e.g. "input", "status" or "output", without
+// colors.
+func (self *Client) View(name string) (result string, err error) {
+	display, err := self.headless()
+	if err != nil {
+		return
+	}
+	self.guiLock.Lock()
+	defer self.guiLock.Unlock()
+	v := display.View(name)
+	if v == nil {
+		return "", fmt.Errorf("No view named %#v", name)
+	}
+	lines := []string{}
+	for y := 0; ; y++ {
+		line, err := v.Line(y)
+		if err != nil {
+			break
+		}
+		lines = append(lines, stripANSI(line))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Output returns the lines of the active session, including an incomplete last line, without their colors.
+func (self *Client) Output() (result []string) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	for _, line := range self.active.output() {
+		result = append(result, stripANSI(line))
+	}
+	return
+}
+
+// OutputText returns the lines of Output joined by newlines, for looking for text in what the active session shows.
+func (self *Client) OutputText() string {
+	return strings.Join(self.Output(), "\n")
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+)
+
+// echoSent shows the lines of text about to be sent with origin in the output, unless local echo is off, echoSends leaves out
+// origin or the server has turned echo off.
+func (self *session) echoSent(text, origin string) {
+	self.client.lock.RLock()
+	enabled, prefix := self.client.config.localEcho, self.client.config.echoPrefix
+	suffix, muted, mode := self.client.echoSuffix, self.client.echoMuted, self.client.echoSends
+	if originPrefix, found := self.client.echoPrefixes[origin]; found {
+		prefix = originPrefix
+	}
+	self.client.lock.RUnlock()
+	byUser := origin == originUser || origin == originAlias
+	if !enabled || muted || mode == echoSendsNone || (mode == echoSendsUser && !byUser) || self.passwordMode() {
+		return
+	}
+	category := themeEcho
+	if !byUser {
+		category = themeScriptEcho
+	}
+	for _, line := range strings.Split(strings.TrimRight(text, "\r\n"), "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			self.outputf("%v\n", self.client.themed(category, prefix+line+suffix))
+		}
+	}
+}
+
+func (self *Client) bindLocalEcho() {
+	self.bind("localecho(enabled, prefix)", "Get or set whether sent lines are shown in the output, optionally after prefix.", func(call otto.FunctionCall) (result otto.Value) {
+		opts := []Option{}
+		if arg := call.Argument(0); arg.IsDefined() {
+			enabled, err := arg.ToBoolean()
+			if err != nil {
+				result, _ = otto.ToValue(err)
+				return
+			}
+			opts = append(opts, WithLocalEcho(enabled))
+		}
+		if arg := call.Argument(1); arg.IsDefined() {
+			opts = append(opts, WithEchoPrefix(arg.String()))
+		}
+		self.Configure(opts...)
+		if config := self.getConfig(); config.localEcho {
+			result, _ = otto.ToValue(fmt.Sprintf("Local echo enabled with prefix %#v", config.echoPrefix))
+		} else {
+			result, _ = otto.ToValue("Local echo disabled")
+		}
+		return
+	})
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// maxStackLines is how much of the stack of a script error is shown.
+	maxStackLines = 4
+)
+
+// reportedError is an error already shown by reportError, returned to stop whatever caused it.
+type reportedError struct {
+	error
+}
+
+// registeredIn describes where a registration owned by owner was made, for error messages.
+func registeredIn(owner string) string {
+	if owner == "" {
+		return ""
+	}
+	return " from " + owner
+}
+
+// splitError returns the message and the stack lines of err. It never panics, whatever err is.
+func splitError(err error) (message string, stack []string) {
+	defer func() {
+		if e := recover(); e != nil {
+			message, stack = fmt.Sprintf("%v (unable to describe error: %v)", fmt.Sprintf("%T", err), e), nil
+		}
+	}()
+	lines := strings.Split(strings.TrimSpace(describeError(err)), "\n")
+	message = lines[0]
+	for _, line := range lines[1:] {
+		if line = strings.TrimSpace(line); line != "" {
+			stack = append(stack, line)
+		}
+	}
+	return
+}
+
+// reportError shows a script error in source in the error style, with a truncated stack, and passes it to the error hooks. Errors
+// in error hooks are only shown, so a failing hook can't loop.
+func (self *Client) reportError(source string, err error) {
+	message, stack := splitError(err)
+	out := &strings.Builder{}
+	fmt.Fprintf(out, "Error in %v: %v\n", source, message)
+	for i, line := range stack {
+		if i == maxStackLines {
+			fmt.Fprintf(out, "    ... %v more\n", len(stack)-i)
+			break
+		}
+		fmt.Fprintf(out, "    %v\n", line)
+	}
+	self.Outputf("%s", self.themed(themeError, out.String()))
+	if self.reportingError {
+		return
+	}
+	context := self.context
+	self.schedule(func() {
+		self.reportingError = true
+		defer func() {
+			self.reportingError = false
+		}()
+		if context != nil {
+			self.within(context, func() {
+				self.fireHook("error", source, message, strings.Join(stack, "\n"))
+			})
+		} else {
+			self.fireHook("error", source, message, strings.Join(stack, "\n"))
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/robertkrimen/otto"
+	"github.com/zond/gocui"
+)
+
+const findTitle = "Find (Enter searches, Ctrl-F searches again)"
+
+// find searches the active scrollback backwards from the last match, or the bottom of the view, and scrolls to the matching line. An empty pattern repeats the last search.
+func (self *Client) find(pattern string) (err error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	sess := self.active
+	if pattern != "" {
+		if sess.findPattern, err = regexp.Compile(pattern); err != nil {
+			return
+		}
+		sess.found = -1
+	}
+	if sess.findPattern == nil {
+		return fmt.Errorf("Nothing to search for")
+	}
+	total := len(sess.output())
+	from := sess.found
+	if from < 0 {
+		if from = total - sess.scroll; from > len(sess.lines) {
+			from = len(sess.lines)
+		}
+	}
+	match := func(i int) bool {
+		return sess.findPattern.MatchString(stripANSI(sess.lines[i]))
+	}
+	wrapped := false
+	i := from - 1
+	for ; i >= 0 && !match(i); i-- {
+	}
+	if i < 0 {
+		wrapped = true
+		for i = len(sess.lines) - 1; i >= from && !match(i); i-- {
+		}
+		if i < from {
+			return fmt.Errorf("No match for %#v", sess.findPattern.String())
+		}
+	}
+	sess.found = i
+	end := i + self.outputHeight/2 + 1
+	if end > total {
+		end = total
+	}
+	sess.scroll = total - end
+	if sess.scroll == 0 {
+		sess.newLines, sess.urgent = 0, false
+		sess.more, sess.paged = false, 0
+	}
+	if wrapped {
+		sess.appendOutput(fmt.Sprintf("Search for %#v wrapped around to the bottom\n", sess.findPattern.String()))
+	}
+	return
+}
+
+func (self *Client) ctrlf(g *gocui.Gui, v *gocui.View) error {
+	if self.masked {
+		return nil
+	}
+	if !self.searching {
+		self.searching = true
+		v.Title = findTitle
+		return nil
+	}
+	line, _ := v.Line(0)
+	v.Clear()
+	v.SetCursor(0, 0)
+	if err := self.find(line); err != nil {
+		self.OutputErrorf("%v\n", err)
+	}
+	return nil
+}
+
+func (self *Client) bindFind() {
+	self.bind("find(pattern)", "Scroll to the previous line in the scrollback matching the regular expression pattern. An empty pattern repeats the last search.", func(call otto.FunctionCall) (result otto.Value) {
+		pattern := ""
+		if arg := call.Argument(0); arg.IsDefined() {
+			pattern = arg.String()
+		}
+		if err := self.find(pattern); err != nil {
+			result, _ = otto.ToValue(err)
+		}
+		return
+	})
+}
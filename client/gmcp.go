@@ -0,0 +1,81 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+)
+
+func (self *session) receiveGMCP(data []byte) {
+	name, payload := string(data), ""
+	if i := bytes.IndexAny(data, " \n"); i != -1 {
+		name, payload = string(data[:i]), strings.TrimSpace(string(data[i+1:]))
+	}
+	if strings.EqualFold(name, "Core.Ping") {
+		self.pong(pingGMCP)
+	}
+	self.schedule(func() {
+		self.client.dispatchGMCP(name, payload)
+	})
+}
+
+func (self *Client) dispatchGMCP(name, payload string) {
+	var handlers []*hook
+	parts := strings.Split(name, ".")
+	for i := len(parts); i > 0; i-- {
+		handlers = append(handlers, self.gmcpHandlers[strings.ToLower(strings.Join(parts[:i], "."))]...)
+	}
+	if len(handlers) == 0 {
+		return
+	}
+	data, _ := otto.ToValue(payload)
+	if payload != "" {
+		if parsed, err := self.ot.Call("JSON.parse", nil, payload); err == nil {
+			data = parsed
+		}
+	}
+	for _, handler := range handlers {
+		if _, err := self.callScript(handler.fn, data, name, payload); err != nil {
+			self.reportError(fmt.Sprintf("GMCP handler for %#v%v", name, registeredIn(handler.owner)), err)
+		}
+	}
+}
+
+func (self *Client) bindGMCP() {
+	obj, _ := self.ot.Object("({})")
+	self.bindMethod(obj, "gmcp", "on(package, fn)", "Call fn(data, name, raw) for GMCP messages in package, e.g. \"Char.Vitals\" or \"Char\".", func(call otto.FunctionCall) (result otto.Value) {
+		name := call.Argument(0).String()
+		if !call.Argument(1).IsFunction() {
+			result, _ = otto.ToValue(fmt.Errorf("Handler for %#v is not a function", name))
+			return
+		}
+		key := strings.ToLower(name)
+		self.gmcpHandlers[key] = append(self.gmcpHandlers[key], &hook{
+			fn:    call.Argument(1),
+			owner: self.currentOwner(),
+		})
+		return
+	})
+	self.bindMethod(obj, "gmcp", "send(package, data)", "Send a GMCP message with data encoded as JSON.", func(call otto.FunctionCall) (result otto.Value) {
+		name := call.Argument(0).String()
+		tn := self.getTelnet()
+		if tn == nil || !tn.enabled(optGMCP) {
+			result, _ = otto.ToValue(fmt.Errorf("GMCP not negotiated, unable to send %#v", name))
+			return
+		}
+		payload := name
+		if arg := call.Argument(1); arg.IsDefined() {
+			encoded, err := self.ot.Call("JSON.stringify", nil, arg)
+			if err != nil {
+				result, _ = otto.ToValue(fmt.Errorf("Unable to encode GMCP data for %#v: %v", name, err))
+				return
+			}
+			payload += " " + encoded.String()
+		}
+		tn.sendSub(optGMCP, []byte(payload))
+		return
+	})
+	self.ot.Set("gmcp", obj)
+}
@@ -0,0 +1,127 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/robertkrimen/otto"
+	"github.com/zond/gocui"
+)
+
+// guiSettings are the layout choices of scripts. Scripts never touch gocui views, they change these under the client lock and
+// layout applies them on the gui goroutine every pass, so they survive resizes.
+type guiSettings struct {
+	width, height int
+	// outputPercent is how much of the screen the views above the input get, or 0 to use the input height instead.
+	outputPercent int
+	titles        map[string]string
+	focus         string
+}
+
+// titledViews are the views scripts may set titles for.
+var titledViews = map[string]bool{
+	"output": true,
+	"input":  true,
+	"live":   true,
+}
+
+// layoutInputHeight returns the number of rows of the input view on a screen height rows high.
+func (self *Client) layoutInputHeight(height int) (result int) {
+	self.lock.RLock()
+	percent := self.guiSettings.outputPercent
+	result = self.config.inputHeight
+	self.lock.RUnlock()
+	if percent > 0 {
+		result = (height - 2) * (100 - percent) / 100
+	}
+	if result < 1 {
+		result = 1
+	}
+	if result > height/2 {
+		result = height / 2
+	}
+	return
+}
+
+// viewTitle returns the title a script set for view, or def.
+func (self *Client) viewTitle(view, def string) string {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	if title, found := self.guiSettings.titles[view]; found {
+		return title
+	}
+	return def
+}
+
+// layoutFocus makes the view scripts chose current, and remembers the screen size for them.
+func (self *Client) layoutFocus(g *gocui.Gui, width, height int) {
+	self.lock.Lock()
+	self.guiSettings.width, self.guiSettings.height = width, height
+	focus := self.guiSettings.focus
+	self.lock.Unlock()
+	if focus == "" || g.View(focus) == nil {
+		focus = "input"
+	}
+	// gocui reads the current view on its main loop without the gui lock, so it is only written when it changes.
+	if current := g.CurrentView(); current == nil || current.Name() != focus {
+		g.SetCurrentView(focus)
+	}
+}
+
+func (self *Client) bindGui() {
+	obj, _ := self.ot.Object("({})")
+	self.bindMethod(obj, "gui", "size()", "Return the {width, height} of the terminal at the last redraw.", func(call otto.FunctionCall) (result otto.Value) {
+		self.lock.RLock()
+		width, height := self.guiSettings.width, self.guiSettings.height
+		self.lock.RUnlock()
+		size, _ := self.ot.Object("({})")
+		size.Set("width", width)
+		size.Set("height", height)
+		return size.Value()
+	})
+	self.bindMethod(obj, "gui", "split(percent)", "Get or set the percentage of the screen above the input view, or use the input height again with 0.", func(call otto.FunctionCall) (result otto.Value) {
+		if arg := call.Argument(0); arg.IsDefined() {
+			percent, err := arg.ToInteger()
+			if err != nil || percent < 0 || percent > 95 {
+				result, _ = otto.ToValue(fmt.Errorf("Invalid split %#v, it has to be between 0 and 95", arg.String()))
+				return
+			}
+			self.lock.Lock()
+			self.guiSettings.outputPercent = int(percent)
+			self.lock.Unlock()
+			self.redraw()
+		}
+		self.lock.RLock()
+		defer self.lock.RUnlock()
+		result, _ = otto.ToValue(self.guiSettings.outputPercent)
+		return
+	})
+	self.bindMethod(obj, "gui", "title(view, text)", "Set the frame title of the output, input or live view, or restore the usual one by leaving out text. Titles of modes like copy mode still take precedence in the input view.", func(call otto.FunctionCall) (result otto.Value) {
+		view := call.Argument(0).String()
+		if !titledViews[view] {
+			result, _ = otto.ToValue(fmt.Errorf("Unknown view %#v", view))
+			return
+		}
+		self.lock.Lock()
+		if text := call.Argument(1); text.IsDefined() {
+			self.guiSettings.titles[view] = text.String()
+		} else {
+			delete(self.guiSettings.titles, view)
+		}
+		self.lock.Unlock()
+		self.redraw()
+		return
+	})
+	self.bindMethod(obj, "gui", "focus(view)", "Make the input or output view receive the keys.", func(call otto.FunctionCall) (result otto.Value) {
+		view := call.Argument(0).String()
+		if view != "input" && view != "output" {
+			result, _ = otto.ToValue(fmt.Errorf("Unknown view %#v", view))
+			return
+		}
+		self.lock.Lock()
+		self.guiSettings.focus = view
+		self.lock.Unlock()
+		self.redraw()
+		return
+	})
+	self.ot.Set("gui", obj)
+}
@@ -0,0 +1,310 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zond/gocui"
+)
+
+const headlessTimeout = 5 * time.Second
+
+// testServer is the server end of the connection of a headless client. It reads what the client sends as it arrives, so the client
+// never blocks writing to it.
+type testServer struct {
+	t     testing.TB
+	conn  net.Conn
+	lines chan string
+}
+
+func newTestServer(t testing.TB, conn net.Conn) (result *testServer) {
+	result = &testServer{t: t, conn: conn, lines: make(chan string, 100)}
+	go func() {
+		defer close(result.lines)
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			result.lines <- strings.TrimRight(line, "\r\n")
+		}
+	}()
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	return
+}
+
+// send writes lines to the client, each ended by CRLF like a MUD does.
+func (self *testServer) send(lines ...string) {
+	go func() {
+		for _, line := range lines {
+			if _, err := self.conn.Write([]byte(line + "\r\n")); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// expect fails the test unless the next line the client sends is want.
+func (self *testServer) expect(want string) {
+	self.t.Helper()
+	select {
+	case line, ok := <-self.lines:
+		if !ok {
+			self.t.Fatalf("Connection closed waiting for %q", want)
+		}
+		if line != want {
+			self.t.Fatalf("Got %q, want %q", line, want)
+		}
+	case <-time.After(headlessTimeout):
+		self.t.Fatalf("Timed out waiting for %q", want)
+	}
+}
+
+// expectNothing fails the test if the client sends a line in the next moment.
+func (self *testServer) expectNothing() {
+	self.t.Helper()
+	select {
+	case line := <-self.lines:
+		self.t.Fatalf("Got %q, want nothing", line)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// startHeadless runs a headless client with a home directory of its own, and stops it when the test ends.
+func startHeadless(t testing.TB, opts ...Option) (c *Client, done chan struct{}) {
+	t.Setenv("HOME", t.TempDir())
+	c = NewHeadless(80, 24, append([]Option{WithStartupScript("")}, opts...)...)
+	done = make(chan struct{})
+	go func() {
+		c.Run()
+		close(done)
+	}()
+	t.Cleanup(func() {
+		c.Close()
+		<-done
+	})
+	return
+}
+
+// attachServer attaches one end of a pipe to the active session of c and returns the other.
+func attachServer(t testing.TB, c *Client) *testServer {
+	server, client := net.Pipe()
+	c.Attach(client, "test server")
+	return newTestServer(t, server)
+}
+
+// eventually fails the test unless cond becomes true in time.
+func eventually(t testing.TB, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(headlessTimeout); !cond(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %v", what)
+		}
+	}
+}
+
+func waitOutput(t testing.TB, c *Client, text string) {
+	t.Helper()
+	eventually(t, fmt.Sprintf("%q in the output %q", text, c.Output()), func() bool {
+		return strings.Contains(c.OutputText(), text)
+	})
+}
+
+func TestHeadlessReceive(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := attachServer(t, c)
+	server.send("Welcome to the test MUD!", "There is a rat here.")
+	waitOutput(t, c, "There is a rat here.")
+	eventually(t, "the output view", func() bool {
+		text, err := c.View("output")
+		return err == nil && strings.Contains(text, "Welcome to the test MUD!") && strings.Contains(text, "There is a rat here.")
+	})
+}
+
+func TestHeadlessColors(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := attachServer(t, c)
+	server.send("\033[1;31mA red dragon\033[0m breathes fire.")
+	waitOutput(t, c, "A red dragon breathes fire.")
+}
+
+func TestHeadlessInput(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := attachServer(t, c)
+	if err := c.Input("look"); err != nil {
+		t.Fatal(err)
+	}
+	server.expect("look")
+	waitOutput(t, c, "look")
+	if text, err := c.View("input"); err != nil || strings.TrimSpace(text) != "" {
+		t.Errorf("Input view is %q, %v after sending", text, err)
+	}
+}
+
+func TestHeadlessSeparatedCommands(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := attachServer(t, c)
+	if err := c.Input("open door;north"); err != nil {
+		t.Fatal(err)
+	}
+	server.expect("open door")
+	server.expect("north")
+}
+
+func TestHeadlessRepeatedCommand(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := attachServer(t, c)
+	if err := c.Input("#3 kick"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		server.expect("kick")
+	}
+}
+
+// connectServer connects the active session of c to a loopback listener, and returns the server end of the connection.
+func connectServer(t testing.TB, c *Client) *testServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+	if err := c.activeSession().connect(listener.Addr().String(), dialOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case conn := <-accepted:
+		return newTestServer(t, conn)
+	case <-time.After(headlessTimeout):
+		t.Fatal("Timed out waiting for the connection")
+	}
+	return nil
+}
+
+func TestHeadlessConnect(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := connectServer(t, c)
+	server.send("By what name do you wish to be known?")
+	waitOutput(t, c, "By what name do you wish to be known?")
+	if err := c.Input("Tester"); err != nil {
+		t.Fatal(err)
+	}
+	server.expect("Tester")
+}
+
+func TestHeadlessQuitKeys(t *testing.T) {
+	c, done := startHeadless(t)
+	if err := c.Press(gocui.KeyCtrlQ, 0); err != nil {
+		t.Fatal(err)
+	}
+	waitOutput(t, c, "Press C-q again")
+	if err := c.Press(gocui.KeyCtrlQ, 0); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(headlessTimeout):
+		t.Fatal("Run didn't return after quitting")
+	}
+}
+
+func TestHeadlessQuitFromScript(t *testing.T) {
+	c, done := startHeadless(t)
+	// As the quit() binding does.
+	c.schedule(func() {
+		c.quitting = true
+	})
+	c.redraw()
+	select {
+	case <-done:
+	case <-time.After(headlessTimeout):
+		t.Fatal("Run didn't return after quitting")
+	}
+}
+
+func TestHeadlessNotHeadless(t *testing.T) {
+	c := New()
+	if err := c.Input("look"); err == nil {
+		t.Error("Input on a terminal client didn't fail")
+	}
+}
+
+// The tests below evaluate JavaScript, and need the real script engine.
+
+func TestHeadlessScriptTrigger(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := attachServer(t, c)
+	if err := c.Input(`/addTrigger("^You are hungry", function() { send("eat bread"); })`); err != nil {
+		t.Fatal(err)
+	}
+	server.send("You are hungry.")
+	server.expect("eat bread")
+}
+
+func TestHeadlessScriptConnectReceiveTriggerSend(t *testing.T) {
+	c, _ := startHeadless(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+	if err := c.Input(`/addTrigger("^What is your name\\?", function() { send("Tester"); })`); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Input("/connect " + listener.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	var conn net.Conn
+	select {
+	case conn = <-accepted:
+	case <-time.After(headlessTimeout):
+		t.Fatal("Timed out waiting for the connection")
+	}
+	server := newTestServer(t, conn)
+	server.send("Welcome!", "What is your name?")
+	server.expect("Tester")
+	waitOutput(t, c, "Welcome!")
+}
+
+func TestHeadlessScriptAlias(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := attachServer(t, c)
+	if err := c.Input(`/alias k kill rat`); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Input("k"); err != nil {
+		t.Fatal(err)
+	}
+	server.expect("kill rat")
+}
+
+func TestHeadlessScriptQuit(t *testing.T) {
+	c, done := startHeadless(t)
+	if err := c.Input("/quit()"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(headlessTimeout):
+		t.Fatal("Run didn't return after quit()")
+	}
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+	"github.com/zond/gocui"
+)
+
+var bareCommandPattern = regexp.MustCompile("^[A-Za-z_$][A-Za-z0-9_$.]*$")
+
+type binding struct {
+	name      string
+	signature string
+	doc       string
+}
+
+type keyBinding struct {
+	name string
+	doc  string
+}
+
+// bind registers fn in the interpreter under the name in signature, and records it for help().
+func (self *Client) bind(signature, doc string, fn func(otto.FunctionCall) otto.Value) {
+	name := signature
+	if i := strings.Index(signature, "("); i != -1 {
+		name = signature[:i]
+	}
+	self.bindings = append(self.bindings, &binding{
+		name:      name,
+		signature: signature,
+		doc:       doc,
+	})
+	self.ot.Set(name, fn)
+}
+
+// bindMethod registers fn as a method of the object bound as prefix.
+func (self *Client) bindMethod(obj *otto.Object, prefix, signature, doc string, fn func(otto.FunctionCall) otto.Value) {
+	name := signature
+	if i := strings.Index(signature, "("); i != -1 {
+		name = signature[:i]
+	}
+	self.bindings = append(self.bindings, &binding{
+		name:      prefix + "." + name,
+		signature: prefix + "." + signature,
+		doc:       doc,
+	})
+	obj.Set(name, fn)
+}
+
+func (self *Client) findBinding(name string) *binding {
+	for _, b := range self.bindings {
+		if b.name == name {
+			return b
+		}
+	}
+	return nil
+}
+
+func (self *Client) setKeybinding(key interface{}, mod gocui.Modifier, name, doc string, handler gocui.KeybindingHandler) error {
+	if name != "" {
+		self.keyBindings = append(self.keyBindings, &keyBinding{
+			name: name,
+			doc:  doc,
+		})
+	}
+	sig := keySpec{key: key, mod: mod}.sig()
+	if name == "" {
+		name = doc
+	}
+	self.builtinKeys[sig] = name
+	return self.gui.SetKeybinding("", key, mod, self.guiHandler(self.builtinKey(sig, handler)))
+}
+
+func (self *Client) help(name string) {
+	if name != "" {
+		b := self.findBinding(name)
+		if b == nil {
+			self.Outputf("No function named %#v\n", name)
+			return
+		}
+		self.Outputf("%v\n  %v\n", b.signature, b.doc)
+		return
+	}
+	width := 0
+	for _, b := range self.bindings {
+		if len(b.signature) > width {
+			width = len(b.signature)
+		}
+	}
+	self.Outputf("Functions (call as /name(args), or as /name arg1 arg2 with quotes around arguments with spaces or operators, the last one taking the rest of the line):\n")
+	for _, b := range self.bindings {
+		doc := b.doc
+		if i := strings.Index(doc, ". "); i != -1 {
+			doc = doc[:i+1]
+		}
+		self.Outputf("  %-*v  %v\n", width, b.signature, doc)
+	}
+	width = 0
+	for _, k := range self.keyBindings {
+		if len(k.name) > width {
+			width = len(k.name)
+		}
+	}
+	self.Outputf("Keys:\n")
+	for _, k := range self.keyBindings {
+		self.Outputf("  %-*v  %v\n", width, k.name, k.doc)
+	}
+}
+
+func (self *Client) bindHelp() {
+	self.bind("help(name)", "List all functions and keys, or describe the function name in detail.", func(call otto.FunctionCall) (result otto.Value) {
+		name := ""
+		if arg := call.Argument(0); arg.IsDefined() {
+			name = arg.String()
+		}
+		self.help(name)
+		return
+	})
+}
@@ -0,0 +1,152 @@
+package client
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+)
+
+type highlightRule struct {
+	id      int
+	pattern *regexp.Regexp
+	color   string
+	sgr     string
+	owner   string
+}
+
+func isSGR(escape string) bool {
+	return strings.HasSuffix(escape, "m")
+}
+
+func isReset(escape string) bool {
+	params := escape[2 : len(escape)-1]
+	return params == "" || params == "0" || strings.HasPrefix(params, "0;")
+}
+
+// applyHighlights colors all matches of the rules in raw, matching against the text without escape sequences and
+// letting later rules override earlier ones. Server colors inside a highlighted span are suppressed, and restored after it.
+func applyHighlights(raw string, rules []*highlightRule) string {
+	if len(rules) == 0 {
+		return raw
+	}
+	escapes := map[int][]string{}
+	plain := &strings.Builder{}
+	last := 0
+	for _, loc := range ansiPattern.FindAllStringIndex(raw, -1) {
+		plain.WriteString(raw[last:loc[0]])
+		escapes[plain.Len()] = append(escapes[plain.Len()], raw[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	plain.WriteString(raw[last:])
+	text := plain.String()
+	colors := make([]int, len(text))
+	matched := false
+	for index, rule := range rules {
+		for _, loc := range rule.pattern.FindAllStringIndex(text, -1) {
+			for i := loc[0]; i < loc[1]; i++ {
+				colors[i] = index + 1
+				matched = true
+			}
+		}
+	}
+	if !matched {
+		return raw
+	}
+	out := &strings.Builder{}
+	state := ""
+	current := 0
+	for i := 0; i <= len(text); i++ {
+		for _, escape := range escapes[i] {
+			if isSGR(escape) {
+				if isReset(escape) {
+					state = escape
+				} else {
+					state += escape
+				}
+				if current != 0 {
+					continue
+				}
+			}
+			out.WriteString(escape)
+		}
+		if i == len(text) {
+			break
+		}
+		if colors[i] != current {
+			current = colors[i]
+			out.WriteString(ansiReset)
+			if current == 0 {
+				out.WriteString(state)
+			} else {
+				out.WriteString(rules[current-1].sgr)
+			}
+		}
+		out.WriteByte(text[i])
+	}
+	if current != 0 {
+		out.WriteString(ansiReset + state)
+	}
+	return out.String()
+}
+
+func (self *Client) bindHighlights() {
+	self.bind("highlight(pattern, color)", "Color all matches of pattern in received lines, in the highlight color of the theme if no color is given, and return the rule id.", func(call otto.FunctionCall) (result otto.Value) {
+		pattern, err := regexp.Compile(call.Argument(0).String())
+		if err != nil {
+			result, _ = otto.ToValue(fmt.Errorf("Invalid highlight pattern %#v: %v", call.Argument(0).String(), err))
+			return
+		}
+		if pattern.MatchString("") {
+			result, _ = otto.ToValue(fmt.Errorf("Highlight pattern %#v matches the empty string", call.Argument(0).String()))
+			return
+		}
+		color := self.themeSpec(themeHighlight)
+		if arg := call.Argument(1); arg.IsDefined() {
+			color = arg.String()
+		}
+		sgr, err := parseColor(color)
+		if err != nil {
+			result, _ = otto.ToValue(err)
+			return
+		}
+		if sgr == "" {
+			result, _ = otto.ToValue(fmt.Errorf("No highlight color given"))
+			return
+		}
+		self.nextTriggerId++
+		self.highlights = append(self.highlights, &highlightRule{
+			id:      self.nextTriggerId,
+			pattern: pattern,
+			color:   color,
+			sgr:     sgr,
+			owner:   self.currentOwner(),
+		})
+		result, _ = otto.ToValue(self.nextTriggerId)
+		return
+	})
+	self.bind("unhighlight(id)", "Remove a highlight rule.", func(call otto.FunctionCall) (result otto.Value) {
+		id, _ := call.Argument(0).ToInteger()
+		for i, rule := range self.highlights {
+			if rule.id == int(id) {
+				self.highlights = append(self.highlights[:i], self.highlights[i+1:]...)
+				result, _ = otto.ToValue(true)
+				return
+			}
+		}
+		result, _ = otto.ToValue(false)
+		return
+	})
+	self.bind("highlights()", "List highlight rules.", func(call otto.FunctionCall) (result otto.Value) {
+		items := []map[string]interface{}{}
+		for _, rule := range self.highlights {
+			items = append(items, map[string]interface{}{
+				"id":      rule.id,
+				"pattern": rule.pattern.String(),
+				"color":   rule.color,
+			})
+		}
+		return self.jsArray(items)
+	})
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+)
+
+const defaultHistorySize = 1000
+
+type historyIgnore struct {
+	pattern *regexp.Regexp
+	owner   string
+}
+
+// addHistory records line in the history unless it is blank, repeats the previous entry, was typed with echo off, or matches a
+// pattern given to historyIgnore. The oldest entries are dropped beyond the configured size.
+func (self *Client) addHistory(line string) {
+	if strings.TrimSpace(line) == "" || self.activeSession().passwordMode() {
+		return
+	}
+	if len(self.history) > 0 && self.history[len(self.history)-1] == line {
+		return
+	}
+	for _, ignore := range self.historyIgnores {
+		if ignore.pattern.MatchString(line) {
+			return
+		}
+	}
+	self.history = append(self.history, line)
+	if over := len(self.history) - self.getConfig().historySize; over > 0 {
+		self.history = append([]string{}, self.history[over:]...)
+	}
+}
+
+func (self *Client) bindHistory() {
+	self.bind("history()", "Return the history, oldest line first.", func(call otto.FunctionCall) (result otto.Value) {
+		arr, _ := self.ot.Object("[]")
+		for i, line := range self.history {
+			arr.Set(strconv.Itoa(i), line)
+		}
+		return arr.Value()
+	})
+	self.bind("clearhistory()", "Forget the history.", func(call otto.FunctionCall) (result otto.Value) {
+		self.history = nil
+		self.historyBack = 0
+		return
+	})
+	self.bind("historyIgnore(pattern)", "Never record lines matching pattern in the history.", func(call otto.FunctionCall) (result otto.Value) {
+		pattern, err := regexp.Compile(call.Argument(0).String())
+		if err != nil {
+			result, _ = otto.ToValue(fmt.Errorf("Invalid history pattern %#v: %v", call.Argument(0).String(), err))
+			return
+		}
+		self.historyIgnores = append(self.historyIgnores, &historyIgnore{
+			pattern: pattern,
+			owner:   self.currentOwner(),
+		})
+		return
+	})
+	self.bind("historysize(lines)", "Get or set the number of lines kept in the history.", func(call otto.FunctionCall) (result otto.Value) {
+		if arg := call.Argument(0); arg.IsDefined() {
+			lines, err := arg.ToInteger()
+			if err != nil || lines < 1 {
+				result, _ = otto.ToValue(fmt.Errorf("Invalid history size %#v", arg.String()))
+				return
+			}
+			self.Configure(WithHistorySize(int(lines)))
+			if over := len(self.history) - int(lines); over > 0 {
+				self.history = append([]string{}, self.history[over:]...)
+				self.historyBack = 0
+			}
+		}
+		result, _ = otto.ToValue(self.getConfig().historySize)
+		return
+	})
+}
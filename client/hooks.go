@@ -0,0 +1,77 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/robertkrimen/otto"
+)
+
+var hookEvents = map[string]bool{
+	"connect":    true,
+	"disconnect": true,
+	"bell":       true,
+	"error":      true,
+	"exit":       true,
+	"line":       true,
+}
+
+type hook struct {
+	fn    otto.Value
+	owner string
+}
+
+// fireHook runs all handlers for event in registration order. It must run in script context, i.e. from the job queue or a script.
+func (self *Client) fireHook(event string, args ...interface{}) {
+	for _, h := range append([]*hook{}, self.hooks[event]...) {
+		if _, err := self.callScript(h.fn, args...); err != nil {
+			self.reportError(fmt.Sprintf("%v handler%v", event, registeredIn(h.owner)), err)
+		}
+	}
+}
+
+// runLineHooks passes a received line through the line handlers in registration order, and returns what the last one made of it.
+func (self *Client) runLineHooks(line, raw string) (resultLine, resultRaw string, dropped bool) {
+	resultLine, resultRaw = line, raw
+	for _, h := range append([]*hook{}, self.hooks["line"]...) {
+		result, err := self.callScript(h.fn, resultLine, resultRaw)
+		if err != nil {
+			self.reportError(fmt.Sprintf("line handler%v", registeredIn(h.owner)), err)
+			continue
+		}
+		if result.IsString() {
+			resultRaw = expandMarkup(result.String())
+			resultLine = stripANSI(resultRaw)
+		} else if result.IsBoolean() {
+			if b, _ := result.ToBoolean(); !b {
+				dropped = true
+				return
+			}
+		}
+	}
+	return
+}
+
+func (self *Client) scheduleHook(event string, args ...interface{}) {
+	self.schedule(func() {
+		self.fireHook(event, args...)
+	})
+}
+
+func (self *Client) bindHooks() {
+	self.bind("on(event, fn)", "Call fn when event happens. Events: connect(host), disconnect(host, error), bell(), error(source, message, stack), exit(), which has a second to finish, and line(line, raw) for every received line, where returning a string replaces the line for later handlers, triggers and display and returning false drops it.", func(call otto.FunctionCall) (result otto.Value) {
+		event := call.Argument(0).String()
+		if !hookEvents[event] {
+			result, _ = otto.ToValue(fmt.Errorf("Unknown event %#v", event))
+			return
+		}
+		if !call.Argument(1).IsFunction() {
+			result, _ = otto.ToValue(fmt.Errorf("Handler for %#v is not a function", event))
+			return
+		}
+		self.hooks[event] = append(self.hooks[event], &hook{
+			fn:    call.Argument(1),
+			owner: self.currentOwner(),
+		})
+		return
+	})
+}
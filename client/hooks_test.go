@@ -0,0 +1,41 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// BenchmarkLineHook passes 10000 received lines through the line pipeline with no line handlers, and with one or more that look at
+// every line without changing it. Compare the lines/s to see what the handlers cost.
+func BenchmarkLineHook(b *testing.B) {
+	const count = 10000
+	lines := []string{}
+	for i := 0; i < count; i++ {
+		lines = append(lines, fmt.Sprintf("\033[32mA goblin\033[0m attacks you, line %v.", i))
+	}
+	for _, handlers := range []int{0, 1, 3} {
+		b.Run(fmt.Sprintf("%v handlers", handlers), func(b *testing.B) {
+			c := New()
+			script := strings.Repeat(`on("line", function(line, raw) { if (line.indexOf("xyzzy") >= 0) { return false; } });`, handlers)
+			if _, err := c.ot.Run(script); err != nil {
+				b.Fatal(err)
+			}
+			if got := len(c.hooks["line"]); got != handlers {
+				b.Fatalf("%v line handlers registered, want %v", got, handlers)
+			}
+			sess := c.activeSession()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				sess.batch(func() {
+					at := time.Now()
+					for _, line := range lines {
+						c.processLine(line, 0, at)
+					}
+				})
+			}
+			b.ReportMetric(float64(count*b.N)/b.Elapsed().Seconds(), "lines/s")
+		})
+	}
+}
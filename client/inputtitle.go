@@ -0,0 +1,88 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+)
+
+const defaultInputTitle = "%n %h %q %s"
+
+// modeTitle returns the title of the input view while it is used for something other than typing commands, or "" if it isn't.
+func (self *Client) modeTitle() string {
+	switch {
+	case self.copy.active:
+		return copyTitle
+	case self.question != nil:
+		return self.question.title
+	case self.searching:
+		return findTitle
+	case self.enterDeferred:
+		return composingTitle
+	case self.pendingPaste != nil:
+		return fmt.Sprintf("Send %v pasted lines? (y/n, then Enter)", len(self.pendingPaste))
+	}
+	return ""
+}
+
+// contextTitle expands the input title format for the active session: %n is the session name, %h the host or "offline", %q "[paused]"
+// while commands wait in the send queue, %s "[script]" while a script runs and %% a percent sign. Empty tokens leave no stray spaces,
+// and the result is cut to width.
+func (self *Client) contextTitle(width int) string {
+	sess := self.activeSession()
+	self.lock.RLock()
+	format, name, host := self.inputTitle, sess.name, sess.host
+	self.lock.RUnlock()
+	if sess.getConn() == nil {
+		host = "offline"
+	}
+	self.interruptLock.Lock()
+	running := self.scriptRunning
+	self.interruptLock.Unlock()
+	out := &strings.Builder{}
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			out.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'n':
+			out.WriteString(name)
+		case 'h':
+			out.WriteString(host)
+		case 'q':
+			if queued := sess.queueSize(); queued > 0 {
+				fmt.Fprintf(out, "[paused %v]", queued)
+			}
+		case 's':
+			if running {
+				out.WriteString("[script]")
+			}
+		case '%':
+			out.WriteByte('%')
+		default:
+			out.WriteByte('%')
+			out.WriteByte(format[i])
+		}
+	}
+	title := strings.Join(strings.Fields(out.String()), " ")
+	if title == "" {
+		return ""
+	}
+	title, _ = truncateWidth(" "+title+" ", width)
+	return title
+}
+
+func (self *Client) bindInputTitle() {
+	self.bind("inputTitle(format)", "Get or set the title of the input view. %n is the session name, %h the host or \"offline\", %q shows the number of queued commands, %s shows when a script runs. An empty format shows no title.", func(call otto.FunctionCall) (result otto.Value) {
+		self.lock.Lock()
+		defer self.lock.Unlock()
+		if arg := call.Argument(0); arg.IsDefined() {
+			self.inputTitle = arg.String()
+		}
+		result, _ = otto.ToValue(self.inputTitle)
+		return
+	})
+}
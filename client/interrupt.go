@@ -0,0 +1,105 @@
+package client
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robertkrimen/otto"
+	"github.com/zond/gocui"
+)
+
+const (
+	defaultScriptTimeout = 5 * time.Second
+)
+
+type scriptInterrupt struct {
+	reason string
+}
+
+func (self scriptInterrupt) Error() string {
+	return self.reason
+}
+
+// guard runs f, which executes otto code, and interrupts it if it runs longer than the script timeout.
+// Only the outermost guard arms the watchdog, so nested script calls share one budget.
+func (self *Client) guard(f func() error) (err error) {
+	self.lock.RLock()
+	timeout := self.scriptTimeout
+	self.lock.RUnlock()
+	return self.guardFor(timeout, f)
+}
+
+// guardFor is guard with a budget of timeout instead of the script timeout.
+func (self *Client) guardFor(timeout time.Duration, f func() error) (err error) {
+	self.scriptDepth++
+	defer func() {
+		self.scriptDepth--
+	}()
+	if self.scriptDepth > 1 {
+		return f()
+	}
+	self.interruptLock.Lock()
+	self.scriptRunning = true
+	self.interruptLock.Unlock()
+	watchdog := time.AfterFunc(timeout, func() {
+		self.interrupt(fmt.Sprintf("script interrupted after %v", timeout))
+	})
+	defer func() {
+		watchdog.Stop()
+		self.interruptLock.Lock()
+		self.scriptRunning = false
+		select {
+		case <-self.ot.Interrupt:
+		default:
+		}
+		self.interruptLock.Unlock()
+		if e := recover(); e != nil {
+			if interrupted, ok := e.(scriptInterrupt); ok {
+				err = interrupted
+				return
+			}
+			err = fmt.Errorf("%v", e)
+		}
+	}()
+	return f()
+}
+
+// interrupt aborts the currently running script, if any.
+func (self *Client) interrupt(reason string) bool {
+	self.interruptLock.Lock()
+	defer self.interruptLock.Unlock()
+	if !self.scriptRunning {
+		return false
+	}
+	select {
+	case self.ot.Interrupt <- func() {
+		panic(scriptInterrupt{reason})
+	}:
+	default:
+	}
+	return true
+}
+
+func (self *Client) ctrlx(g *gocui.Gui, v *gocui.View) error {
+	if !self.interrupt("script interrupted by user") {
+		self.OutputNoticef("No script running\n")
+	}
+	return nil
+}
+
+func (self *Client) bindInterrupt() {
+	self.bind("scriptTimeout(seconds)", "Get or set how long a script may run before being interrupted.", func(call otto.FunctionCall) (result otto.Value) {
+		self.lock.Lock()
+		defer self.lock.Unlock()
+		if arg := call.Argument(0); arg.IsDefined() {
+			seconds, err := arg.ToFloat()
+			if err != nil || seconds <= 0 {
+				result, _ = otto.ToValue(fmt.Errorf("Invalid script timeout %#v", arg.String()))
+				return
+			}
+			self.scriptTimeout = time.Duration(seconds * float64(time.Second))
+		}
+		result, _ = otto.ToValue(self.scriptTimeout.Seconds())
+		return
+	})
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// spin runs until interrupted, checking the interrupt channel like the script engine does between statements.
+func spin(c *Client) func() error {
+	return func() error {
+		for {
+			select {
+			case f := <-c.ot.Interrupt:
+				f()
+			default:
+			}
+		}
+	}
+}
+
+func TestGuardTimesOut(t *testing.T) {
+	c := New()
+	err := c.guardFor(50*time.Millisecond, spin(c))
+	if _, ok := err.(scriptInterrupt); !ok || err.Error() != "script interrupted after 50ms" {
+		t.Fatalf("Got %#v, want an interruption after 50ms", err)
+	}
+	// The interpreter is usable afterwards, and no stale interrupt is left for the next script.
+	if err := c.guard(func() error { return nil }); err != nil {
+		t.Errorf("Guard after an interruption returned %v", err)
+	}
+	if len(c.ot.Interrupt) != 0 {
+		t.Errorf("An interrupt was left behind")
+	}
+	if c.interrupt("late") {
+		t.Errorf("Interrupted with no script running")
+	}
+}
+
+func TestGuardInterruptedByUser(t *testing.T) {
+	c := New()
+	go func() {
+		for !c.interrupt("script interrupted by user") {
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	if err := c.guardFor(time.Hour, spin(c)); err == nil || err.Error() != "script interrupted by user" {
+		t.Fatalf("Got %v, want an interruption by the user", err)
+	}
+}
+
+func TestGuardNested(t *testing.T) {
+	c := New()
+	err := c.guardFor(50*time.Millisecond, func() error {
+		// A nested script shares the budget of the outermost one instead of getting one of its own.
+		return c.guardFor(time.Hour, spin(c))
+	})
+	if _, ok := err.(scriptInterrupt); !ok {
+		t.Fatalf("Got %#v, want an interruption", err)
+	}
+}
+
+func TestGuardPanic(t *testing.T) {
+	c := New()
+	if err := c.guard(func() error { panic("boom") }); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Got %v, want the panic as an error", err)
+	}
+}
+
+// The tests below evaluate JavaScript, and need the real script engine.
+
+func TestScriptInfiniteLoop(t *testing.T) {
+	c, _ := startHeadless(t)
+	if err := c.Input("/scriptTimeout(0.2)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Input("/while (true) {}"); err != nil {
+		t.Fatal(err)
+	}
+	waitOutput(t, c, "script interrupted after 200ms")
+	if err := c.Input(`/echo("still alive")`); err != nil {
+		t.Fatal(err)
+	}
+	waitOutput(t, c, "still alive")
+}
+
+func TestScriptInfiniteLoopInTrigger(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := attachServer(t, c)
+	if err := c.Input("/scriptTimeout(0.2)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Input(`/addTrigger("^loop$", function() { while (true) {} })`); err != nil {
+		t.Fatal(err)
+	}
+	server.send("loop", "after the loop")
+	waitOutput(t, c, "script interrupted after 200ms")
+	waitOutput(t, c, "after the loop")
+}
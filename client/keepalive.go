@@ -0,0 +1,87 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+const (
+	keepAlivePeriod    = 15 * time.Second
+	writeTimeout       = 10 * time.Second
+	defaultDeadTimeout = 5 * time.Minute
+)
+
+// enableKeepAlive makes the OS probe an otherwise silent peer, so a vanished network surfaces as a read error within a minute or so.
+func enableKeepAlive(conn net.Conn) {
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.SetKeepAlive(true)
+		tcpConn.SetKeepAlivePeriod(keepAlivePeriod)
+	}
+}
+
+// writeDeadlined writes b to w, failing instead of blocking forever when the send buffer of a connection stays full.
+func writeDeadlined(w io.Writer, b []byte) (err error) {
+	if conn, ok := w.(writeDeadliner); ok {
+		conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	}
+	_, err = w.Write(b)
+	return
+}
+
+// refreshReadDeadline pushes the read deadline of conn the dead timeout into the future, or removes it if there is none.
+func (self *session) refreshReadDeadline(c Conn) {
+	conn, ok := c.(readDeadliner)
+	if !ok {
+		return
+	}
+	self.client.lock.RLock()
+	timeout := self.client.deadTimeout
+	self.client.lock.RUnlock()
+	if timeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+	} else {
+		conn.SetReadDeadline(time.Time{})
+	}
+}
+
+// describeReadError replaces the timeout of a read deadline with something a user understands.
+func (self *session) describeReadError(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		self.client.lock.RLock()
+		timeout := self.client.deadTimeout
+		self.client.lock.RUnlock()
+		return fmt.Errorf("Connection appears dead, nothing received for %v", timeout)
+	}
+	return err
+}
+
+func (self *Client) bindDeadTimeout() {
+	self.bind("deadtimeout(seconds)", "Get or set how long a connection may stay silent before it is considered dead and dropped, triggering autoreconnect if enabled. Defaults to 300, 0 waits forever.", func(call otto.FunctionCall) (result otto.Value) {
+		if arg := call.Argument(0); arg.IsDefined() {
+			seconds, err := arg.ToFloat()
+			if err != nil || seconds < 0 {
+				result, _ = otto.ToValue(fmt.Errorf("Invalid dead timeout %#v", arg.String()))
+				return
+			}
+			self.lock.Lock()
+			self.deadTimeout = time.Duration(seconds * float64(time.Second))
+			sessions := self.sessions
+			self.lock.Unlock()
+			for _, sess := range sessions {
+				if conn := sess.getConn(); conn != nil {
+					sess.refreshReadDeadline(conn)
+				}
+			}
+		}
+		self.lock.RLock()
+		defer self.lock.RUnlock()
+		result, _ = otto.ToValue(self.deadTimeout.Seconds())
+		return
+	})
+}
@@ -0,0 +1,250 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+	"github.com/zond/gocui"
+)
+
+var namedKeys = map[string]gocui.Key{
+	"tab":       gocui.KeyTab,
+	"enter":     gocui.KeyEnter,
+	"esc":       gocui.KeyEsc,
+	"space":     gocui.KeySpace,
+	"backspace": gocui.KeyBackspace2,
+	"insert":    gocui.KeyInsert,
+	"delete":    gocui.KeyDelete,
+	"home":      gocui.KeyHome,
+	"end":       gocui.KeyEnd,
+	"pgup":      gocui.KeyPgup,
+	"pgdn":      gocui.KeyPgdn,
+	"up":        gocui.KeyArrowUp,
+	"down":      gocui.KeyArrowDown,
+	"left":      gocui.KeyArrowLeft,
+	"right":     gocui.KeyArrowRight,
+}
+
+var functionKeys = []gocui.Key{
+	gocui.KeyF1, gocui.KeyF2, gocui.KeyF3, gocui.KeyF4, gocui.KeyF5, gocui.KeyF6,
+	gocui.KeyF7, gocui.KeyF8, gocui.KeyF9, gocui.KeyF10, gocui.KeyF11, gocui.KeyF12,
+}
+
+// keypadKeys maps the final byte of the ESC O sequences keypad keys send in application keypad mode to their names.
+var keypadKeys = map[rune]string{
+	'p': "0", 'q': "1", 'r': "2", 's': "3", 't': "4", 'u': "5", 'v': "6", 'w': "7", 'x': "8", 'y': "9",
+	'k': "+", 'm': "-", 'j': "*", 'o': "/", 'n': ".", 'M': "enter",
+}
+
+// keySpec is a parsed key description like "ctrl-t", "alt-x", "f5" or "keypad-8".
+type keySpec struct {
+	key    interface{}
+	mod    gocui.Modifier
+	keypad string
+}
+
+// sig identifies the key the way gocui sees it, so that different names for the same key collide.
+func (self keySpec) sig() string {
+	if self.keypad != "" {
+		return "keypad-" + self.keypad
+	}
+	return fmt.Sprintf("%v/%v", self.key, self.mod)
+}
+
+func parseKeySpec(spec string) (result keySpec, err error) {
+	spec = strings.ToLower(spec)
+	switch {
+	case strings.HasPrefix(spec, "keypad-"):
+		name := spec[len("keypad-"):]
+		for _, known := range keypadKeys {
+			if name == known {
+				result.keypad = name
+				return
+			}
+		}
+	case strings.HasPrefix(spec, "ctrl-"):
+		if name := spec[len("ctrl-"):]; len(name) == 1 && name[0] >= 'a' && name[0] <= 'z' {
+			result.key = gocui.KeyCtrlA + gocui.Key(name[0]-'a')
+			return
+		}
+	case strings.HasPrefix(spec, "alt-"):
+		if name := []rune(spec[len("alt-"):]); len(name) == 1 {
+			result.key, result.mod = name[0], gocui.ModAlt
+			return
+		}
+	case strings.HasPrefix(spec, "f"):
+		if n, e := strconv.Atoi(spec[1:]); e == nil && n >= 1 && n <= len(functionKeys) {
+			result.key = functionKeys[n-1]
+			return
+		}
+	}
+	if key, found := namedKeys[spec]; found {
+		result.key = key
+		return
+	}
+	err = fmt.Errorf("Unknown key %#v", spec)
+	return
+}
+
+// keyMacro is what a script bound to a key: either a function to call or a command to run.
+type keyMacro struct {
+	spec  string
+	fn    otto.Value
+	text  string
+	owner string
+}
+
+func (self *keyMacro) String() string {
+	if self.fn.IsFunction() {
+		return "<function>"
+	}
+	return self.text
+}
+
+// runKeyMacro runs the macro bound to sig, if any, the same way typed commands and triggers are run, and returns whether there was one.
+func (self *Client) runKeyMacro(sig string) bool {
+	self.lock.RLock()
+	macro, found := self.keyMacros[sig]
+	self.lock.RUnlock()
+	if !found {
+		return false
+	}
+	self.scriptLock.Lock()
+	defer self.scriptLock.Unlock()
+	self.within(self.activeSession(), func() {
+		if macro.fn.IsFunction() {
+			if _, err := self.callScript(macro.fn, macro.spec); err != nil {
+				self.reportError(fmt.Sprintf("key %v%v", macro.spec, registeredIn(macro.owner)), err)
+			}
+		} else if err := self.command(macro.text); err != nil {
+			self.OutputErrorf("%v\n", err)
+		}
+	})
+	return true
+}
+
+// builtinKey wraps the handler of a built-in binding so that a macro forced onto the same key replaces it.
+func (self *Client) builtinKey(sig string, handler gocui.KeybindingHandler) gocui.KeybindingHandler {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		self.lock.RLock()
+		_, overridden := self.keyMacros[sig]
+		self.lock.RUnlock()
+		if overridden {
+			return nil
+		}
+		return handler(g, v)
+	}
+}
+
+// registerKey makes gocui call the macros for spec. gocui bindings can't be removed, so each key is only registered once and does
+// nothing once its macro is unbound.
+func (self *Client) registerKey(spec keySpec) {
+	sig := spec.sig()
+	if spec.keypad != "" || self.registeredKeys[sig] {
+		return
+	}
+	self.registeredKeys[sig] = true
+	self.schedule(func() {
+		self.gui.SetKeybinding("", spec.key, spec.mod, self.guiHandler(func(g *gocui.Gui, v *gocui.View) error {
+			self.runKeyMacro(sig)
+			return nil
+		}))
+	})
+}
+
+// keypadEscape notes that the terminal sent ESC O, which reaches us as Alt-O, so that the keypad key it starts can be
+// picked out of the input view once its final byte has been typed there.
+func (self *Client) keypadEscape(g *gocui.Gui, v *gocui.View) error {
+	if input := g.View("input"); input != nil {
+		self.keypadLine = new(string)
+		*self.keypadLine, _ = input.Line(0)
+	}
+	return nil
+}
+
+func (self *Client) finishKeypad(v *gocui.View) {
+	if self.keypadLine == nil {
+		return
+	}
+	if line, _ := v.Line(0); line == *self.keypadLine {
+		return
+	}
+	self.keypadLine = nil
+	runes, pos := inputState(v)
+	if pos == 0 {
+		return
+	}
+	name, found := keypadKeys[runes[pos-1]]
+	if !found {
+		return
+	}
+	setInputState(v, append(runes[:pos-1:pos-1], runes[pos:]...), pos-1)
+	self.keypadKey(name)
+}
+
+func (self *Client) bindKeys() {
+	self.bind("bindKey(key, fn, options)", "Call fn(key), or run fn as a command if it is a string, when key is pressed. Keys look like \"f5\", \"ctrl-t\", \"alt-x\", \"keypad-8\", \"tab\" or \"pgup\". Options: {force} to replace a built-in binding.", func(call otto.FunctionCall) (result otto.Value) {
+		spec, err := parseKeySpec(call.Argument(0).String())
+		if err != nil {
+			result, _ = otto.ToValue(err)
+			return
+		}
+		force := false
+		if opts := call.Argument(2); opts.IsObject() {
+			v, _ := opts.Object().Get("force")
+			force, _ = v.ToBoolean()
+		}
+		sig := spec.sig()
+		if name, builtin := self.builtinKeys[sig]; builtin && !force {
+			result, _ = otto.ToValue(fmt.Errorf("%v is a built-in key (%v), use {force:true} to replace it", call.Argument(0).String(), name))
+			return
+		}
+		macro := &keyMacro{
+			spec:  strings.ToLower(call.Argument(0).String()),
+			owner: self.currentOwner(),
+		}
+		if arg := call.Argument(1); arg.IsFunction() {
+			macro.fn = arg
+		} else {
+			macro.text = arg.String()
+		}
+		self.lock.Lock()
+		self.keyMacros[sig] = macro
+		self.lock.Unlock()
+		self.registerKey(spec)
+		return
+	})
+	self.bind("unbindKey(key)", "Remove the macro bound to key, restoring any built-in binding it replaced.", func(call otto.FunctionCall) (result otto.Value) {
+		spec, err := parseKeySpec(call.Argument(0).String())
+		if err != nil {
+			result, _ = otto.ToValue(err)
+			return
+		}
+		self.lock.Lock()
+		delete(self.keyMacros, spec.sig())
+		self.lock.Unlock()
+		return
+	})
+	self.bind("keys()", "List key macros.", func(call otto.FunctionCall) (result otto.Value) {
+		self.lock.RLock()
+		macros := make([]*keyMacro, 0, len(self.keyMacros))
+		for _, macro := range self.keyMacros {
+			macros = append(macros, macro)
+		}
+		self.lock.RUnlock()
+		sort.Slice(macros, func(i, j int) bool {
+			return macros[i].spec < macros[j].spec
+		})
+		items := []map[string]interface{}{}
+		for _, macro := range macros {
+			items = append(items, map[string]interface{}{
+				"key":    macro.spec,
+				"action": macro.String(),
+			})
+		}
+		return self.jsArray(items)
+	})
+}
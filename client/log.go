@@ -0,0 +1,246 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+const (
+	logQueueSize       = 1024
+	logFlushInterval   = time.Second
+	logTimestampFormat = "2006-01-02 15:04:05 "
+)
+
+type logOptions struct {
+	raw        bool
+	timestamps bool
+}
+
+func parseLogOptions(value otto.Value) (result logOptions, err error) {
+	if !value.IsObject() {
+		return
+	}
+	obj := value.Object()
+	for _, key := range obj.Keys() {
+		v, _ := obj.Get(key)
+		switch key {
+		case "raw":
+			result.raw, err = v.ToBoolean()
+		case "timestamps":
+			result.timestamps, err = v.ToBoolean()
+		default:
+			err = fmt.Errorf("Unknown log option %#v", key)
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// logger appends to a file from its own goroutine, so that writers never block on the disk. When the queue is full lines are
+// dropped and counted, and the first write error closes the log and is reported through onError.
+type logger struct {
+	lock    sync.Mutex
+	path    string
+	opts    logOptions
+	file    *os.File
+	queue   chan []byte
+	done    chan struct{}
+	closed  bool
+	dropped int
+	onError func(error)
+}
+
+func openLog(path string, opts logOptions, onError func(error)) (result *logger, err error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return
+	}
+	result = &logger{
+		path:    path,
+		opts:    opts,
+		file:    file,
+		queue:   make(chan []byte, logQueueSize),
+		done:    make(chan struct{}),
+		onError: onError,
+	}
+	go result.run()
+	return
+}
+
+func (self *logger) enqueue(b []byte) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.closed {
+		return
+	}
+	select {
+	case self.queue <- b:
+	default:
+		self.dropped++
+	}
+}
+
+// line logs a received or sent line in plain mode, and a sent line in raw mode.
+func (self *logger) line(s string, sent bool) {
+	if self.opts.raw && !sent {
+		return
+	}
+	if self.opts.timestamps {
+		s = time.Now().Format(logTimestampFormat) + s
+	}
+	self.enqueue([]byte(s + "\n"))
+}
+
+// data logs bytes as received in raw mode.
+func (self *logger) data(b []byte) {
+	if self.opts.raw {
+		self.enqueue(append([]byte{}, b...))
+	}
+}
+
+func (self *logger) shut() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if !self.closed {
+		self.closed = true
+		close(self.queue)
+	}
+}
+
+func (self *logger) close() {
+	self.shut()
+	<-self.done
+}
+
+func (self *logger) run() {
+	defer close(self.done)
+	writer := bufio.NewWriter(self.file)
+	ticker := time.NewTicker(logFlushInterval)
+	defer ticker.Stop()
+	fail := func(err error) {
+		self.shut()
+		self.file.Close()
+		self.onError(err)
+		for range self.queue {
+		}
+	}
+	for {
+		select {
+		case b, ok := <-self.queue:
+			if !ok {
+				if err := writer.Flush(); err != nil {
+					self.file.Close()
+					self.onError(err)
+					return
+				}
+				self.file.Close()
+				return
+			}
+			self.lock.Lock()
+			dropped := self.dropped
+			self.dropped = 0
+			self.lock.Unlock()
+			if dropped > 0 {
+				fmt.Fprintf(writer, "[%v writes not logged, queue full]\n", dropped)
+			}
+			if _, err := writer.Write(b); err != nil {
+				fail(err)
+				return
+			}
+		case <-ticker.C:
+			if err := writer.Flush(); err != nil {
+				fail(err)
+				return
+			}
+		}
+	}
+}
+
+func (self *session) getLogger() *logger {
+	self.client.lock.RLock()
+	defer self.client.lock.RUnlock()
+	return self.logger
+}
+
+func (self *session) startLog(path string, opts logOptions) (err error) {
+	self.stopLog()
+	var l *logger
+	l, err = openLog(path, opts, func(err error) {
+		self.client.lock.Lock()
+		if self.logger == l {
+			self.logger, self.autologPath = nil, ""
+		}
+		self.client.lock.Unlock()
+		self.outputErrorf("Logging to %#v stopped: %v\n", path, err)
+	})
+	if err != nil {
+		return
+	}
+	self.client.lock.Lock()
+	self.logger = l
+	self.client.lock.Unlock()
+	return
+}
+
+func (self *session) stopLog() {
+	self.client.lock.Lock()
+	l := self.logger
+	self.logger, self.autologPath = nil, ""
+	self.client.lock.Unlock()
+	if l != nil {
+		l.close()
+	}
+}
+
+func (self *session) logLine(s string, sent bool) {
+	if l := self.getLogger(); l != nil {
+		l.line(s, sent)
+	}
+}
+
+func (self *session) logData(b []byte) {
+	if l := self.getLogger(); l != nil {
+		l.data(b)
+	}
+}
+
+func (self *Client) bindLog() {
+	self.bind("log(path, options)", "Append the received and echoed lines of the current session to path, or stop logging with log(false). Options: {raw, timestamps}, where raw logs the bytes as received.", func(call otto.FunctionCall) (result otto.Value) {
+		sess := self.target()
+		arg := call.Argument(0)
+		if !arg.IsDefined() {
+			if l := sess.getLogger(); l != nil {
+				result, _ = otto.ToValue(fmt.Sprintf("Logging to %#v", l.path))
+			} else {
+				result, _ = otto.ToValue("Not logging")
+			}
+			return
+		}
+		if b, _ := arg.ToBoolean(); arg.IsNull() || (arg.IsBoolean() && !b) {
+			sess.stopLog()
+			result, _ = otto.ToValue("Logging stopped")
+			return
+		}
+		opts, err := parseLogOptions(call.Argument(1))
+		if err != nil {
+			result, _ = otto.ToValue(err)
+			return
+		}
+		path := self.resolvePath(arg.String())
+		sess.logLock.Lock()
+		defer sess.logLock.Unlock()
+		if err := sess.startLog(path, opts); err != nil {
+			result, _ = otto.ToValue(fmt.Errorf("Unable to log to %#v: %v", path, err))
+			return
+		}
+		result, _ = otto.ToValue(fmt.Sprintf("Logging to %#v", path))
+		return
+	})
+}
@@ -0,0 +1,516 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/robertkrimen/otto"
+	"github.com/zond/gocui"
+)
+
+const (
+	mapFileName = "map.json"
+	mapWidth    = 32
+	// mapExitsWindow is how many lines after a room name its exits line may come.
+	mapExitsWindow = 10
+	// maxPendingMoves is how many sent movement commands may wait for the rooms they lead to.
+	maxPendingMoves = 20
+)
+
+// mapDirections are the movement commands the mapper notices, with where they are drawn around the current room.
+var mapDirections = map[string][2]int{
+	"n": {0, -1}, "north": {0, -1},
+	"s": {0, 1}, "south": {0, 1},
+	"e": {1, 0}, "east": {1, 0},
+	"w": {-1, 0}, "west": {-1, 0},
+	"ne": {1, -1}, "northeast": {1, -1},
+	"nw": {-1, -1}, "northwest": {-1, -1},
+	"se": {1, 1}, "southeast": {1, 1},
+	"sw": {-1, 1}, "southwest": {-1, 1},
+	"u": {0, 0}, "up": {0, 0},
+	"d": {0, 0}, "down": {0, 0},
+}
+
+type mapRoom struct {
+	Id    int    `json:"id"`
+	Name  string `json:"name"`
+	Exits string `json:"exits"`
+	// Links are the rooms the movement commands lead to.
+	Links map[string]int `json:"links"`
+}
+
+// roomMap is the graph of rooms the mapper has seen. Lines are matched on the script goroutine, moves are noticed wherever commands
+// are sent and the map view is drawn by layout, so it has its own lock.
+type roomMap struct {
+	lock   sync.Mutex
+	path   string
+	Rooms  map[int]*mapRoom `json:"rooms"`
+	NextId int              `json:"nextId"`
+	// current is the id of the room the player is in, or 0 if unknown.
+	current      int
+	roomPattern  *regexp.Regexp
+	exitsPattern *regexp.Regexp
+	// session names the session being mapped, whose commands and lines are the only ones that move the player around the map.
+	session string
+	// pendingName is the name of a room whose exits line hasn't arrived yet, seen lines ago.
+	pendingName string
+	seen        int
+	moves       []string
+	visible     bool
+}
+
+// loadMap reads the map at path, or returns an empty one and a warning if it can't.
+func loadMap(path string) (result *roomMap, warning error) {
+	result = &roomMap{
+		path:  path,
+		Rooms: map[int]*mapRoom{},
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			warning = fmt.Errorf("Unable to read %v, starting with an empty map: %v", path, err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		result.Rooms, result.NextId = map[int]*mapRoom{}, 0
+		warning = fmt.Errorf("Unable to parse %v, starting with an empty map: %v", path, err)
+	}
+	for _, room := range result.Rooms {
+		if room.Links == nil {
+			room.Links = map[string]int{}
+		}
+	}
+	return
+}
+
+// save writes the map. It must be called with the map lock held.
+func (self *roomMap) save() error {
+	data, err := json.MarshalIndent(self, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(self.path, data, 0600)
+}
+
+// moved notes a command sent in sess if it is a movement, so the next room seen is linked to the current one with it.
+func (self *roomMap) moved(sess *session, command string) {
+	command = strings.ToLower(strings.TrimSpace(command))
+	if _, found := mapDirections[command]; !found {
+		return
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.roomPattern == nil || sess.name != self.session {
+		return
+	}
+	self.moves = append(self.moves, command)
+	if len(self.moves) > maxPendingMoves {
+		self.moves = self.moves[len(self.moves)-maxPendingMoves:]
+	}
+}
+
+// see looks for room names and exits in a line received in sess, and returns an error if the map couldn't be saved after it changed.
+func (self *roomMap) see(sess *session, line string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.roomPattern == nil || sess.name != self.session {
+		return nil
+	}
+	if match := self.roomPattern.FindStringSubmatch(line); match != nil {
+		name := match[0]
+		if i := self.roomPattern.SubexpIndex("name"); i > 0 {
+			name = match[i]
+		} else if len(match) > 1 {
+			name = match[1]
+		}
+		if self.exitsPattern == nil {
+			return self.enter(strings.TrimSpace(name), "")
+		}
+		self.pendingName, self.seen = strings.TrimSpace(name), 0
+		return nil
+	}
+	if self.pendingName == "" {
+		return nil
+	}
+	if match := self.exitsPattern.FindStringSubmatch(line); match != nil {
+		exits := match[0]
+		if len(match) > 1 {
+			exits = match[1]
+		}
+		name := self.pendingName
+		self.pendingName = ""
+		return self.enter(name, strings.TrimSpace(exits))
+	}
+	if self.seen++; self.seen > mapExitsWindow {
+		self.pendingName = ""
+	}
+	return nil
+}
+
+// enter makes the room with name and exits current, creating it if it is new and linking it to the previous room with the oldest
+// pending move. It must be called with the map lock held.
+func (self *roomMap) enter(name, exits string) error {
+	var room *mapRoom
+	for _, id := range self.sortedIds() {
+		if candidate := self.Rooms[id]; candidate.Name == name && candidate.Exits == exits {
+			room = candidate
+			break
+		}
+	}
+	changed := false
+	if room == nil {
+		self.NextId++
+		room = &mapRoom{
+			Id:    self.NextId,
+			Name:  name,
+			Exits: exits,
+			Links: map[string]int{},
+		}
+		self.Rooms[room.Id] = room
+		changed = true
+	}
+	if len(self.moves) > 0 {
+		move := self.moves[0]
+		self.moves = self.moves[1:]
+		if previous := self.Rooms[self.current]; previous != nil && previous.Links[move] != room.Id {
+			previous.Links[move] = room.Id
+			changed = true
+		}
+	}
+	self.current = room.Id
+	if changed {
+		return self.save()
+	}
+	return nil
+}
+
+func (self *roomMap) sortedIds() (result []int) {
+	for id := range self.Rooms {
+		result = append(result, id)
+	}
+	sort.Ints(result)
+	return
+}
+
+// find returns the ids of the rooms with the id or, case insensitively, the name in ref. It must be called with the map lock held.
+func (self *roomMap) find(ref otto.Value) (result []int) {
+	if ref.IsNumber() {
+		id, _ := ref.ToInteger()
+		if self.Rooms[int(id)] != nil {
+			result = append(result, int(id))
+		}
+		return
+	}
+	name := ref.String()
+	if id, err := strconv.Atoi(name); err == nil && self.Rooms[id] != nil {
+		return []int{id}
+	}
+	for _, id := range self.sortedIds() {
+		if strings.EqualFold(self.Rooms[id].Name, name) {
+			result = append(result, id)
+		}
+	}
+	return
+}
+
+// findOne returns the id of the single room ref refers to. It must be called with the map lock held.
+func (self *roomMap) findOne(ref otto.Value) (id int, err error) {
+	ids := self.find(ref)
+	switch len(ids) {
+	case 0:
+		err = fmt.Errorf("No room %#v on the map", ref.String())
+	case 1:
+		id = ids[0]
+	default:
+		err = fmt.Errorf("%v rooms are named %#v, use one of the ids %v", len(ids), ref.String(), ids)
+	}
+	return
+}
+
+// shortestPath returns the movement commands leading from the current room to the nearest of targets by a breadth first search.
+// It must be called with the map lock held.
+func (self *roomMap) shortestPath(targets []int) (result []string, found bool) {
+	goal := map[int]bool{}
+	for _, id := range targets {
+		goal[id] = true
+	}
+	type step struct {
+		from    int
+		command string
+	}
+	visited := map[int]step{self.current: {}}
+	queue := []int{self.current}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if goal[id] {
+			for id != self.current {
+				result = append([]string{visited[id].command}, result...)
+				id = visited[id].from
+			}
+			return result, true
+		}
+		room := self.Rooms[id]
+		if room == nil {
+			continue
+		}
+		commands := []string{}
+		for command := range room.Links {
+			commands = append(commands, command)
+		}
+		sort.Strings(commands)
+		for _, command := range commands {
+			next := room.Links[command]
+			if _, seen := visited[next]; !seen && self.Rooms[next] != nil {
+				visited[next] = step{from: id, command: command}
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil, false
+}
+
+// merge moves the links of the room with id from into the one with id into, and points the links to it there too.
+func (self *roomMap) merge(into, from int) {
+	for command, target := range self.Rooms[from].Links {
+		if _, found := self.Rooms[into].Links[command]; !found {
+			self.Rooms[into].Links[command] = target
+		}
+	}
+	delete(self.Rooms, from)
+	for _, room := range self.Rooms {
+		for command, target := range room.Links {
+			if target == from {
+				room.Links[command] = into
+			}
+		}
+	}
+	if self.current == from {
+		self.current = into
+	}
+}
+
+// remove forgets the room with id and the links to it.
+func (self *roomMap) remove(id int) {
+	delete(self.Rooms, id)
+	for _, room := range self.Rooms {
+		for command, target := range room.Links {
+			if target == id {
+				delete(room.Links, command)
+			}
+		}
+	}
+	if self.current == id {
+		self.current = 0
+	}
+}
+
+// render draws the rooms around the current one in a compass grid, followed by where each link of the current room leads.
+func (self *roomMap) render() string {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	room := self.Rooms[self.current]
+	if room == nil {
+		return "Unknown room"
+	}
+	grid := [3][3]string{}
+	for y := range grid {
+		for x := range grid[y] {
+			grid[y][x] = " "
+		}
+	}
+	grid[1][1] = "@"
+	extra := ""
+	for command, target := range room.Links {
+		offset := mapDirections[command]
+		if self.Rooms[target] == nil {
+			continue
+		}
+		if offset == [2]int{} {
+			if !strings.Contains(extra, command[:1]) {
+				extra += command[:1]
+			}
+			continue
+		}
+		grid[1+offset[1]][1+offset[0]] = "#"
+	}
+	lines := []string{}
+	for y, row := range grid {
+		line := "  " + strings.Join(row[:], " ")
+		if y == 1 && extra != "" {
+			line += "  " + extra
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "", room.Name)
+	commands := []string{}
+	for command := range room.Links {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+	for _, command := range commands {
+		if target := self.Rooms[room.Links[command]]; target != nil {
+			lines = append(lines, fmt.Sprintf("%v: %v", command, target.Name))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (self *roomMap) shown() bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.visible
+}
+
+// layoutMap shows the map view at the right edge between top and bottom while it is toggled on, and returns the rightmost column
+// left for the output.
+func (self *Client) layoutMap(g *gocui.Gui, maxX, top, bottom int) (right int, err error) {
+	right = maxX - 1
+	if self.roomMap == nil || !self.roomMap.shown() || maxX < 3*mapWidth {
+		if g.View("map") != nil {
+			err = g.DeleteView("map")
+		}
+		return
+	}
+	right = maxX - 1 - mapWidth
+	v, err := g.SetView("map", right+1, top, maxX-1, bottom)
+	if err != nil && err != gocui.ErrorUnkView {
+		return
+	}
+	err = nil
+	v.Title = "Map"
+	v.Clear()
+	fmt.Fprint(v, self.roomMap.render())
+	return
+}
+
+func (self *Client) bindMapper() {
+	var warning error
+	self.roomMap, warning = loadMap(filepath.Join(mugDir(), mapFileName))
+	if warning != nil {
+		self.OutputErrorf("%v\n", warning)
+	}
+	m := self.roomMap
+	obj, _ := self.ot.Object("({})")
+	self.bindMethod(obj, "mapper", "roomTrigger(pattern, exitsPattern)", "Start mapping the current session, recognizing rooms by lines matching pattern, with the room name in the group called name or the first group, and the exits line following it by exitsPattern, with the exits in its first group. Rooms are told apart by name and exits, and linked by the movement commands sent to walk between them.", func(call otto.FunctionCall) (result otto.Value) {
+		pattern, err := regexp.Compile(call.Argument(0).String())
+		if err != nil {
+			result, _ = otto.ToValue(fmt.Errorf("Invalid room pattern %#v: %v", call.Argument(0).String(), err))
+			return
+		}
+		var exits *regexp.Regexp
+		if arg := call.Argument(1); arg.IsDefined() {
+			if exits, err = regexp.Compile(arg.String()); err != nil {
+				result, _ = otto.ToValue(fmt.Errorf("Invalid exits pattern %#v: %v", arg.String(), err))
+				return
+			}
+		}
+		sess := self.target()
+		m.lock.Lock()
+		m.roomPattern, m.exitsPattern, m.pendingName, m.moves = pattern, exits, "", nil
+		m.session = sess.name
+		m.lock.Unlock()
+		return
+	})
+	self.bindMethod(obj, "mapper", "path(room, options)", "Return the shortest known list of movement commands from the current room to room, given by name or id. With {walk:true} they are also sent as a speedwalk.", func(call otto.FunctionCall) (result otto.Value) {
+		m.lock.Lock()
+		targets := m.find(call.Argument(0))
+		steps, found := m.shortestPath(targets)
+		current := m.Rooms[m.current]
+		m.lock.Unlock()
+		switch {
+		case len(targets) == 0:
+			result, _ = otto.ToValue(fmt.Errorf("No room %#v on the map", call.Argument(0).String()))
+			return
+		case current == nil:
+			result, _ = otto.ToValue(fmt.Errorf("Current room unknown"))
+			return
+		case !found:
+			result, _ = otto.ToValue(fmt.Errorf("No known way from %#v to %#v", current.Name, call.Argument(0).String()))
+			return
+		}
+		if opts := call.Argument(1); opts.IsObject() && len(steps) > 0 {
+			if v, _ := opts.Object().Get("walk"); v.IsDefined() {
+				if walk, _ := v.ToBoolean(); walk {
+					if err := self.speedwalk(steps); err != nil {
+						result, _ = otto.ToValue(err)
+						return
+					}
+				}
+			}
+		}
+		result, _ = self.ot.ToValue(steps)
+		return
+	})
+	self.bindMethod(obj, "mapper", "merge(a, b)", "Merge room b into room a, given by name or id, when one room was mistaken for two.", func(call otto.FunctionCall) (result otto.Value) {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		a, err := m.findOne(call.Argument(0))
+		if err == nil {
+			var b int
+			if b, err = m.findOne(call.Argument(1)); err == nil && a == b {
+				err = fmt.Errorf("Unable to merge room %v into itself", a)
+			} else if err == nil {
+				m.merge(a, b)
+				err = m.save()
+			}
+		}
+		if err != nil {
+			result, _ = otto.ToValue(err)
+		}
+		return
+	})
+	self.bindMethod(obj, "mapper", "delete(room)", "Forget a room, given by name or id, and the links to it.", func(call otto.FunctionCall) (result otto.Value) {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		id, err := m.findOne(call.Argument(0))
+		if err == nil {
+			m.remove(id)
+			err = m.save()
+		}
+		if err != nil {
+			result, _ = otto.ToValue(err)
+		}
+		return
+	})
+	self.bindMethod(obj, "mapper", "rooms()", "List the rooms on the map.", func(call otto.FunctionCall) (result otto.Value) {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		items := []map[string]interface{}{}
+		for _, id := range m.sortedIds() {
+			room := m.Rooms[id]
+			links := []string{}
+			for command, target := range room.Links {
+				links = append(links, fmt.Sprintf("%v:%v", command, target))
+			}
+			sort.Strings(links)
+			items = append(items, map[string]interface{}{
+				"id":      room.Id,
+				"name":    room.Name,
+				"exits":   room.Exits,
+				"links":   strings.Join(links, " "),
+				"current": id == m.current,
+			})
+		}
+		return self.jsArray(items)
+	})
+	self.ot.Set("mapper", obj)
+	self.bind("map()", "Show or hide the map of the rooms around the current one.", func(call otto.FunctionCall) (result otto.Value) {
+		m.lock.Lock()
+		m.visible = !m.visible
+		visible := m.visible
+		m.lock.Unlock()
+		self.redraw()
+		result, _ = otto.ToValue(visible)
+		return
+	})
+}
@@ -0,0 +1,166 @@
+package client
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/robertkrimen/otto"
+)
+
+const (
+	msdpVar        = 1
+	msdpVal        = 2
+	msdpTableOpen  = 3
+	msdpTableClose = 4
+	msdpArrayOpen  = 5
+	msdpArrayClose = 6
+)
+
+type msdpParser struct {
+	data []byte
+	pos  int
+}
+
+func parseMSDP(data []byte) map[string]interface{} {
+	p := &msdpParser{data: data}
+	return p.table(-1)
+}
+
+func (self *msdpParser) peek() int {
+	if self.pos >= len(self.data) {
+		return -1
+	}
+	return int(self.data[self.pos])
+}
+
+func (self *msdpParser) str() string {
+	start := self.pos
+	for self.pos < len(self.data) && self.data[self.pos] > msdpArrayClose {
+		self.pos++
+	}
+	return string(self.data[start:self.pos])
+}
+
+func (self *msdpParser) value() interface{} {
+	switch self.peek() {
+	case msdpTableOpen:
+		self.pos++
+		return self.table(msdpTableClose)
+	case msdpArrayOpen:
+		self.pos++
+		return self.array()
+	}
+	return self.str()
+}
+
+func (self *msdpParser) table(end int) (result map[string]interface{}) {
+	result = map[string]interface{}{}
+	for {
+		switch self.peek() {
+		case -1:
+			return
+		case end:
+			self.pos++
+			return
+		case msdpVar:
+			self.pos++
+			name := self.str()
+			var values []interface{}
+			for self.peek() == msdpVal {
+				self.pos++
+				values = append(values, self.value())
+			}
+			switch len(values) {
+			case 0:
+				result[name] = ""
+			case 1:
+				result[name] = values[0]
+			default:
+				result[name] = values
+			}
+		default:
+			self.pos++
+		}
+	}
+}
+
+func (self *msdpParser) array() (result []interface{}) {
+	result = []interface{}{}
+	for {
+		switch self.peek() {
+		case -1:
+			return
+		case msdpArrayClose:
+			self.pos++
+			return
+		case msdpVal:
+			self.pos++
+			result = append(result, self.value())
+		default:
+			self.pos++
+		}
+	}
+}
+
+func encodeMSDP(name string, values ...string) (result []byte) {
+	result = append([]byte{msdpVar}, name...)
+	for _, value := range values {
+		result = append(result, msdpVal)
+		result = append(result, value...)
+	}
+	return
+}
+
+func (self *session) receiveMSDP(data []byte) {
+	vars := parseMSDP(data)
+	self.schedule(func() {
+		for name, value := range vars {
+			if old, found := self.msdp[name]; found && reflect.DeepEqual(old, value) {
+				continue
+			}
+			self.msdp[name] = value
+			for _, handler := range self.client.msdpHandlers[name] {
+				converted, _ := self.client.ot.ToValue(value)
+				if _, err := self.client.callScript(handler.fn, converted, name); err != nil {
+					self.client.reportError(fmt.Sprintf("MSDP handler for %#v%v", name, registeredIn(handler.owner)), err)
+				}
+			}
+		}
+	})
+}
+
+func (self *Client) bindMSDP() {
+	obj, _ := self.ot.Object("({})")
+	self.bindMethod(obj, "msdp", "get(name)", "Return the last reported value of the MSDP variable name.", func(call otto.FunctionCall) (result otto.Value) {
+		if value, found := self.target().msdp[call.Argument(0).String()]; found {
+			result, _ = self.ot.ToValue(value)
+		}
+		return
+	})
+	self.bindMethod(obj, "msdp", "on(name, fn)", "Call fn(value, name) when the MSDP variable name changes.", func(call otto.FunctionCall) (result otto.Value) {
+		name := call.Argument(0).String()
+		if !call.Argument(1).IsFunction() {
+			result, _ = otto.ToValue(fmt.Errorf("Handler for %#v is not a function", name))
+			return
+		}
+		self.msdpHandlers[name] = append(self.msdpHandlers[name], &hook{
+			fn:    call.Argument(1),
+			owner: self.currentOwner(),
+		})
+		return
+	})
+	self.bindMethod(obj, "msdp", "report(names...)", "Ask the server to report changes to the MSDP variables.", func(call otto.FunctionCall) (result otto.Value) {
+		tn := self.getTelnet()
+		if tn == nil || !tn.enabled(optMSDP) {
+			result, _ = otto.ToValue(fmt.Errorf("MSDP not negotiated"))
+			return
+		}
+		names := make([]string, len(call.ArgumentList))
+		for i, arg := range call.ArgumentList {
+			names[i] = arg.String()
+		}
+		tn.sendSub(optMSDP, encodeMSDP("REPORT", names...))
+		return
+	})
+	self.ot.Set("msdp", obj)
+}
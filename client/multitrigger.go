@@ -0,0 +1,144 @@
+package client
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/robertkrimen/otto"
+)
+
+const (
+	defaultMultiWindow = 5
+	maxMultiWindow     = 100
+)
+
+// multiTrigger fires when its patterns match, in order, lines received within window consecutive lines. Each line takes part in at
+// most one firing, and the matched lines are the latest ones that fit, so a sequence that fails to complete never blocks one
+// starting at any later line.
+type multiTrigger struct {
+	id       int
+	patterns []*regexp.Regexp
+	window   int
+	callback otto.Value
+	owner    string
+	// fired is the number of the last line each session used in a firing.
+	fired map[*session]int
+}
+
+// rememberLine adds line to the recent lines of the session that multi-line triggers look at.
+func (self *session) rememberLine(line string) {
+	self.received++
+	self.recent = append(self.recent, line)
+	if len(self.recent) > maxMultiWindow {
+		self.recent = self.recent[len(self.recent)-maxMultiWindow:]
+	}
+}
+
+// match returns the indexes in recent of lines matching the patterns of the trigger and ending with the last one, or nil.
+func (self *multiTrigger) match(sess *session) (result []int) {
+	last := len(sess.recent) - 1
+	// first is the index in recent of the oldest line the trigger may use.
+	first := last - self.window + 1
+	if unused := self.fired[sess] - (sess.received - len(sess.recent)); unused > first {
+		first = unused
+	}
+	result = make([]int, len(self.patterns))
+	next := last + 1
+	for i := len(self.patterns) - 1; i >= 0; i-- {
+		found := false
+		for j := next - 1; j >= first && j >= 0; j-- {
+			if self.patterns[i].MatchString(sess.recent[j]) {
+				result[i], next, found = j, j, true
+				break
+			}
+			if i == len(self.patterns)-1 {
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	return
+}
+
+// fire returns what match returns, and marks the matched lines as used by a firing.
+func (self *multiTrigger) fire(sess *session) (result []int) {
+	if result = self.match(sess); result != nil {
+		self.fired[sess] = sess.received - len(sess.recent) + result[len(result)-1] + 1
+	}
+	return
+}
+
+// runMultiTriggers runs the multi-line triggers completed by the last line remembered by the context session.
+func (self *Client) runMultiTriggers() {
+	sess := self.target()
+	for _, t := range append([]*multiTrigger{}, self.multiTriggers...) {
+		indexes := t.fire(sess)
+		if indexes == nil {
+			continue
+		}
+		lines, _ := self.ot.Object("[]")
+		groups, _ := self.ot.Object("[]")
+		for i, index := range indexes {
+			line := sess.recent[index]
+			lines.Set(strconv.Itoa(i), line)
+			groups.Set(strconv.Itoa(i), self.groups(t.patterns[i], t.patterns[i].FindStringSubmatch(line)))
+		}
+		if _, err := self.callScript(t.callback, lines.Value(), groups.Value()); err != nil {
+			self.reportError(fmt.Sprintf("trigger %v%v", t.id, registeredIn(t.owner)), err)
+		}
+	}
+}
+
+func (self *Client) bindMultiTriggers() {
+	self.bind("addMultiTrigger(patterns, fn, options)", "Call fn(lines, groups) when the array of patterns match received lines in order, all within {window} consecutive lines (default 5), and return the trigger id. Gagged lines count too. Remove it with removeTrigger.", func(call otto.FunctionCall) (result otto.Value) {
+		arg := call.Argument(0)
+		if !arg.IsObject() || arg.Class() != "Array" {
+			result, _ = otto.ToValue(fmt.Errorf("Multi-line trigger patterns must be an array"))
+			return
+		}
+		t := &multiTrigger{
+			window:   defaultMultiWindow,
+			callback: call.Argument(1),
+			owner:    self.currentOwner(),
+			fired:    map[*session]int{},
+		}
+		for _, key := range arg.Object().Keys() {
+			v, _ := arg.Object().Get(key)
+			pattern, err := regexp.Compile(v.String())
+			if err != nil {
+				result, _ = otto.ToValue(fmt.Errorf("Invalid trigger pattern %#v: %v", v.String(), err))
+				return
+			}
+			t.patterns = append(t.patterns, pattern)
+		}
+		if len(t.patterns) == 0 {
+			result, _ = otto.ToValue(fmt.Errorf("No trigger patterns"))
+			return
+		}
+		if !t.callback.IsFunction() {
+			result, _ = otto.ToValue(fmt.Errorf("Trigger callback is not a function"))
+			return
+		}
+		if opts := call.Argument(2); opts.IsObject() {
+			if v, _ := opts.Object().Get("window"); v.IsDefined() {
+				window, err := v.ToInteger()
+				if err != nil || window < int64(len(t.patterns)) || window > maxMultiWindow {
+					result, _ = otto.ToValue(fmt.Errorf("Invalid window %#v, it has to fit all patterns and be at most %v", v.String(), maxMultiWindow))
+					return
+				}
+				t.window = int(window)
+			}
+		}
+		if t.window < len(t.patterns) {
+			t.window = len(t.patterns)
+		}
+		self.nextTriggerId++
+		t.id = self.nextTriggerId
+		self.multiTriggers = append(self.multiTriggers, t)
+		result, _ = otto.ToValue(t.id)
+		return
+	})
+}
@@ -0,0 +1,151 @@
+package client
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// feed remembers lines in sess one at a time like the line pipeline does, and returns the lines of each firing of t.
+func feed(t *multiTrigger, sess *session, lines ...string) (result [][]string) {
+	for _, line := range lines {
+		sess.rememberLine(line)
+		if indexes := t.fire(sess); indexes != nil {
+			fired := []string{}
+			for _, index := range indexes {
+				fired = append(fired, sess.recent[index])
+			}
+			result = append(result, fired)
+		}
+	}
+	return
+}
+
+func newMultiTrigger(window int, patterns ...string) (result *multiTrigger) {
+	result = &multiTrigger{window: window, fired: map[*session]int{}}
+	for _, pattern := range patterns {
+		result.patterns = append(result.patterns, regexp.MustCompile(pattern))
+	}
+	return
+}
+
+func TestMultiTrigger(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		patterns []string
+		window   int
+		lines    string
+		want     [][]string
+	}{
+		{
+			name:     "in order",
+			patterns: []string{"^die", "^corpse"},
+			window:   5,
+			lines:    "die x corpse",
+			want:     [][]string{{"die", "corpse"}},
+		},
+		{
+			name:     "out of order",
+			patterns: []string{"^die", "^corpse"},
+			window:   5,
+			lines:    "corpse die",
+		},
+		{
+			name:     "outside the window",
+			patterns: []string{"^die", "^corpse"},
+			window:   3,
+			lines:    "die x x corpse",
+		},
+		{
+			name:     "at the edge of the window",
+			patterns: []string{"^die", "^corpse"},
+			window:   3,
+			lines:    "die x corpse",
+			want:     [][]string{{"die", "corpse"}},
+		},
+		{
+			// The second start is the one that completes, since the first is too old by then.
+			name:     "failed sequence restarts at a later line",
+			patterns: []string{"^die", "^corpse"},
+			window:   3,
+			lines:    "die x die1 x corpse",
+			want:     [][]string{{"die1", "corpse"}},
+		},
+		{
+			name:     "interleaved candidates use the latest start",
+			patterns: []string{"^a", "^b"},
+			window:   5,
+			lines:    "a1 a2 b1 b2",
+			want:     [][]string{{"a2", "b1"}},
+		},
+		{
+			name:     "interleaved sequences",
+			patterns: []string{"^a", "^b"},
+			window:   5,
+			lines:    "a1 x b1 a2 b2",
+			want:     [][]string{{"a1", "b1"}, {"a2", "b2"}},
+		},
+		{
+			name:     "overlapping partial matches",
+			patterns: []string{"^a", "^a", "^b"},
+			window:   5,
+			lines:    "a1 a2 a3 b1",
+			want:     [][]string{{"a2", "a3", "b1"}},
+		},
+		{
+			name:     "lines take part in one firing",
+			patterns: []string{"^a", "^b"},
+			window:   5,
+			lines:    "a1 b1 b2 a2 b3",
+			want:     [][]string{{"a1", "b1"}, {"a2", "b3"}},
+		},
+		{
+			name:     "one line matching several patterns",
+			patterns: []string{"^ab", "b$"},
+			window:   5,
+			lines:    "ab ab",
+			want:     [][]string{{"ab", "ab"}},
+		},
+	} {
+		c := New()
+		got := feed(newMultiTrigger(tc.window, tc.patterns...), newSession(c, "test"), strings.Fields(tc.lines)...)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: %v in %q fired %q, want %q", tc.name, tc.patterns, tc.lines, got, tc.want)
+		}
+	}
+}
+
+func TestMultiTriggerSessions(t *testing.T) {
+	c := New()
+	trigger := newMultiTrigger(5, "^die", "^corpse")
+	first, second := newSession(c, "first"), newSession(c, "second")
+	if got := feed(trigger, first, "die"); got != nil {
+		t.Fatalf("Fired %q", got)
+	}
+	// A sequence started in one session is not completed by another.
+	if got := feed(trigger, second, "corpse"); got != nil {
+		t.Fatalf("Fired %q across sessions", got)
+	}
+	if got := feed(trigger, first, "corpse"); !reflect.DeepEqual(got, [][]string{{"die", "corpse"}}) {
+		t.Fatalf("Fired %q, want the sequence of the first session", got)
+	}
+}
+
+// The tests below evaluate JavaScript, and need the real script engine.
+
+func TestScriptMultiTrigger(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := attachServer(t, c)
+	if err := c.Input(`/gag("^The rat is DEAD"); id = addMultiTrigger(["^The (\\w+) is DEAD", "corpse of the (\\w+)"], function(lines, groups) { sendln("get all " + groups[1][1]); }, {window: 3})`); err != nil {
+		t.Fatal(err)
+	}
+	// The gagged line still counts toward the window.
+	server.send("The rat is DEAD!", "You gain experience.", "The corpse of the rat lies here.")
+	server.expect("get all rat")
+	if err := c.Input("/removeTrigger(id)"); err != nil {
+		t.Fatal(err)
+	}
+	server.send("The rat is DEAD!", "The corpse of the rat lies here.")
+	server.expectNothing()
+}
@@ -0,0 +1,359 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/robertkrimen/otto"
+)
+
+const (
+	optMXP = 91
+	// mxpMaxTag is the longest tag looked for. Anything longer is taken as text with a stray <.
+	mxpMaxTag    = 1024
+	mxpMaxEntity = 10
+	mxpMaxEscape = 16
+)
+
+// mxpLink is a <send> or <a> link found in MXP output.
+type mxpLink struct {
+	target  string
+	text    string
+	command bool
+}
+
+type mxpStyle struct {
+	name string
+	sgr  string
+}
+
+// mxpParser removes MXP markup from the text of a connection, turning the common formatting tags into escape sequences and
+// collecting links. Tags, entities and line mode sequences may be split between reads, and a tag or entity that isn't closed before
+// the end of the line, or grows too long, is shown as it was received instead.
+type mxpParser struct {
+	// locked is the default line mode, in which markup is just text. lineLocked is the mode of the current line.
+	locked     bool
+	lineLocked bool
+	inTag      bool
+	tag        []byte
+	inEntity   bool
+	entity     []byte
+	inEscape   bool
+	escape     []byte
+	styles     []mxpStyle
+	link       *mxpLink
+	links      []mxpLink
+}
+
+var mxpEntities = map[string]string{
+	"lt":   "<",
+	"gt":   ">",
+	"amp":  "&",
+	"quot": "\"",
+	"apos": "'",
+	"nbsp": " ",
+}
+
+func (self *mxpParser) filter(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for _, b := range data {
+		out = self.feed(out, b)
+	}
+	return out
+}
+
+func (self *mxpParser) feed(out []byte, b byte) []byte {
+	switch {
+	case self.inEscape:
+		self.escape = append(self.escape, b)
+		if len(self.escape) == 2 && b != '[' || len(self.escape) > mxpMaxEscape {
+			self.inEscape = false
+			return append(out, self.escape...)
+		}
+		if len(self.escape) > 2 && (b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z') {
+			self.inEscape = false
+			if b != 'z' {
+				return append(out, self.escape...)
+			}
+			mode, _ := strconv.Atoi(string(self.escape[2 : len(self.escape)-1]))
+			self.lineMode(mode)
+		}
+		return out
+	case self.inTag:
+		if b == '>' {
+			self.inTag = false
+			return append(out, self.handleTag(string(self.tag))...)
+		}
+		if b != '\n' && len(self.tag) < mxpMaxTag {
+			self.tag = append(self.tag, b)
+			return out
+		}
+		self.inTag = false
+		out = self.text(append(out, '<'), self.tag...)
+	case self.inEntity:
+		if b == ';' {
+			self.inEntity = false
+			if text, found := mxpEntities[strings.ToLower(string(self.entity))]; found {
+				return self.text(out, []byte(text)...)
+			}
+			return self.text(append(out, '&'), append(self.entity, ';')...)
+		}
+		if len(self.entity) < mxpMaxEntity && (b == '#' || b < unicode.MaxASCII && (unicode.IsLetter(rune(b)) || unicode.IsDigit(rune(b)))) {
+			self.entity = append(self.entity, b)
+			return out
+		}
+		self.inEntity = false
+		out = self.text(append(out, '&'), self.entity...)
+	}
+	switch {
+	case b == '\033':
+		self.inEscape = true
+		self.escape = append(self.escape[:0], b)
+		return out
+	case b == '\n':
+		out = self.endLine(out)
+		return append(out, b)
+	case self.lineLocked:
+		return append(out, b)
+	case b == '<':
+		self.inTag = true
+		self.tag = self.tag[:0]
+		return out
+	case b == '&':
+		self.inEntity = true
+		self.entity = self.entity[:0]
+		return out
+	}
+	return self.text(out, b)
+}
+
+// text appends shown text, remembering it as the text of an open link.
+func (self *mxpParser) text(out []byte, b ...byte) []byte {
+	if self.link != nil {
+		self.link.text += string(b)
+	}
+	return append(out, b...)
+}
+
+// endLine closes what was left open on the line, since MXP tags don't span lines outside of locked mode.
+func (self *mxpParser) endLine(out []byte) []byte {
+	if len(self.styles) > 0 {
+		out = append(out, ansiReset...)
+		self.styles = nil
+	}
+	self.closeLink()
+	self.lineLocked = self.locked
+	return out
+}
+
+// lineMode applies ESC[<mode>z. Modes 0, 1, 2 and 4 are for the rest of the line, 3 resets to open, and 5, 6 and 7 lock a mode.
+func (self *mxpParser) lineMode(mode int) {
+	switch mode {
+	case 0, 1, 4:
+		self.lineLocked = false
+	case 2:
+		self.lineLocked = true
+	case 3, 5, 6:
+		self.locked, self.lineLocked = false, false
+	case 7:
+		self.locked, self.lineLocked = true, true
+	}
+}
+
+// mxpAttributes splits the attributes of a tag into named ones and positional ones, removing quotes.
+func mxpAttributes(s string) (named map[string]string, positional []string) {
+	named = map[string]string{}
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		end := 0
+		for quote := byte(0); end < len(s); end++ {
+			if quote != 0 {
+				if s[end] == quote {
+					quote = 0
+				}
+			} else if s[end] == '"' || s[end] == '\'' {
+				quote = s[end]
+			} else if s[end] == ' ' || s[end] == '\t' {
+				break
+			}
+		}
+		token := s[:end]
+		s = s[end:]
+		if i := strings.Index(token, "="); i > 0 {
+			named[strings.ToLower(token[:i])] = strings.Trim(token[i+1:], "\"'")
+		} else {
+			positional = append(positional, strings.Trim(token, "\"'"))
+		}
+	}
+	return
+}
+
+// mxpColor returns the SGR code of an MXP color name or #rrggbb, approximated by the nearest of the 8 basic colors, as a foreground
+// color. Unknown colors return 0.
+func mxpColor(spec string) int {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	if code, found := ansiAttributes[spec]; found && code >= 30 {
+		return code
+	}
+	if len(spec) == 7 && spec[0] == '#' {
+		rgb, err := strconv.ParseUint(spec[1:], 16, 32)
+		if err != nil {
+			return 0
+		}
+		code := 30
+		if rgb>>16&0xff > 0x7f {
+			code += 1
+		}
+		if rgb>>8&0xff > 0x7f {
+			code += 2
+		}
+		if rgb&0xff > 0x7f {
+			code += 4
+		}
+		return code
+	}
+	return 0
+}
+
+func (self *mxpParser) handleTag(tag string) []byte {
+	tag = strings.TrimSpace(tag)
+	if tag == "" || tag[0] == '!' {
+		return nil
+	}
+	closing := tag[0] == '/'
+	tag = strings.TrimPrefix(tag, "/")
+	attrs := ""
+	name := tag
+	if i := strings.IndexAny(tag, " \t"); i != -1 {
+		name, attrs = tag[:i], tag[i+1:]
+	}
+	name = strings.ToLower(strings.TrimSuffix(name, "/"))
+	if closing {
+		if name == "send" || name == "a" {
+			self.closeLink()
+		}
+		return self.closeStyle(name)
+	}
+	sgr := ""
+	switch name {
+	case "b", "bold", "strong", "i", "italic", "em":
+		sgr = "\033[1m"
+	case "u", "underline":
+		sgr = "\033[4m"
+	case "color", "c", "font":
+		named, positional := mxpAttributes(attrs)
+		fore, back := named["fore"], named["back"]
+		if name == "font" {
+			fore, back = named["color"], named["back"]
+		}
+		if fore == "" && len(positional) > 0 {
+			fore = positional[0]
+		}
+		if back == "" && len(positional) > 1 {
+			back = positional[1]
+		}
+		codes := []string{}
+		if code := mxpColor(fore); code != 0 {
+			codes = append(codes, strconv.Itoa(code))
+		}
+		if code := mxpColor(back); code != 0 {
+			codes = append(codes, strconv.Itoa(code+10))
+		}
+		if len(codes) > 0 {
+			sgr = "\033[" + strings.Join(codes, ";") + "m"
+		}
+	case "send", "a":
+		named, positional := mxpAttributes(attrs)
+		target := named["href"]
+		if target == "" && len(positional) > 0 && name == "send" {
+			target = positional[0]
+		}
+		self.closeLink()
+		self.link = &mxpLink{
+			target:  target,
+			command: name == "send",
+		}
+		sgr = "\033[4m"
+	case "br":
+		return []byte("\n")
+	default:
+		return nil
+	}
+	self.styles = append(self.styles, mxpStyle{
+		name: name,
+		sgr:  sgr,
+	})
+	return []byte(sgr)
+}
+
+// closeStyle removes the innermost open tag called name, and returns the escape sequences restoring the styles still open.
+func (self *mxpParser) closeStyle(name string) []byte {
+	for i := len(self.styles) - 1; i >= 0; i-- {
+		if self.styles[i].name == name || (name == "c" || name == "color") && (self.styles[i].name == "c" || self.styles[i].name == "color") {
+			self.styles = append(self.styles[:i], self.styles[i+1:]...)
+			out := ansiReset
+			for _, style := range self.styles {
+				out += style.sgr
+			}
+			return []byte(out)
+		}
+	}
+	return nil
+}
+
+// closeLink finishes the open link, if any. A send without href sends its text, and &text; in the href is the text of the link.
+func (self *mxpParser) closeLink() {
+	if self.link == nil {
+		return
+	}
+	link := *self.link
+	self.link = nil
+	link.text = stripANSI(link.text)
+	if link.target == "" {
+		link.target = link.text
+	}
+	link.target = strings.Replace(link.target, "&text;", link.text, -1)
+	if i := strings.Index(link.target, "|"); i != -1 {
+		link.target = link.target[:i]
+	}
+	if link.target != "" {
+		self.links = append(self.links, link)
+	}
+}
+
+func (self *mxpParser) takeLinks() (result []mxpLink) {
+	result, self.links = self.links, nil
+	return
+}
+
+func (self *Client) mxpAllowed() bool {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.mxp
+}
+
+func (self *Client) bindMXP() {
+	self.bind("mxp(enabled)", "Get or set whether MXP markup is accepted from servers offering it. Turning it off refuses it on current connections too, for servers with broken implementations.", func(call otto.FunctionCall) (result otto.Value) {
+		if arg := call.Argument(0); arg.IsDefined() {
+			enabled, err := arg.ToBoolean()
+			if err != nil {
+				result, _ = otto.ToValue(fmt.Errorf("Invalid mxp setting %#v", arg.String()))
+				return
+			}
+			self.lock.Lock()
+			self.mxp = enabled
+			sessions := self.sessions
+			self.lock.Unlock()
+			if !enabled {
+				for _, sess := range sessions {
+					if tn := sess.getTelnet(); tn != nil {
+						tn.refuse(optMXP)
+					}
+				}
+			}
+		}
+		result, _ = otto.ToValue(self.mxpAllowed())
+		return
+	})
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+const (
+	notifyOSC777 = "osc777"
+	notifyOSC9   = "osc9"
+	notifyTitle  = "title"
+	notifyBell   = "bell"
+)
+
+const (
+	// notifyInterval is how often notifications with the same title may be shown.
+	notifyInterval = 3 * time.Second
+	notifyTitleFor = 5 * time.Second
+	terminalTitle  = "mug"
+)
+
+// notifyText removes what would end or confuse an escape sequence.
+func notifyText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f || r == ';' {
+			return ' '
+		}
+		return r
+	}, stripANSI(s))
+}
+
+// notify writes a notification to the terminal itself, bypassing the views, and returns whether it was shown rather than
+// rate limited.
+func (self *Client) notify(title, body string) bool {
+	self.lock.Lock()
+	if time.Since(self.notified[title]) < notifyInterval {
+		self.lock.Unlock()
+		return false
+	}
+	self.notified[title] = time.Now()
+	method := self.notifyMethod
+	self.lock.Unlock()
+	title, body = notifyText(title), notifyText(body)
+	switch method {
+	case notifyOSC777:
+		fmt.Fprintf(self.gui, "\033]777;notify;%v;%v\a", title, body)
+	case notifyOSC9:
+		fmt.Fprintf(self.gui, "\033]9;%v: %v\a", title, body)
+	case notifyTitle:
+		fmt.Fprintf(self.gui, "\033]0;%v: %v\a\a", title, body)
+		time.AfterFunc(notifyTitleFor, func() {
+			fmt.Fprintf(self.gui, "\033]0;%v\a", terminalTitle)
+		})
+	case notifyBell:
+		fmt.Fprint(self.gui, "\a")
+	}
+	return true
+}
+
+func (self *Client) bindNotify() {
+	self.bind("notify(title, body)", "Show a desktop notification through the terminal, at most one per 3 seconds for each title, and return whether it was shown.", func(call otto.FunctionCall) (result otto.Value) {
+		result, _ = otto.ToValue(self.notify(call.Argument(0).String(), call.Argument(1).String()))
+		return
+	})
+	self.bind("notifyMethod(method)", "Get or set how notify() reaches you: \"osc777\" or \"osc9\" for terminals showing desktop notifications, or else \"title\", which sets the terminal title for a while and rings the bell, or just \"bell\".", func(call otto.FunctionCall) (result otto.Value) {
+		self.lock.Lock()
+		defer self.lock.Unlock()
+		if arg := call.Argument(0); arg.IsDefined() {
+			switch method := arg.String(); method {
+			case notifyOSC777, notifyOSC9, notifyTitle, notifyBell:
+				self.notifyMethod = method
+			default:
+				result, _ = otto.ToValue(fmt.Errorf("Unknown notify method %#v, use \"osc777\", \"osc9\", \"title\" or \"bell\"", method))
+				return
+			}
+		}
+		result, _ = otto.ToValue(self.notifyMethod)
+		return
+	})
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/robertkrimen/otto"
+	"github.com/zond/gocui"
+)
+
+var numpadWalks = map[string]string{
+	"8": "n",
+	"2": "s",
+	"4": "w",
+	"6": "e",
+	"7": "nw",
+	"9": "ne",
+	"1": "sw",
+	"3": "se",
+	"5": "look",
+	"+": "up",
+	"-": "down",
+}
+
+// keypadKey runs the macro bound to the keypad key name, or walks in its direction if numpad walking is on.
+func (self *Client) keypadKey(name string) {
+	if self.runKeyMacro(keySpec{keypad: name}.sig()) {
+		return
+	}
+	self.lock.RLock()
+	enabled := self.numpadWalk
+	self.lock.RUnlock()
+	direction, found := numpadWalks[name]
+	if !enabled || !found {
+		return
+	}
+	var err error
+	self.scriptLock.Lock()
+	self.within(self.activeSession(), func() {
+		err = self.command(direction)
+	})
+	self.scriptLock.Unlock()
+	if err != nil {
+		self.OutputErrorf("%v\n", err)
+	}
+}
+
+// plainKeypad handles terminals sending plain digits for the keypad: with numpad walking on, a single walk key typed into an empty
+// input line is taken as a keypad key.
+func (self *Client) plainKeypad(v *gocui.View) {
+	before := self.lastInput
+	line, _ := v.Line(0)
+	line = trimInput(line)
+	self.lastInput = line
+	self.lock.RLock()
+	enabled := self.numpadWalk
+	self.lock.RUnlock()
+	if !enabled || self.masked || before != "" {
+		return
+	}
+	if _, found := numpadWalks[line]; !found {
+		return
+	}
+	v.Clear()
+	v.SetCursor(0, 0)
+	self.lastInput = ""
+	self.keypadKey(line)
+}
+
+func (self *Client) bindNumpad() {
+	self.bind("numpadwalk(enabled)", "Get or set whether the keypad walks: 8/2/4/6 send n/s/w/e, 7/9/1/3 nw/ne/sw/se, 5 look and +/- up/down, unless bound with bindKey(\"keypad-8\", ...). Keys in application keypad mode work anywhere; terminals sending plain digits walk when the key is pressed on an empty input line. If nothing happens, turn it off and press the key: a digit showing up in the input line means plain digits, nothing showing up means an unrecognized sequence.", func(call otto.FunctionCall) (result otto.Value) {
+		self.lock.Lock()
+		defer self.lock.Unlock()
+		if arg := call.Argument(0); arg.IsDefined() {
+			enabled, err := arg.ToBoolean()
+			if err != nil {
+				result, _ = otto.ToValue(fmt.Errorf("Invalid numpadwalk setting %#v", arg.String()))
+				return
+			}
+			self.numpadWalk = enabled
+		}
+		result, _ = otto.ToValue(self.numpadWalk)
+		return
+	})
+}
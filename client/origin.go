@@ -0,0 +1,100 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+)
+
+// Origins tag sent commands with what sent them, in echoes and logs.
+const (
+	originUser     = "user"
+	originAlias    = "alias"
+	originTrigger  = "trigger"
+	originTimer    = "timer"
+	originSendFile = "sendFile"
+	originScript   = "script"
+	// originClient is for what mug sends by itself, like anti-idle commands and logins.
+	originClient = "client"
+)
+
+const (
+	echoSendsAll  = "all"
+	echoSendsUser = "user"
+	echoSendsNone = "none"
+)
+
+var origins = []string{originUser, originAlias, originTrigger, originTimer, originSendFile, originScript, originClient}
+
+// queuedSend is text waiting in the send queue, with the origin it is logged with once it goes out.
+type queuedSend struct {
+	text   string
+	origin string
+}
+
+// withOrigin runs f with what it sends tagged with origin. It must run in script context.
+func (self *Client) withOrigin(origin string, f func()) {
+	old := self.origin
+	self.origin = origin
+	defer func() {
+		self.origin = old
+	}()
+	f()
+}
+
+// currentOrigin returns the origin of what is sent from script context right now.
+func (self *Client) currentOrigin() string {
+	if self.origin == "" {
+		return originScript
+	}
+	return self.origin
+}
+
+// logSent logs the lines of text sent with origin, except while the server has turned echo off for a password.
+func (self *session) logSent(text, origin string) {
+	if self.passwordMode() || self.getLogger() == nil {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimRight(text, "\r\n"), "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			self.logLine(fmt.Sprintf("[%v] %v", origin, line), true)
+		}
+	}
+}
+
+func (self *Client) bindOrigins() {
+	self.bind("echoSends(mode)", "Get or set which sent commands are echoed: \"all\", \"user\" for only those typed or sent by your aliases, or \"none\". Commands from scripts are echoed dimmed.", func(call otto.FunctionCall) (result otto.Value) {
+		self.lock.Lock()
+		defer self.lock.Unlock()
+		if arg := call.Argument(0); arg.IsDefined() {
+			switch mode := arg.String(); mode {
+			case echoSendsAll, echoSendsUser, echoSendsNone:
+				self.echoSends = mode
+			default:
+				result, _ = otto.ToValue(fmt.Errorf("Unknown echo mode %#v, use \"all\", \"user\" or \"none\"", mode))
+				return
+			}
+		}
+		result, _ = otto.ToValue(self.echoSends)
+		return
+	})
+	self.bind("echoPrefix(origin, prefix)", fmt.Sprintf("Get or set the prefix of echoed commands from origin, one of %v, instead of the localecho prefix.", strings.Join(origins, ", ")), func(call otto.FunctionCall) (result otto.Value) {
+		origin := call.Argument(0).String()
+		known := false
+		for _, o := range origins {
+			known = known || o == origin
+		}
+		if !known {
+			result, _ = otto.ToValue(fmt.Errorf("Unknown origin %#v, use one of %v", origin, strings.Join(origins, ", ")))
+			return
+		}
+		self.lock.Lock()
+		defer self.lock.Unlock()
+		if arg := call.Argument(1); arg.IsDefined() {
+			self.echoPrefixes[origin] = arg.String()
+		}
+		result, _ = otto.ToValue(self.echoPrefixes[origin])
+		return
+	})
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robertkrimen/otto"
+	"github.com/zond/gocui"
+)
+
+// pageQuiet is how long output has to pause for the lines after it to start a new page.
+const pageQuiet = time.Second
+
+// page holds the display of a session at the bottom once the added lines fill more than a screenful since the user last had a look or
+// the output paused, by scrolling it up to the last line that fit. From there on it is scrolled up like any other, so scrollback keys
+// work on it too. It must be called with the client lock held.
+func (self *session) page(added []string) {
+	width, height := self.client.outputWidth, self.client.outputHeight
+	if !self.client.config.paging || self.more || height < 2 {
+		return
+	}
+	now := time.Now()
+	if now.Sub(self.pagedAt) > pageQuiet {
+		self.paged = 0
+	}
+	self.pagedAt = now
+	for i, line := range added {
+		// The last row is left for the marker.
+		if self.paged += len(wrapLine(line, width, self.client.wrapIndent)); self.paged > height-1 {
+			self.more = true
+			self.scroll = len(added) - i
+			self.newLines = self.scroll
+			return
+		}
+	}
+}
+
+// moreMarker describes how many lines paging holds back. It must be called with the client lock held.
+func (self *session) moreMarker() string {
+	return fmt.Sprintf("--More (%v)--", self.scroll)
+}
+
+// paging tells whether paging holds the display of the session.
+func (self *session) paging() bool {
+	self.client.lock.RLock()
+	defer self.client.lock.RUnlock()
+	return self.more
+}
+
+// morePage shows the next page of the lines paging holds back.
+func (self *Client) morePage(g *gocui.Gui) error {
+	return self.pageDown(g, nil)
+}
+
+// moreLine shows one more of the lines paging holds back.
+func (self *Client) moreLine(g *gocui.Gui) error {
+	return self.scrollOutput(g, func(sess *session, all []string, width, height int) {
+		sess.scroll--
+	})
+}
+
+// moreEnd shows the bottom of the output and goes back to following it.
+func (self *Client) moreEnd(g *gocui.Gui) error {
+	return self.scrollBottom(g, nil)
+}
+
+// moreKeys takes a space or q typed into the empty input line as paging commands while paging holds the display of the active session.
+func (self *Client) moreKeys(g *gocui.Gui, v *gocui.View) {
+	if self.copy.active || self.masked || !self.activeSession().paging() {
+		return
+	}
+	line, _ := v.Line(0)
+	if _, err := v.Line(1); err == nil {
+		return
+	}
+	switch strings.TrimRight(line, "\x00") {
+	case " ":
+		self.morePage(g)
+	case "q":
+		self.moreEnd(g)
+	default:
+		return
+	}
+	v.Clear()
+	v.SetCursor(0, 0)
+}
+
+func (self *Client) bindPaging() {
+	self.bind("paging(enabled)", "Get or set whether the display stops at a --More-- marker when more than a screenful of lines arrives at once. Then Space shows the next page, Enter one more line and q the bottom, when typed into an empty input line. Receiving, triggers and logging go on meanwhile.", func(call otto.FunctionCall) (result otto.Value) {
+		if arg := call.Argument(0); arg.IsDefined() {
+			enabled, err := arg.ToBoolean()
+			if err != nil {
+				result, _ = otto.ToValue(err)
+				return
+			}
+			self.Configure(WithPaging(enabled))
+			if !enabled {
+				// Held sessions stay where they are, but as ordinary scrollback.
+				self.lock.Lock()
+				for _, sess := range self.sessions {
+					sess.more = false
+				}
+				self.lock.Unlock()
+			}
+		}
+		result, _ = otto.ToValue(self.getConfig().paging)
+		return
+	})
+}
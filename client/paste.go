@@ -0,0 +1,55 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zond/gocui"
+)
+
+const pasteConfirmLines = 10
+
+// inputLines returns every line typed or pasted into v, without trailing empty lines.
+func inputLines(v *gocui.View) (result []string) {
+	for y := 0; ; y++ {
+		line, err := v.Line(y)
+		if err != nil {
+			break
+		}
+		result = append(result, trimInput(line))
+	}
+	for len(result) > 0 && result[len(result)-1] == "" {
+		result = result[:len(result)-1]
+	}
+	return
+}
+
+// paste submits lines one by one, asking for confirmation first if there are many of them.
+func (self *Client) paste(v *gocui.View, lines []string) {
+	if len(lines) > pasteConfirmLines {
+		self.pendingPaste = lines
+		v.Title = fmt.Sprintf("Send %v pasted lines? (y/n, then Enter)", len(lines))
+		return
+	}
+	self.submitLines(lines)
+}
+
+// submitLines records lines as one history entry, so recalling it brings all of them back, and runs them one by one.
+func (self *Client) submitLines(lines []string) {
+	self.addHistory(strings.Join(lines, "\n"))
+	for _, line := range lines {
+		self.execute(line)
+	}
+}
+
+func (self *Client) confirmPaste(v *gocui.View, answer string) {
+	lines := self.pendingPaste
+	self.pendingPaste = nil
+	v.Title = ""
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		self.submitLines(lines)
+	default:
+		self.OutputNoticef("Discarded %v pasted lines\n", len(lines))
+	}
+}
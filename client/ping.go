@@ -0,0 +1,263 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+const optTimingMark = 6
+
+const (
+	pingTimeout      = 5 * time.Second
+	pingSamples      = 10
+	autoPingInterval = 30 * time.Second
+	pingStatus       = "ping"
+)
+
+const (
+	pingGMCP       = "GMCP Core.Ping"
+	pingTimingMark = "timing mark"
+	// pingPrompt sends an empty line and waits for the next prompt, which measures the server as well as the network.
+	pingPrompt = "prompt"
+)
+
+type pingSample struct {
+	rtt         time.Duration
+	approximate bool
+}
+
+// pinger measures round trips to the server of a session. Answers arrive on the read goroutine, so it has its own lock.
+type pinger struct {
+	lock sync.Mutex
+	// method is how the pending ping was sent, or empty if none is.
+	method  string
+	sent    time.Time
+	timer   *time.Timer
+	waiting []func(time.Duration, string, error)
+	samples []pingSample
+	// noTimingMark is set once a timing mark went unanswered, so later pings fall back to prompts.
+	noTimingMark bool
+}
+
+// ping measures the latency of the server and calls done with the result. Pings made while one is pending share its result.
+func (self *session) ping(done func(rtt time.Duration, method string, err error)) {
+	tn := self.getTelnet()
+	if tn == nil {
+		done(0, "", fmt.Errorf("Not connected"))
+		return
+	}
+	// The telnet lock is held while answers arrive, so it must not be taken with the pinger lock.
+	gmcp, prompts := tn.enabled(optGMCP), tn.usesPrompts()
+	p := &self.pinger
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.waiting = append(p.waiting, done)
+	if p.method != "" {
+		return
+	}
+	switch {
+	case gmcp:
+		p.method = pingGMCP
+	case !p.noTimingMark:
+		p.method = pingTimingMark
+	case prompts:
+		p.method = pingPrompt
+	default:
+		p.waiting = nil
+		done(0, "", fmt.Errorf("Unable to ping, the server neither answers timing marks nor marks its prompts"))
+		return
+	}
+	method, sent := p.method, time.Now()
+	p.sent = sent
+	p.timer = time.AfterFunc(pingTimeout, func() {
+		self.pingTimedOut(method, sent)
+	})
+	var err error
+	switch method {
+	case pingGMCP:
+		err = tn.sendSub(optGMCP, []byte("Core.Ping"))
+	case pingTimingMark:
+		err = writeDeadlined(tn.conn, []byte{telnetIAC, telnetDO, optTimingMark})
+	case pingPrompt:
+		go self.write("\n", originClient)
+	}
+	if err != nil {
+		p.timer.Stop()
+		p.method = ""
+		waiting := p.waiting
+		p.waiting = nil
+		go p.report(waiting, 0, method, err)
+	}
+}
+
+func (self *pinger) report(waiting []func(time.Duration, string, error), rtt time.Duration, method string, err error) {
+	for _, done := range waiting {
+		done(rtt, method, err)
+	}
+}
+
+// pong ends the pending ping if it was sent using method, and returns whether it did.
+func (self *session) pong(method string) bool {
+	p := &self.pinger
+	p.lock.Lock()
+	if p.method != method {
+		p.lock.Unlock()
+		return false
+	}
+	rtt := time.Since(p.sent)
+	p.timer.Stop()
+	p.method = ""
+	p.samples = append(p.samples, pingSample{rtt: rtt, approximate: method == pingPrompt})
+	if len(p.samples) > pingSamples {
+		p.samples = p.samples[len(p.samples)-pingSamples:]
+	}
+	waiting := p.waiting
+	p.waiting = nil
+	p.lock.Unlock()
+	go p.report(waiting, rtt, method, nil)
+	return true
+}
+
+// pingTimedOut fails the ping sent using method at sent if it is still pending. Timeouts are not part of the average.
+func (self *session) pingTimedOut(method string, sent time.Time) {
+	p := &self.pinger
+	p.lock.Lock()
+	if p.method != method || !p.sent.Equal(sent) {
+		p.lock.Unlock()
+		return
+	}
+	p.method = ""
+	if method == pingTimingMark {
+		p.noTimingMark = true
+	}
+	waiting := p.waiting
+	p.waiting = nil
+	p.lock.Unlock()
+	p.report(waiting, 0, method, fmt.Errorf("No answer to ping within %v", pingTimeout))
+}
+
+// latency returns the average of the recent round trips, whether any of them were approximate, and how many there were.
+func (self *session) latency() (average time.Duration, approximate bool, count int) {
+	p := &self.pinger
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, sample := range p.samples {
+		average += sample.rtt
+		approximate = approximate || sample.approximate
+	}
+	if count = len(p.samples); count > 0 {
+		average /= time.Duration(count)
+	}
+	return
+}
+
+func formatLatency(rtt time.Duration, approximate bool) string {
+	text := rtt.Round(time.Millisecond).String()
+	if approximate {
+		text = "~" + text
+	}
+	return text
+}
+
+// showLatency puts the average latency of the active session in the status bar while autoping is on.
+func (self *Client) showLatency(err error) {
+	self.lock.RLock()
+	enabled := self.autoPing != nil
+	self.lock.RUnlock()
+	if !enabled {
+		return
+	}
+	text := "ping timeout"
+	if err == nil {
+		average, approximate, _ := self.activeSession().latency()
+		text = "ping " + formatLatency(average, approximate)
+	}
+	self.setStatusField(pingStatus, text, "")
+	self.redraw()
+}
+
+// autoPingLoop pings the active session until stop is closed.
+func (self *Client) autoPingLoop(stop chan struct{}) {
+	ticker := time.NewTicker(autoPingInterval)
+	defer ticker.Stop()
+	for {
+		if self.activeSession().getConn() != nil {
+			self.activeSession().ping(func(rtt time.Duration, method string, err error) {
+				self.showLatency(err)
+			})
+		}
+		select {
+		case <-stop:
+			return
+		case <-self.closing:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (self *Client) bindPing() {
+	self.bind("ping(fn)", "Measure the latency of the server using GMCP Core.Ping or a telnet timing mark, or else by sending an empty line and timing the next prompt, which is approximate. Calls fn(ms, approximate, error) with the result if given, or shows it.", func(call otto.FunctionCall) (result otto.Value) {
+		sess := self.target()
+		fn := call.Argument(0)
+		sess.ping(func(rtt time.Duration, method string, err error) {
+			sess.schedule(func() {
+				if !fn.IsFunction() {
+					if err != nil {
+						sess.outputErrorf("%v\n", err)
+					} else {
+						sess.outputNoticef("Latency %v via %v\n", formatLatency(rtt, method == pingPrompt), method)
+					}
+					return
+				}
+				errValue := otto.NullValue()
+				if err != nil {
+					errValue, _ = otto.ToValue(err)
+				}
+				if _, err := self.callScript(fn, rtt.Seconds()*1000, method == pingPrompt, errValue); err != nil {
+					self.reportError("ping callback", err)
+				}
+			})
+			self.showLatency(err)
+		})
+		return
+	})
+	self.bind("latency()", "Return the {average, samples, approximate} of the last 10 answered pings of the current session, in milliseconds, or null before any.", func(call otto.FunctionCall) (result otto.Value) {
+		average, approximate, count := self.target().latency()
+		if count == 0 {
+			return otto.NullValue()
+		}
+		obj, _ := self.ot.Object("({})")
+		obj.Set("average", average.Seconds()*1000)
+		obj.Set("samples", count)
+		obj.Set("approximate", approximate)
+		return obj.Value()
+	})
+	self.bind("autoping(enabled)", "Get or set whether the active session is pinged every 30 seconds, with the average latency shown in the status bar.", func(call otto.FunctionCall) (result otto.Value) {
+		if arg := call.Argument(0); arg.IsDefined() {
+			enabled, _ := arg.ToBoolean()
+			self.lock.Lock()
+			if enabled && self.autoPing == nil {
+				self.autoPing = make(chan struct{})
+				go self.autoPingLoop(self.autoPing)
+			} else if !enabled && self.autoPing != nil {
+				close(self.autoPing)
+				self.autoPing = nil
+			}
+			self.lock.Unlock()
+			if !enabled {
+				self.removeStatusFields(func(field *statusField) bool {
+					return field.key == pingStatus
+				})
+				self.redraw()
+			}
+		}
+		self.lock.RLock()
+		defer self.lock.RUnlock()
+		result, _ = otto.ToValue(self.autoPing != nil)
+		return
+	})
+}
@@ -0,0 +1,194 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/robertkrimen/otto"
+)
+
+// stage is a step of a pipeline. It gets data from the stage before it, and passes what the stage after it gets to emit, any number
+// of times, so it may drop, hold back, split or rewrite what it got.
+type stage interface {
+	process(data []byte, emit func([]byte))
+}
+
+type stageFunc func(data []byte, emit func([]byte))
+
+func (self stageFunc) process(data []byte, emit func([]byte)) {
+	self(data, emit)
+}
+
+type pipelineStage struct {
+	name string
+	// stage is nil for steps done outside the pipeline, which are only listed to show their counters.
+	stage stage
+	// fixed stages can't be disabled, since nothing after them would make sense without them.
+	fixed    bool
+	in       int64
+	out      int64
+	bytesIn  int64
+	bytesOut int64
+}
+
+// pipeline runs data through named stages in order. Disabled stages let data through unchanged.
+type pipeline struct {
+	lock   sync.Mutex
+	name   string
+	stages []*pipelineStage
+	// disabled tells whether the stage with the qualified name pipeline.stage is turned off, and may be nil.
+	disabled func(name string) bool
+}
+
+func newPipeline(name string, disabled func(string) bool, stages ...*pipelineStage) *pipeline {
+	return &pipeline{
+		name:     name,
+		stages:   stages,
+		disabled: disabled,
+	}
+}
+
+func newStage(name string, s stage) *pipelineStage {
+	return &pipelineStage{name: name, stage: s}
+}
+
+func fixedStage(name string, s stage) *pipelineStage {
+	return &pipelineStage{name: name, stage: s, fixed: true}
+}
+
+// externalStage lists a step done outside the pipeline, which counts what it does with count.
+func externalStage(name string) *pipelineStage {
+	return &pipelineStage{name: name, fixed: true}
+}
+
+// run passes data through all stages, calling sink with whatever comes out of the last one.
+func (self *pipeline) run(data []byte, sink func([]byte)) {
+	self.runFrom(0, data, sink)
+}
+
+func (self *pipeline) runFrom(index int, data []byte, sink func([]byte)) {
+	if index == len(self.stages) {
+		sink(data)
+		return
+	}
+	s := self.stages[index]
+	if s.stage == nil {
+		self.runFrom(index+1, data, sink)
+		return
+	}
+	self.lock.Lock()
+	s.in++
+	s.bytesIn += int64(len(data))
+	self.lock.Unlock()
+	emit := func(out []byte) {
+		self.lock.Lock()
+		s.out++
+		s.bytesOut += int64(len(out))
+		self.lock.Unlock()
+		self.runFrom(index+1, out, sink)
+	}
+	if !s.fixed && self.disabled != nil && self.disabled(self.name+"."+s.name) {
+		emit(data)
+		return
+	}
+	s.stage.process(data, emit)
+}
+
+// count adds to the counters of the external stage name.
+func (self *pipeline) count(name string, bytesIn, bytesOut int) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for _, s := range self.stages {
+		if s.name == name {
+			s.in++
+			s.out++
+			s.bytesIn += int64(bytesIn)
+			s.bytesOut += int64(bytesOut)
+		}
+	}
+}
+
+func (self *pipeline) find(name string) *pipelineStage {
+	for _, s := range self.stages {
+		if self.name+"."+s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// describe returns a row for each stage with its counters.
+func (self *pipeline) describe() (result []map[string]interface{}) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for _, s := range self.stages {
+		name := self.name + "." + s.name
+		result = append(result, map[string]interface{}{
+			"stage":    name,
+			"enabled":  s.fixed || self.disabled == nil || !self.disabled(name),
+			"in":       s.in,
+			"out":      s.out,
+			"bytesIn":  s.bytesIn,
+			"bytesOut": s.bytesOut,
+		})
+	}
+	return
+}
+
+// stageDisabled tells whether the stage with the qualified name is turned off.
+func (self *Client) stageDisabled(name string) bool {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.disabledStages[name]
+}
+
+// pipelines returns the pipelines the current session uses, in the order data passes through them.
+func (self *Client) pipelines() (result []*pipeline) {
+	sess := self.target()
+	if p := sess.getReceivePipeline(); p != nil {
+		result = append(result, p)
+	}
+	return append(result, self.linePipeline, sess.sendPipeline)
+}
+
+func (self *Client) bindPipeline() {
+	self.bind("pipeline()", "List the stages received data, received lines and sent commands of the current session pass through, in order, with how many chunks or lines and bytes went in and out of each.", func(call otto.FunctionCall) (result otto.Value) {
+		items := []map[string]interface{}{}
+		for _, p := range self.pipelines() {
+			items = append(items, p.describe()...)
+		}
+		return self.jsArray(items)
+	})
+	self.bind("pipelineStage(stage, enabled)", "Turn a pipeline stage, named as in pipeline(), off or on again for debugging. A disabled stage lets everything through unchanged.", func(call otto.FunctionCall) (result otto.Value) {
+		name := call.Argument(0).String()
+		var found *pipelineStage
+		names := []string{}
+		for _, p := range self.pipelines() {
+			if s := p.find(name); s != nil {
+				found = s
+			}
+			for _, s := range p.stages {
+				names = append(names, p.name+"."+s.name)
+			}
+		}
+		if found == nil {
+			result, _ = otto.ToValue(fmt.Errorf("Unknown stage %#v, use one of %v", name, strings.Join(names, ", ")))
+			return
+		}
+		if found.fixed {
+			result, _ = otto.ToValue(fmt.Errorf("Stage %#v can't be disabled", name))
+			return
+		}
+		enabled, _ := call.Argument(1).ToBoolean()
+		self.lock.Lock()
+		if enabled {
+			delete(self.disabledStages, name)
+		} else {
+			self.disabledStages[name] = true
+		}
+		self.lock.Unlock()
+		result, _ = otto.ToValue(enabled)
+		return
+	})
+}
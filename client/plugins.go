@@ -0,0 +1,197 @@
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+)
+
+type plugin struct {
+	name string
+	path string
+	err  error
+}
+
+func (self *plugin) status() string {
+	if self.err != nil {
+		return "error: " + self.err.Error()
+	}
+	return "ok"
+}
+
+func (self *Client) loadPlugins() {
+	infos, err := ioutil.ReadDir(self.pluginDir)
+	if err != nil {
+		return
+	}
+	names := []string{}
+	for _, info := range infos {
+		if !info.IsDir() && strings.HasSuffix(info.Name(), ".js") {
+			names = append(names, info.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := &plugin{
+			name: strings.TrimSuffix(name, ".js"),
+			path: filepath.Join(self.pluginDir, name),
+		}
+		self.plugins = append(self.plugins, p)
+		if p.err = self.runFile(p.path); p.err != nil {
+			self.OutputErrorf("Plugin %v failed: %v\n", p.name, p.err)
+		}
+	}
+}
+
+func (self *Client) findPlugin(name string) *plugin {
+	for _, p := range self.plugins {
+		if p.name == name || p.name+".js" == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// unloadPlugin removes everything registered while the plugin was being loaded.
+func (self *Client) unloadPlugin(p *plugin) {
+	owner, _ := filepath.Abs(p.path)
+	triggers := []*trigger{}
+	for _, t := range self.triggers {
+		if t.owner != owner {
+			triggers = append(triggers, t)
+		}
+	}
+	self.triggers = triggers
+	multiTriggers := []*multiTrigger{}
+	for _, t := range self.multiTriggers {
+		if t.owner != owner {
+			multiTriggers = append(multiTriggers, t)
+		}
+	}
+	self.multiTriggers = multiTriggers
+	subs := []*substitution{}
+	for _, sub := range self.subs {
+		if sub.owner != owner {
+			subs = append(subs, sub)
+		}
+	}
+	self.subs = subs
+	gags := []*gag{}
+	for _, g := range self.gags {
+		if g.owner != owner {
+			gags = append(gags, g)
+		}
+	}
+	self.gags = gags
+	highlights := []*highlightRule{}
+	for _, rule := range self.highlights {
+		if rule.owner != owner {
+			highlights = append(highlights, rule)
+		}
+	}
+	self.highlights = highlights
+	historyIgnores := []*historyIgnore{}
+	for _, ignore := range self.historyIgnores {
+		if ignore.owner != owner {
+			historyIgnores = append(historyIgnores, ignore)
+		}
+	}
+	self.historyIgnores = historyIgnores
+	for name, a := range self.aliases {
+		if a.owner == owner {
+			delete(self.aliases, name)
+		}
+	}
+	for id, t := range self.timers {
+		if t.owner == owner {
+			t.timer.Stop()
+			delete(self.timers, id)
+		}
+	}
+	for _, w := range append([]*wait{}, self.waits...) {
+		if w.owner == owner {
+			self.removeWait(w)
+		}
+	}
+	self.lock.Lock()
+	captures := []*captureWindow{}
+	for _, w := range self.captures {
+		if w.owner != owner {
+			captures = append(captures, w)
+		}
+	}
+	self.captures = captures
+	windows := []*scriptWindow{}
+	for _, w := range self.windows {
+		if w.owner != owner {
+			windows = append(windows, w)
+		}
+	}
+	self.windows = windows
+	for sig, macro := range self.keyMacros {
+		if macro.owner == owner {
+			delete(self.keyMacros, sig)
+		}
+	}
+	self.lock.Unlock()
+	removeHooks(self.hooks, owner)
+	removeHooks(self.gmcpHandlers, owner)
+	removeHooks(self.msdpHandlers, owner)
+	self.removeStatusFields(func(field *statusField) bool {
+		return field.owner == owner
+	})
+}
+
+// removeHooks removes the handlers registered by owner from each list in hooks.
+func removeHooks(hooks map[string][]*hook, owner string) {
+	for key, handlers := range hooks {
+		kept := []*hook{}
+		for _, h := range handlers {
+			if h.owner != owner {
+				kept = append(kept, h)
+			}
+		}
+		hooks[key] = kept
+	}
+}
+
+func (self *Client) bindPlugins() {
+	self.bind("plugins()", "List loaded plugins and their status.", func(call otto.FunctionCall) (result otto.Value) {
+		if len(self.plugins) == 0 {
+			result, _ = otto.ToValue(fmt.Sprintf("No plugins in %v", self.pluginDir))
+			return
+		}
+		for _, p := range self.plugins {
+			self.Outputf("%v: %v\n", p.name, p.status())
+		}
+		return
+	})
+	self.bind("reloadPlugin(name)", "Unload and load the plugin name again.", func(call otto.FunctionCall) (result otto.Value) {
+		p := self.findPlugin(call.Argument(0).String())
+		if p == nil {
+			result, _ = otto.ToValue(fmt.Errorf("No plugin named %#v", call.Argument(0).String()))
+			return
+		}
+		self.unloadPlugin(p)
+		if p.err = self.runFile(p.path); p.err != nil {
+			result, _ = otto.ToValue(p.err)
+			return
+		}
+		result, _ = otto.ToValue(fmt.Sprintf("Reloaded %v", p.name))
+		return
+	})
+	self.bind("unloadPlugin(name)", "Remove everything the plugin name registered while loading.", func(call otto.FunctionCall) (result otto.Value) {
+		p := self.findPlugin(call.Argument(0).String())
+		if p == nil {
+			result, _ = otto.ToValue(fmt.Errorf("No plugin named %#v", call.Argument(0).String()))
+			return
+		}
+		self.unloadPlugin(p)
+		result, _ = otto.ToValue(fmt.Sprintf("Unloaded %v", p.name))
+		return
+	})
+}
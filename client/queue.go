@@ -0,0 +1,120 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+// write sends text without echoing it, and logs it tagged with origin once it goes out. With a send delay configured, text is queued
+// unless the queue is empty and the delay has already passed since the last send, so single interactive commands go out at once.
+// Only what the user typed, directly or through an alias, counts as activity for anti-idle.
+func (self *session) write(text, origin string) (err error) {
+	if self.getConn() == nil {
+		return fmt.Errorf("Nowhere to send %#v", text)
+	}
+	if origin == originUser || origin == originAlias {
+		self.armAntiIdle()
+	}
+	if m := self.client.roomMap; m != nil {
+		for _, line := range strings.Split(text, "\n") {
+			m.moved(self, line)
+		}
+	}
+	self.client.lock.Lock()
+	delay := self.client.sendDelay
+	if delay == 0 || (len(self.queue) == 0 && time.Since(self.lastSent) >= delay) {
+		self.lastSent = time.Now()
+		self.client.lock.Unlock()
+		return self.writeLogged(queuedSend{text: text, origin: origin})
+	}
+	self.queue = append(self.queue, queuedSend{text: text, origin: origin})
+	if !self.dispatching {
+		self.dispatching = true
+		go self.dispatch()
+	}
+	self.client.lock.Unlock()
+	self.client.redraw()
+	return
+}
+
+func (self *session) dispatch() {
+	for {
+		self.client.lock.Lock()
+		if len(self.queue) == 0 {
+			self.dispatching = false
+			self.client.lock.Unlock()
+			return
+		}
+		wait := self.client.sendDelay - time.Since(self.lastSent)
+		self.client.lock.Unlock()
+		time.Sleep(wait)
+		self.client.lock.Lock()
+		if len(self.queue) == 0 {
+			self.dispatching = false
+			self.client.lock.Unlock()
+			return
+		}
+		next := self.queue[0]
+		self.queue = self.queue[1:]
+		self.lastSent = time.Now()
+		self.client.lock.Unlock()
+		if err := self.writeLogged(next); err != nil {
+			self.outputErrorf("Abandoning %v queued commands: %v\n", self.clearQueue()+1, err)
+		}
+		self.client.redraw()
+	}
+}
+
+func (self *session) writeLogged(send queuedSend) (err error) {
+	if err = self.writeConn(send.text); err == nil {
+		self.logSent(send.text, send.origin)
+	}
+	return
+}
+
+// clearQueue drops all queued commands, cancelling a file being sent, and returns how many there were.
+func (self *session) clearQueue() int {
+	self.client.lock.Lock()
+	defer self.client.lock.Unlock()
+	dropped := len(self.queue)
+	self.queue = nil
+	if self.upload != nil {
+		close(self.upload)
+		self.upload = nil
+	}
+	return dropped
+}
+
+func (self *session) queueSize() int {
+	self.client.lock.RLock()
+	defer self.client.lock.RUnlock()
+	return len(self.queue)
+}
+
+func (self *Client) bindQueue() {
+	self.bind("sendDelay(ms)", "Get or set the minimum number of milliseconds between sent commands. Commands sent faster are queued. 0 sends everything at once.", func(call otto.FunctionCall) (result otto.Value) {
+		self.lock.Lock()
+		defer self.lock.Unlock()
+		if arg := call.Argument(0); arg.IsDefined() {
+			ms, err := arg.ToInteger()
+			if err != nil || ms < 0 {
+				result, _ = otto.ToValue(fmt.Errorf("Invalid delay %#v", arg.String()))
+				return
+			}
+			self.sendDelay = time.Duration(ms) * time.Millisecond
+		}
+		result, _ = otto.ToValue(int64(self.sendDelay / time.Millisecond))
+		return
+	})
+	self.bind("queueSize()", "Return the number of commands waiting to be sent in the current session.", func(call otto.FunctionCall) (result otto.Value) {
+		result, _ = otto.ToValue(self.target().queueSize())
+		return
+	})
+	self.bind("clearQueue()", "Drop the commands waiting to be sent in the current session.", func(call otto.FunctionCall) (result otto.Value) {
+		result, _ = otto.ToValue(fmt.Sprintf("Dropped %v queued commands", self.target().clearQueue()))
+		return
+	})
+}
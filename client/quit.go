@@ -0,0 +1,139 @@
+package client
+
+import (
+	"time"
+
+	"github.com/robertkrimen/otto"
+	"github.com/zond/gocui"
+)
+
+func (self *Client) ctrlc(g *gocui.Gui, v *gocui.View) error {
+	input := g.View("input")
+	if input == nil {
+		return nil
+	}
+	input.Clear()
+	input.SetCursor(0, 0)
+	input.Title = ""
+	self.password = nil
+	self.historyBack = 0
+	self.searching = false
+	self.pendingPaste = nil
+	self.question = nil
+	return nil
+}
+
+const (
+	exitHookTimeout = time.Second
+	quitCommandWait = 2 * time.Second
+)
+
+func (self *Client) ctrlq(g *gocui.Gui, v *gocui.View) error {
+	timeout := self.getConfig().quitTimeout
+	if self.shuttingDown || time.Now().Sub(self.quitAt) < timeout {
+		return self.quit()
+	}
+	self.quitAt = time.Now()
+	self.OutputNoticef("Press C-q again within %v to quit\n", timeout)
+	return nil
+}
+
+// quit starts shutting down in the background, so the gui keeps running and a second quit can skip what is left by returning the
+// error that makes the main loop exit at once.
+func (self *Client) quit() error {
+	if self.shuttingDown {
+		select {
+		case <-self.forceQuit:
+		default:
+			close(self.forceQuit)
+		}
+		return gocui.ErrorQuit
+	}
+	self.shuttingDown = true
+	self.OutputNoticef("Shutting down, quit again to exit at once\n")
+	go func() {
+		self.shutdown()
+		close(self.shutDown)
+	}()
+	return nil
+}
+
+// shutdown runs the exit hooks, saves the store, sends the quit command to connected sessions and waits a while for the servers to
+// close the connections, and then closes all connections and logs. It only runs once, later calls wait for the first to finish.
+func (self *Client) shutdown() {
+	self.shutdownOnce.Do(func() {
+		self.scriptLock.Lock()
+		self.guardFor(exitHookTimeout, func() error {
+			self.fireHook("exit")
+			return nil
+		})
+		if self.store != nil {
+			if err := self.store.save(); err != nil {
+				self.OutputErrorf("Unable to save %v: %v\n", self.store.path, err)
+			}
+		}
+		self.scriptLock.Unlock()
+		self.lock.RLock()
+		sessions := self.sessions
+		command := self.config.quitCommand
+		self.lock.RUnlock()
+		if command != "" {
+			self.sendQuitCommand(sessions, command)
+		}
+		for _, sess := range sessions {
+			sess.stopReconnect()
+			sess.disconnect()
+			sess.stopLog()
+		}
+	})
+}
+
+// sendQuitCommand sends command to the connected sessions, and waits until the servers have closed the connections, quitCommandWait
+// has passed or the shutdown is forced.
+func (self *Client) sendQuitCommand(sessions []*session, command string) {
+	for _, sess := range sessions {
+		if sess.getConn() != nil {
+			sess.writeConn(command + "\n")
+		}
+	}
+	deadline := time.After(quitCommandWait)
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		connected := false
+		for _, sess := range sessions {
+			if sess.getConn() != nil {
+				connected = true
+			}
+		}
+		if !connected {
+			return
+		}
+		select {
+		case <-deadline:
+			return
+		case <-self.forceQuit:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (self *Client) bindQuit() {
+	self.bind("quit()", "Run the exit hooks, close all connections and logs and exit. Quitting again while shutting down exits at once.", func(call otto.FunctionCall) (result otto.Value) {
+		self.quitting = true
+		self.redraw()
+		result, _ = otto.ToValue("Quitting")
+		return
+	})
+	self.bind("quitCommand(text)", "Get or set the command sent to connected servers when quitting, before waiting briefly for them to close the connection. An empty text sends nothing.", func(call otto.FunctionCall) (result otto.Value) {
+		self.lock.Lock()
+		if arg := call.Argument(0); arg.IsDefined() {
+			self.config.quitCommand = arg.String()
+		}
+		command := self.config.quitCommand
+		self.lock.Unlock()
+		result, _ = otto.ToValue(command)
+		return
+	})
+}
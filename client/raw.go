@@ -0,0 +1,87 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/robertkrimen/otto"
+)
+
+// scriptBytes turns a string, sent as UTF-8, or an array of byte values into bytes.
+func scriptBytes(arg otto.Value) (result []byte, err error) {
+	if arg.IsString() {
+		return []byte(arg.String()), nil
+	}
+	if !arg.IsObject() || arg.Class() != "Array" {
+		return nil, fmt.Errorf("Expected a string or an array of bytes, got %#v", arg.String())
+	}
+	length, _ := arg.Object().Get("length")
+	n, _ := length.ToInteger()
+	for i := int64(0); i < n; i++ {
+		v, _ := arg.Object().Get(fmt.Sprint(i))
+		b, e := v.ToInteger()
+		if e != nil || b < 0 || b > 255 {
+			return nil, fmt.Errorf("Invalid byte %#v at index %v", v.String(), i)
+		}
+		result = append(result, byte(b))
+	}
+	return
+}
+
+// writeRaw writes data to the connection as it is, bypassing echo, logging, history and the send queue.
+func (self *session) writeRaw(data []byte) (err error) {
+	conn := self.getConn()
+	if conn == nil {
+		return fmt.Errorf("Not connected, unable to send %v bytes", len(data))
+	}
+	if err = writeDeadlined(conn, data); err == nil {
+		self.countStats(func(stats *connStats) {
+			stats.sent += int64(len(data))
+		})
+	}
+	return
+}
+
+func (self *Client) bindRaw() {
+	self.bind("sendRaw(bytes, options)", "Send a string or an array of byte values to the server without echo or newline. IAC bytes are doubled unless options are {escape: false}.", func(call otto.FunctionCall) (result otto.Value) {
+		data, err := scriptBytes(call.Argument(0))
+		if err != nil {
+			result, _ = otto.ToValue(err)
+			return
+		}
+		escape := true
+		if opts := call.Argument(1); opts.IsObject() {
+			if v, _ := opts.Object().Get("escape"); v.IsDefined() {
+				escape, _ = v.ToBoolean()
+			}
+		}
+		if escape {
+			data = bytes.Replace(data, []byte{telnetIAC}, []byte{telnetIAC, telnetIAC}, -1)
+		}
+		if err := self.target().writeRaw(data); err != nil {
+			result, _ = otto.ToValue(err)
+		}
+		return
+	})
+	self.bind("sendSub(option, payload)", "Send payload, a string or an array of byte values, as a telnet subnegotiation of option.", func(call otto.FunctionCall) (result otto.Value) {
+		option, err := call.Argument(0).ToInteger()
+		if err != nil || option < 0 || option > 255 {
+			result, _ = otto.ToValue(fmt.Errorf("Invalid telnet option %#v", call.Argument(0).String()))
+			return
+		}
+		payload, err := scriptBytes(call.Argument(1))
+		if err != nil {
+			result, _ = otto.ToValue(err)
+			return
+		}
+		tn := self.getTelnet()
+		if tn == nil {
+			result, _ = otto.ToValue(fmt.Errorf("Not connected, unable to send subnegotiation %v", option))
+			return
+		}
+		if err := tn.sendSub(byte(option), payload); err != nil {
+			result, _ = otto.ToValue(err)
+		}
+		return
+	})
+}
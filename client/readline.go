@@ -0,0 +1,116 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/zond/gocui"
+)
+
+// inputState returns the runes of the input line and the index of the rune at the cursor, padding with spaces if the cursor is past
+// the end of the line.
+func inputState(v *gocui.View) (runes []rune, pos int) {
+	line, _ := v.Line(0)
+	runes = []rune(strings.TrimRight(line, "\x00"))
+	cx, _ := v.Cursor()
+	ox, _ := v.Origin()
+	for width := 0; width < cx+ox; pos++ {
+		if pos == len(runes) {
+			runes = append(runes, ' ')
+		}
+		width += runeWidth(runes[pos])
+	}
+	return
+}
+
+func setInputState(v *gocui.View, runes []rune, pos int) {
+	v.Clear()
+	fmt.Fprint(v, string(runes))
+	v.SetOrigin(0, 0)
+	v.SetCursor(stringWidth(string(runes[:pos])), 0)
+}
+
+// wordStart returns the index of the start of the word before pos.
+func wordStart(runes []rune, pos int) int {
+	for pos > 0 && unicode.IsSpace(runes[pos-1]) {
+		pos--
+	}
+	for pos > 0 && !unicode.IsSpace(runes[pos-1]) {
+		pos--
+	}
+	return pos
+}
+
+// wordEnd returns the index of the end of the word after pos.
+func wordEnd(runes []rune, pos int) int {
+	for pos < len(runes) && unicode.IsSpace(runes[pos]) {
+		pos++
+	}
+	for pos < len(runes) && !unicode.IsSpace(runes[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// editInput applies f to the input line unless a password is being typed.
+func (self *Client) editInput(f func(runes []rune, pos int) ([]rune, int)) gocui.KeybindingHandler {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if self.masked {
+			return nil
+		}
+		if input := g.View("input"); input != nil {
+			runes, pos := f(inputState(input))
+			setInputState(input, runes, pos)
+		}
+		return nil
+	}
+}
+
+// kill removes runes[from:to] into the kill buffer.
+func (self *Client) kill(runes []rune, from, to int) ([]rune, int) {
+	self.killBuffer = string(runes[from:to])
+	return append(runes[:from:from], runes[to:]...), from
+}
+
+func (self *Client) bindReadline() (err error) {
+	bindings := []struct {
+		key  interface{}
+		mod  gocui.Modifier
+		name string
+		doc  string
+		f    func(runes []rune, pos int) ([]rune, int)
+	}{
+		{gocui.KeyCtrlA, 0, "Ctrl-A", "Move to the start of the input line.", func(runes []rune, pos int) ([]rune, int) {
+			return runes, 0
+		}},
+		{gocui.KeyCtrlE, 0, "Ctrl-E", "Move to the end of the input line.", func(runes []rune, pos int) ([]rune, int) {
+			return runes, len(runes)
+		}},
+		{'b', gocui.ModAlt, "Alt-B", "Move back a word.", func(runes []rune, pos int) ([]rune, int) {
+			return runes, wordStart(runes, pos)
+		}},
+		{'f', gocui.ModAlt, "Alt-F", "Move forward a word.", func(runes []rune, pos int) ([]rune, int) {
+			return runes, wordEnd(runes, pos)
+		}},
+		{gocui.KeyCtrlW, 0, "Ctrl-W", "Cut the word before the cursor.", func(runes []rune, pos int) ([]rune, int) {
+			return self.kill(runes, wordStart(runes, pos), pos)
+		}},
+		{gocui.KeyCtrlU, 0, "Ctrl-U", "Cut everything before the cursor.", func(runes []rune, pos int) ([]rune, int) {
+			return self.kill(runes, 0, pos)
+		}},
+		{gocui.KeyCtrlK, 0, "Ctrl-K", "Cut everything after the cursor.", func(runes []rune, pos int) ([]rune, int) {
+			return self.kill(runes, pos, len(runes))
+		}},
+		{gocui.KeyCtrlY, 0, "Ctrl-Y", "Paste the last cut text.", func(runes []rune, pos int) ([]rune, int) {
+			yanked := []rune(self.killBuffer)
+			return append(append(append([]rune{}, runes[:pos]...), yanked...), runes[pos:]...), pos + len(yanked)
+		}},
+	}
+	for _, binding := range bindings {
+		if err = self.setKeybinding(binding.key, binding.mod, binding.name, binding.doc, self.editInput(binding.f)); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,171 @@
+package client
+
+import (
+	"strings"
+	"time"
+)
+
+// receiveLine schedules processing of a complete line, the first shown bytes of which have already been displayed.
+func (self *session) receiveLine(raw string, shown int) {
+	self.receiveLines([]string{raw}, shown)
+}
+
+// receiveLines schedules processing of the complete lines of one read in one go, so that their display costs one update of the
+// line store. The first line continues the shown bytes of an incomplete line already displayed.
+func (self *session) receiveLines(raws []string, shown int) {
+	if len(raws) == 0 {
+		return
+	}
+	at := time.Now()
+	self.rotateAutolog(false)
+	for _, raw := range raws {
+		self.logLine(stripANSI(strings.TrimRight(raw, "\r")), false)
+	}
+	self.countStats(func(stats *connStats) {
+		stats.lines += int64(len(raws))
+	})
+	self.schedule(func() {
+		self.batch(func() {
+			for i, raw := range raws {
+				if i > 0 {
+					shown = 0
+				}
+				self.client.processLine(raw, shown, at)
+			}
+		})
+	})
+}
+
+// receivePartial schedules display of raw, which continues the shown bytes of an incomplete line already displayed.
+func (self *session) receivePartial(raw string, shown int) {
+	at := time.Now()
+	self.schedule(func() {
+		if shown == 0 {
+			self.outputBytes([]byte(self.client.timestamp(at) + raw))
+		} else {
+			self.outputBytes([]byte(raw))
+		}
+	})
+}
+
+// receivedLine is what the stages of the line pipeline know about the line passing through it, besides its text with escapes.
+type receivedLine struct {
+	// line is the text without escapes that triggers see.
+	line string
+	// text is what triggers and substitutions made of line, and changed whether they did.
+	text    string
+	changed bool
+	gagged  bool
+	shown   int
+	at      time.Time
+}
+
+// newLinePipeline returns the stages received lines pass through in script context, from line hooks to display.
+func (self *Client) newLinePipeline() *pipeline {
+	return newPipeline("line", self.stageDisabled,
+		newStage("hooks", stageFunc(func(raw []byte, emit func([]byte)) {
+			r := self.received
+			if len(self.hooks["line"]) == 0 {
+				emit(raw)
+				return
+			}
+			line, newRaw, dropped := self.runLineHooks(r.line, string(raw))
+			if dropped {
+				if r.shown > 0 {
+					self.OutputLines([]string{""})
+				}
+				return
+			}
+			r.line, r.text = line, line
+			emit([]byte(newRaw))
+		})),
+		newStage("observers", stageFunc(func(raw []byte, emit func([]byte)) {
+			r := self.received
+			self.runWaits(r.line)
+			self.target().rememberLine(r.line)
+			if self.roomMap != nil {
+				if err := self.roomMap.see(self.target(), r.line); err != nil {
+					self.OutputErrorf("Unable to save the map: %v\n", err)
+				}
+			}
+			self.runMultiTriggers()
+			emit(raw)
+		})),
+		newStage("hardgags", stageFunc(func(raw []byte, emit func([]byte)) {
+			if r := self.received; self.gagged(r.line, true) {
+				if r.shown > 0 {
+					self.OutputLines([]string{""})
+				}
+				return
+			}
+			emit(raw)
+		})),
+		newStage("triggers", stageFunc(func(raw []byte, emit func([]byte)) {
+			r := self.received
+			if r.text, r.changed, r.gagged = self.runTriggers(r.line); r.changed {
+				raw = []byte(expandMarkup(r.text))
+			}
+			emit(raw)
+		})),
+		// Lines shown in part before they were complete only get the rest of them shown.
+		fixedStage("partial", stageFunc(func(raw []byte, emit func([]byte)) {
+			if r := self.received; r.shown > 0 {
+				if r.shown < len(raw) {
+					self.OutputLines([]string{string(raw[r.shown:])})
+				} else {
+					self.OutputLines([]string{""})
+				}
+				return
+			}
+			emit(raw)
+		})),
+		newStage("gags", stageFunc(func(raw []byte, emit func([]byte)) {
+			if r := self.received; r.gagged || self.gagged(r.line, false) {
+				return
+			}
+			emit(raw)
+		})),
+		newStage("subs", stageFunc(func(raw []byte, emit func([]byte)) {
+			r := self.received
+			if substituted, found := self.substitute(r.text); found {
+				r.text, r.changed = substituted, true
+				raw = []byte(expandMarkup(substituted))
+			}
+			emit(raw)
+		})),
+		newStage("highlights", stageFunc(func(raw []byte, emit func([]byte)) {
+			emit([]byte(applyHighlights(string(raw), append([]*highlightRule{urlHighlight}, self.highlights...))))
+		})),
+		newStage("timestamps", stageFunc(func(raw []byte, emit func([]byte)) {
+			emit([]byte(self.timestamp(self.received.at) + string(raw)))
+		})),
+		fixedStage("display", stageFunc(func(raw []byte, emit func([]byte)) {
+			r := self.received
+			self.rememberURLs(r.line, r.at)
+			if self.capture(r.line, string(raw)) {
+				self.OutputLines([]string{string(raw)})
+			} else {
+				self.redraw()
+			}
+			emit(raw)
+		})),
+	)
+}
+
+// processLine runs a received line through the line pipeline.
+func (self *Client) processLine(raw string, shown int, at time.Time) {
+	old, oldReceived := self.origin, self.received
+	raw = strings.TrimRight(raw, "\r")
+	line := stripANSI(raw)
+	self.origin = originTrigger
+	self.received = &receivedLine{
+		line:  line,
+		text:  line,
+		shown: shown,
+		at:    at,
+	}
+	defer func() {
+		self.origin, self.received = old, oldReceived
+	}()
+	self.linePipeline.run([]byte(raw), func([]byte) {})
+}
@@ -0,0 +1,147 @@
+package client
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBatchKeepsOrder(t *testing.T) {
+	c := New()
+	s := c.activeSession()
+	s.outputBytes([]byte("prompt> "))
+	s.batch(func() {
+		s.outputLines([]string{"first"})
+		s.outputf("notice\n")
+		s.outputLines([]string{"second", "third"})
+		if got := len(s.output()); got != 2 {
+			t.Errorf("%v lines stored before the batch ended, want 2", got)
+		}
+	})
+	want := []string{"prompt> first", "notice", "second", "third"}
+	if got := s.output(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %q, want %q", got, want)
+	}
+}
+
+func TestHeadlessReceiveMany(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := attachServer(t, c)
+	lines := []string{}
+	for i := 0; i < 1000; i++ {
+		lines = append(lines, fmt.Sprintf("line %v", i))
+	}
+	server.sendChunked(100, lines...)
+	waitOutput(t, c, "line 999")
+	if got := c.Output(); !reflect.DeepEqual(got[len(got)-len(lines):], lines) {
+		t.Errorf("Lines out of order: %q", got)
+	}
+}
+
+// sendChunked writes lines to the client like send, perWrite lines at a time, so that the client reads many of them at once.
+func (self *testServer) sendChunked(perWrite int, lines ...string) {
+	go func() {
+		for len(lines) > 0 {
+			n := perWrite
+			if n > len(lines) {
+				n = len(lines)
+			}
+			if _, err := self.conn.Write([]byte(strings.Join(lines[:n], "\r\n") + "\r\n")); err != nil {
+				return
+			}
+			lines = lines[n:]
+		}
+	}()
+}
+
+// shownLast tells whether the last line stored in the active session of c ends with suffix, without copying them all like Output.
+func shownLast(c *Client, suffix string) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	lines := c.active.lines
+	return len(lines) > 0 && strings.HasSuffix(lines[len(lines)-1], suffix)
+}
+
+// BenchmarkOutputFlood shows 100000 received lines in a session, formatting each with Outputf as received text used to be shown, or
+// storing them 100 at a time with OutputLines as reads are shown now, and logs how much faster the latter is against the goal of 10x.
+func BenchmarkOutputFlood(b *testing.B) {
+	const count, perRead, goal = 100000, 100, 10
+	lines := []string{}
+	for i := 0; i < count; i++ {
+		lines = append(lines, fmt.Sprintf("\033[32mA goblin\033[0m attacks you, line %v.", i))
+	}
+	perLine := map[string]float64{}
+	shown := map[string][]string{}
+	flood := func(name string, show func(c *Client)) {
+		b.Run(name, func(b *testing.B) {
+			c := New()
+			for i := 0; i < b.N; i++ {
+				c = New()
+				show(c)
+			}
+			perLine[name] = b.Elapsed().Seconds() / float64(count*b.N)
+			shown[name] = c.Output()
+			b.ReportMetric(1/perLine[name], "lines/s")
+		})
+	}
+	flood("Outputf", func(c *Client) {
+		for _, line := range lines {
+			c.Outputf("%s\n", line)
+		}
+	})
+	flood("OutputLines", func(c *Client) {
+		for start := 0; start < count; start += perRead {
+			c.OutputLines(lines[start : start+perRead])
+		}
+	})
+	if !reflect.DeepEqual(shown["Outputf"], shown["OutputLines"]) {
+		b.Errorf("OutputLines shows different lines than Outputf")
+	}
+	if perLine["Outputf"] == 0 || perLine["OutputLines"] == 0 {
+		return
+	}
+	speedup := perLine["Outputf"] / perLine["OutputLines"]
+	verdict := "met"
+	if speedup < goal {
+		verdict = "missed"
+	}
+	b.Logf("before (Outputf per line): %.0f lines/s, after (OutputLines per %v lines): %.0f lines/s, %.1fx faster: goal of %vx %v",
+		1/perLine["Outputf"], perRead, 1/perLine["OutputLines"], speedup, goal, verdict)
+}
+
+func TestHeadlessPartialLine(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := attachServer(t, c)
+	go server.conn.Write([]byte("Welcome!\r\nWhat is your name? "))
+	// A prompt without a newline shows once the connection has been idle for a moment.
+	waitOutput(t, c, "What is your name? ")
+	go server.conn.Write([]byte("Tester\r\nHello Tester.\r\n"))
+	waitOutput(t, c, "Hello Tester.")
+	if got, want := c.Output(), []string{"Welcome!", "What is your name? Tester", "Hello Tester."}; !reflect.DeepEqual(got[len(got)-3:], want) {
+		t.Errorf("Got %q, want it to end with %q", got, want)
+	}
+}
+
+// BenchmarkReceiveFile dumps a megabyte of text through a loopback connection, and measures how long it takes to be shown.
+func BenchmarkReceiveFile(b *testing.B) {
+	text := &strings.Builder{}
+	for i := 0; text.Len() < 1<<20; i++ {
+		fmt.Fprintf(text, "%v: The quick brown fox jumps over the lazy dog.\r\n", i)
+	}
+	data := []byte(text.String())
+	lines := strings.Split(strings.TrimSpace(text.String()), "\r\n")
+	last := strings.TrimSuffix(lines[len(lines)-1], "\r")
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		c, _ := startHeadless(b)
+		server := connectServer(b, c)
+		b.StartTimer()
+		go server.conn.Write(data)
+		for !shownLast(c, last) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"time"
+
+	"github.com/zond/gocui"
+)
+
+const frameInterval = time.Second / 60
+
+// redraw asks for the gui to be flushed. The first request after a quiet period flushes at once, those arriving while flushing
+// coalesce into a single flush a frame later.
+func (self *Client) redraw() {
+	select {
+	case self.redraws <- struct{}{}:
+	default:
+	}
+}
+
+// guiHandler makes handler hold the gui locks. gocui runs key handlers, its editor and a flush after every key on its main loop, and has
+// no way of running code there for other goroutines, so flushLoop flushes from its own goroutine instead. Everything writing views
+// holds the gui lock, layout included, and handlers and the editor also hold the flush lock, which keeps them out while flushLoop
+// draws. gocui drawing the views after its own layout has no hook to lock in, so that is left unserialized.
+func (self *Client) guiHandler(handler gocui.KeybindingHandler) gocui.KeybindingHandler {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		self.flushLock.Lock()
+		defer self.flushLock.Unlock()
+		self.guiLock.Lock()
+		defer self.guiLock.Unlock()
+		return handler(g, v)
+	}
+}
+
+// guiEditor makes editor hold the gui locks like guiHandler does for key handlers.
+func (self *Client) guiEditor(editor gocui.Editor) gocui.Editor {
+	return gocui.EditorFunc(func(v *gocui.View, key gocui.Key, ch rune, mod gocui.Modifier) {
+		self.flushLock.Lock()
+		defer self.flushLock.Unlock()
+		self.guiLock.Lock()
+		defer self.guiLock.Unlock()
+		editor.Edit(v, key, ch, mod)
+	})
+}
+
+func (self *Client) flushLoop() {
+	for {
+		select {
+		case <-self.closing:
+			return
+		case <-self.redraws:
+			self.flushLock.Lock()
+			self.gui.Flush()
+			self.flushLock.Unlock()
+			time.Sleep(frameInterval)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zond/gocui"
+)
+
+// countingDisplay counts the flushes of the display it wraps.
+type countingDisplay struct {
+	display
+	flushes atomic.Int64
+	flushed chan time.Time
+}
+
+func (self *countingDisplay) Flush() error {
+	self.flushes.Add(1)
+	select {
+	case self.flushed <- time.Now():
+	default:
+	}
+	return self.display.Flush()
+}
+
+func startCounting(t *testing.T) (c *Client, counter *countingDisplay) {
+	t.Setenv("HOME", t.TempDir())
+	c = NewHeadless(80, 24, WithStartupScript(""))
+	counter = &countingDisplay{display: c.gui, flushed: make(chan time.Time, 1)}
+	c.gui = counter
+	done := make(chan struct{})
+	go func() {
+		c.Run()
+		close(done)
+	}()
+	t.Cleanup(func() {
+		c.Close()
+		<-done
+	})
+	<-c.started
+	return
+}
+
+func TestRedrawFlood(t *testing.T) {
+	c, counter := startCounting(t)
+	const writes = 10000
+	start, before := time.Now(), counter.flushes.Load()
+	for i := 0; i < writes; i++ {
+		c.Outputf("A goblin attacks you, line %v.\n", i)
+	}
+	elapsed := time.Since(start)
+	time.Sleep(2 * frameInterval)
+	flushes := counter.flushes.Load() - before
+	t.Logf("%v writes in %v caused %v flushes, instead of one each", writes, elapsed, flushes)
+	if most := int64(elapsed/frameInterval) + 2; flushes > most {
+		t.Errorf("%v writes in %v caused %v flushes, want at most one a frame", writes, elapsed, flushes)
+	}
+	if flushes == 0 {
+		t.Errorf("The writes caused no flush")
+	}
+}
+
+// A redraw after a quiet period, like the echo of a keystroke, flushes at once rather than waiting for a frame.
+func TestRedrawLatency(t *testing.T) {
+	c, counter := startCounting(t)
+	for i := 0; i < 10; i++ {
+		time.Sleep(2 * frameInterval)
+		select {
+		case <-counter.flushed:
+		default:
+		}
+		redrawn := time.Now()
+		c.redraw()
+		select {
+		case flushed := <-counter.flushed:
+			if latency := flushed.Sub(redrawn); latency > frameInterval {
+				t.Errorf("Flushed %v after the redraw, want within a frame", latency)
+			}
+		case <-time.After(headlessTimeout):
+			t.Fatal("Timed out waiting for a flush")
+		}
+	}
+}
+
+// Run with -race: typing through the editor and pressing keys, each followed by a flush like on the gocui main loop, must not race
+// with output and the flushes of flushLoop.
+func TestMainLoopFlush(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := attachServer(t, c)
+	done := make(chan struct{})
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+				c.Outputf("A goblin attacks you, line %v.\n", i)
+			}
+		}
+	}()
+	defer close(done)
+	for i := 0; i < 20; i++ {
+		line := fmt.Sprintf("kick goblin %v", i)
+		if err := c.Type(line); err != nil {
+			t.Fatal(err)
+		}
+		if text, err := c.View("input"); err != nil || strings.TrimSpace(text) != line {
+			t.Fatalf("Input view is %q, %v after typing %q", text, err, line)
+		}
+		if err := c.Press(gocui.KeyEnter, 0); err != nil {
+			t.Fatal(err)
+		}
+		server.expect(line)
+	}
+}
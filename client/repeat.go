@@ -0,0 +1,64 @@
+package client
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/robertkrimen/otto"
+)
+
+// maxRepeat is the highest count a #N prefix accepts.
+const maxRepeat = 100
+
+var repeatPattern = regexp.MustCompile(`^#(\d+)\s+(.*)$`)
+
+// parseRepeat splits a line like "#5 kick" into the count and the command, returning a count of 1 for lines without a prefix.
+func parseRepeat(line string) (count int, command string, err error) {
+	match := repeatPattern.FindStringSubmatch(line)
+	if match == nil {
+		return 1, line, nil
+	}
+	count, err = strconv.Atoi(match[1])
+	if err != nil || count < 1 || count > maxRepeat {
+		return 0, "", fmt.Errorf("Invalid repeat count %#v, use 1 to %v", match[1], maxRepeat)
+	}
+	return count, match[2], nil
+}
+
+// repeat executes line count times, echoing what it sends once with the count instead of count times.
+func (self *Client) repeat(line string, count int) {
+	self.lock.Lock()
+	self.echoSuffix = fmt.Sprintf(" (x%v)", count)
+	self.lock.Unlock()
+	defer func() {
+		self.lock.Lock()
+		self.echoSuffix, self.echoMuted = "", false
+		self.lock.Unlock()
+	}()
+	for i := 0; i < count; i++ {
+		self.execute(line)
+		if i == 0 {
+			self.lock.Lock()
+			self.echoMuted = true
+			self.lock.Unlock()
+		}
+	}
+}
+
+func (self *Client) bindRepeat() {
+	self.bind("repeatEmpty(enabled)", "Get or set whether Enter on an empty input line sends the previous line again instead of an empty line.", func(call otto.FunctionCall) (result otto.Value) {
+		self.lock.Lock()
+		defer self.lock.Unlock()
+		if arg := call.Argument(0); arg.IsDefined() {
+			enabled, err := arg.ToBoolean()
+			if err != nil {
+				result, _ = otto.ToValue(fmt.Errorf("Invalid repeatEmpty setting %#v", arg.String()))
+				return
+			}
+			self.repeatEmpty = enabled
+		}
+		result, _ = otto.ToValue(self.repeatEmpty)
+		return
+	})
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+)
+
+const (
+	startupScriptName = ".mug.js"
+)
+
+func mugDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".mug"
+	}
+	return filepath.Join(home, ".mug")
+}
+
+func defaultStartupScript() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return startupScriptName
+	}
+	return filepath.Join(home, startupScriptName)
+}
+
+// describeError includes the source location otto records for runtime errors.
+func describeError(err error) string {
+	if ottoErr, ok := err.(*otto.Error); ok {
+		return ottoErr.String()
+	}
+	return err.Error()
+}
+
+// currentOwner returns the outermost file being loaded, which registrations made while loading get tagged with.
+func (self *Client) currentOwner() string {
+	if len(self.loading) > 0 {
+		return self.loading[0]
+	}
+	return ""
+}
+
+// resolvePath resolves path against the directory of the file currently being loaded, or the home directory if none is.
+func (self *Client) resolvePath(path string) string {
+	home, _ := os.UserHomeDir()
+	if path == "~" {
+		return home
+	}
+	if strings.HasPrefix(path, "~/") {
+		return filepath.Join(home, path[2:])
+	}
+	if filepath.IsAbs(path) {
+		return path
+	}
+	if len(self.loading) > 0 {
+		return filepath.Join(filepath.Dir(self.loading[len(self.loading)-1]), path)
+	}
+	return filepath.Join(home, path)
+}
+
+// runFile executes the script at path. It must run in script context.
+func (self *Client) runFile(path string) (err error) {
+	if path, err = filepath.Abs(path); err != nil {
+		return
+	}
+	for _, loading := range self.loading {
+		if loading == path {
+			return fmt.Errorf("Recursive load of %v", path)
+		}
+	}
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	script, err := self.ot.Compile(path, src)
+	if err != nil {
+		return fmt.Errorf("Error parsing %v: %v", path, err)
+	}
+	self.loading = append(self.loading, path)
+	defer func() {
+		self.loading = self.loading[:len(self.loading)-1]
+	}()
+	if err = self.guard(func() (err error) {
+		_, err = self.ot.Run(script)
+		return
+	}); err != nil {
+		return fmt.Errorf("Error running %v: %v", path, describeError(err))
+	}
+	return
+}
+
+func (self *Client) runStartupScript() {
+	path := self.getConfig().startupScript
+	if path == "" || self.startupScriptMissing() {
+		return
+	}
+	if err := self.runFile(path); err != nil {
+		self.OutputErrorf("%v\n", err)
+	}
+}
+
+func (self *Client) bindScripts() {
+	self.bind("load(path)", "Run the script at path, relative to the loading script or the home directory.", func(call otto.FunctionCall) (result otto.Value) {
+		path := self.resolvePath(call.Argument(0).String())
+		if err := self.runFile(path); err != nil {
+			if len(self.loading) > 0 {
+				self.OutputErrorf("%v\n", err)
+			}
+			result, _ = otto.ToValue(err)
+			return
+		}
+		result, _ = otto.ToValue(fmt.Sprintf("Loaded %v", path))
+		return
+	})
+	self.bind("reload()", "Run the startup script again.", func(call otto.FunctionCall) (result otto.Value) {
+		path := self.getConfig().startupScript
+		if path == "" {
+			result, _ = otto.ToValue(fmt.Errorf("No startup script"))
+			return
+		}
+		if err := self.runFile(path); err != nil {
+			result, _ = otto.ToValue(err)
+			return
+		}
+		result, _ = otto.ToValue(fmt.Sprintf("Reloaded %v", path))
+		return
+	})
+}
@@ -0,0 +1,202 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+	"github.com/zond/gocui"
+)
+
+const defaultScrollback = 10000
+
+// appendOutput adds text to the line store, keeping a scrolled up view in place. It must be called with the client lock held.
+func (self *session) appendOutput(text string) {
+	self.flushBatched()
+	parts := strings.Split(self.partial+text, "\n")
+	self.partial = parts[len(parts)-1]
+	self.addLines(parts[:len(parts)-1])
+}
+
+// appendLines adds complete lines to the line store like appendOutput, the first one completing any incomplete last line. It must be
+// called with the client lock held.
+func (self *session) appendLines(added []string) {
+	self.flushBatched()
+	if self.partial != "" && len(added) > 0 {
+		added = append([]string{self.partial + added[0]}, added[1:]...)
+		self.partial = ""
+	}
+	self.addLines(added)
+}
+
+// flushBatched adds the lines held back by batch, so that other output comes after them. It must be called with the client lock held.
+func (self *session) flushBatched() {
+	if len(self.batched) > 0 {
+		lines := self.batched
+		self.batched = nil
+		self.appendLines(lines)
+	}
+}
+
+func (self *session) addLines(added []string) {
+	// A full store gets room for twice the scrollback, so trimOutput can reslice it a while before append copies the lines again.
+	if max, total := self.client.config.scrollback, len(self.lines)+len(added); total > max && total > cap(self.lines) {
+		self.lines = append(make([]string, 0, 2*max+len(added)), self.lines...)
+	}
+	self.lines = append(self.lines, added...)
+	if self.scroll > 0 {
+		self.scroll += len(added)
+		self.newLines += len(added)
+	} else {
+		self.page(added)
+	}
+	self.trimOutput(self.client.config.scrollback)
+}
+
+// trimOutput evicts the oldest lines beyond max. The scroll offset counts from the bottom, so a scrolled up view stays put until it
+// reaches the top. The lines are resliced rather than copied, since copying all of them for every line added to a full store is what
+// made floods slow, and evicted lines are freed when append next grows the slice.
+func (self *session) trimOutput(max int) {
+	if over := len(self.lines) - max; over > 0 {
+		self.lines = self.lines[over:]
+		self.evicted += over
+		if self.found -= over; self.found < 0 {
+			self.found = -1
+		}
+	}
+	if self.scroll > len(self.lines) {
+		self.scroll = len(self.lines)
+	}
+}
+
+// output returns the stored lines followed by the incomplete last line, if any.
+func (self *session) output() []string {
+	if self.partial == "" {
+		return self.lines
+	}
+	return append(self.lines[:len(self.lines):len(self.lines)], self.partial)
+}
+
+// fitLines returns how many of the lines of all, starting at from and stepping by step, fit in rows display rows when wrapped at
+// width. A line is counted even if it alone is taller than rows, so scrolling always makes progress.
+func (self *session) fitLines(all []string, from, step, width, rows int) (count int) {
+	used := 0
+	for i := from; i >= 0 && i < len(all); i += step {
+		if used += len(wrapLine(all[i], width, self.client.wrapIndent)); used > rows && count > 0 {
+			break
+		}
+		count++
+	}
+	return
+}
+
+// visibleOutput returns the last height rows of the lines ending back lines from the bottom, wrapped at width, with the last search
+// match highlighted and decorate, if given, applied to each line along with its index. Wrapping happens here rather than when lines
+// arrive, so a resize reflows everything while the scroll offset stays on the same logical line.
+func (self *session) visibleOutput(back, width, height int, decorate func(int, string) string) (result []string) {
+	all := self.output()
+	indent := self.client.wrapIndent
+	for i := len(all) - back - 1; i >= 0 && len(result) < height; i-- {
+		line := all[i]
+		if i == self.found {
+			line = ansiReverse + stripANSI(line) + ansiReset
+		}
+		if decorate != nil {
+			line = decorate(i, line)
+		}
+		result = append(wrapLine(line, width, indent), result...)
+	}
+	if len(result) > height {
+		result = result[len(result)-height:]
+	}
+	return
+}
+
+// scrolledOutput is visibleOutput at the scroll offset, with the last row replaced by a new line indicator when scrolled up past unseen
+// output that no live pane is showing.
+func (self *session) scrolledOutput(width, height int, split bool) (result []string) {
+	result = self.visibleOutput(self.scroll, width, height, nil)
+	if self.more && len(result) > 0 {
+		result[len(result)-1] = ansiReverse + self.moreMarker() + ansiReset
+	} else if self.scroll > 0 && self.newLines > 0 && !split && len(result) > 0 {
+		result[len(result)-1] = fmt.Sprintf("%v-- %v new lines --%v", ansiReverse, self.newLines, ansiReset)
+	}
+	return
+}
+
+func (self *Client) renderOutput(v *gocui.View) {
+	width, height := v.Size()
+	self.lock.Lock()
+	self.outputWidth, self.outputHeight = width, height
+	self.lock.Unlock()
+	self.lock.RLock()
+	var lines []string
+	if self.copy.active && self.copy.session == self.active {
+		lines = self.copyOutput(width, height)
+	} else {
+		lines = self.active.scrolledOutput(width, height, self.split)
+	}
+	self.lock.RUnlock()
+	v.Clear()
+	fmt.Fprint(v, strings.Join(lines, "\n"))
+}
+
+func (self *Client) scrollOutput(g *gocui.Gui, f func(sess *session, all []string, width, height int)) error {
+	width, height := 0, 0
+	if output := g.View("output"); output != nil {
+		width, height = output.Size()
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	sess := self.active
+	all := sess.output()
+	f(sess, all, width, height)
+	if top := len(all) - sess.fitLines(all, 0, 1, width, height); sess.scroll > top {
+		sess.scroll = top
+	}
+	if sess.scroll <= 0 {
+		sess.scroll, sess.newLines, sess.urgent = 0, 0, false
+		sess.more, sess.paged = false, 0
+		sess.found = -1
+	}
+	return nil
+}
+
+func (self *Client) pageUp(g *gocui.Gui, v *gocui.View) error {
+	return self.scrollOutput(g, func(sess *session, all []string, width, height int) {
+		sess.scroll += sess.fitLines(all, len(all)-sess.scroll-1, -1, width, height-1)
+	})
+}
+
+func (self *Client) pageDown(g *gocui.Gui, v *gocui.View) error {
+	return self.scrollOutput(g, func(sess *session, all []string, width, height int) {
+		sess.scroll -= sess.fitLines(all, len(all)-sess.scroll, 1, width, height-1)
+	})
+}
+
+func (self *Client) scrollTop(g *gocui.Gui, v *gocui.View) error {
+	return self.scrollOutput(g, func(sess *session, all []string, width, height int) {
+		sess.scroll = len(all)
+	})
+}
+
+func (self *Client) scrollBottom(g *gocui.Gui, v *gocui.View) error {
+	return self.scrollOutput(g, func(sess *session, all []string, width, height int) {
+		sess.scroll = 0
+	})
+}
+
+func (self *Client) bindScrollback() {
+	self.bind("scrollback(lines)", "Get or set the number of output lines kept for scrolling and searching in each session.", func(call otto.FunctionCall) (result otto.Value) {
+		if arg := call.Argument(0); arg.IsDefined() {
+			lines, err := arg.ToInteger()
+			if err != nil || lines < 1 {
+				result, _ = otto.ToValue(fmt.Errorf("Invalid scrollback size %#v", arg.String()))
+				return
+			}
+			self.Configure(WithScrollback(int(lines)))
+		}
+		result, _ = otto.ToValue(self.getConfig().scrollback)
+		return
+	})
+}
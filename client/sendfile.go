@@ -0,0 +1,144 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+const (
+	// sendFileBacklog is how many lines of a file may wait in the send queue, so large files are read as they are sent.
+	sendFileBacklog = 10
+	sendFileStatus  = "sendfile"
+	sendFilePoll    = 50 * time.Millisecond
+)
+
+// countLines returns the number of lines in r, failing if it looks binary.
+func countLines(r io.Reader) (lines int, err error) {
+	buf := make([]byte, 32*1024)
+	last := byte('\n')
+	for {
+		n, e := r.Read(buf)
+		if bytes.IndexByte(buf[:n], 0) != -1 {
+			return 0, fmt.Errorf("Refusing to send a binary file")
+		}
+		lines += bytes.Count(buf[:n], []byte{'\n'})
+		if n > 0 {
+			last = buf[n-1]
+		}
+		if e == io.EOF {
+			break
+		}
+		if e != nil {
+			return 0, e
+		}
+	}
+	if last != '\n' {
+		lines++
+	}
+	return
+}
+
+// sendFile sends the lines of the file at path through the send queue, showing progress in the status line. clearQueue cancels it.
+func (self *session) sendFile(path string) (err error) {
+	if self.getConn() == nil {
+		return fmt.Errorf("Nowhere to send %v", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	total, err := countLines(f)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("Unable to send %v: %v", path, err)
+	}
+	self.client.lock.Lock()
+	if self.upload != nil {
+		self.client.lock.Unlock()
+		f.Close()
+		return fmt.Errorf("Already sending a file, clearQueue() cancels it")
+	}
+	cancel := make(chan struct{})
+	self.upload = cancel
+	self.client.lock.Unlock()
+	go self.streamFile(f, filepath.Base(path), total, cancel)
+	return
+}
+
+func (self *session) streamFile(f *os.File, name string, total int, cancel chan struct{}) {
+	defer f.Close()
+	r := bufio.NewReader(f)
+	sent := 0
+	var err error
+	progress := func() {
+		self.client.setStatusField(sendFileStatus, fmt.Sprintf("sending %v %v/%v", name, sent, total), "")
+		self.client.redraw()
+	}
+	progress()
+	cancelled := false
+	for err == nil && !cancelled {
+		var line string
+		line, err = r.ReadString('\n')
+		if line == "" {
+			continue
+		}
+		for !cancelled && self.queueSize() >= sendFileBacklog {
+			select {
+			case <-cancel:
+				cancelled = true
+			case <-time.After(sendFilePoll):
+			}
+		}
+		select {
+		case <-cancel:
+			cancelled = true
+		default:
+		}
+		if cancelled {
+			break
+		}
+		if e := self.write(strings.TrimRight(line, "\r\n")+"\n", originSendFile); e != nil {
+			err = e
+			break
+		}
+		sent++
+		progress()
+	}
+	self.client.lock.Lock()
+	if self.upload == cancel {
+		self.upload = nil
+	}
+	self.client.lock.Unlock()
+	self.client.removeStatusFields(func(field *statusField) bool {
+		return field.key == sendFileStatus
+	})
+	self.client.redraw()
+	switch {
+	case cancelled:
+		self.outputNoticef("Cancelled sending %v after %v of %v lines\n", name, sent, total)
+	case err != nil && err != io.EOF:
+		self.outputErrorf("Stopped sending %v after %v of %v lines: %v\n", name, sent, total, err)
+	default:
+		self.outputNoticef("Sent %v lines from %v\n", sent, name)
+	}
+}
+
+func (self *Client) bindSendFile() {
+	self.bind("sendFile(path)", "Send the lines of the file at path through the send queue, paced by sendDelay. clearQueue() cancels it.", func(call otto.FunctionCall) (result otto.Value) {
+		if err := self.target().sendFile(call.Argument(0).String()); err != nil {
+			result, _ = otto.ToValue(err)
+		}
+		return
+	})
+}
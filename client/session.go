@@ -0,0 +1,752 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+	"unsafe"
+
+	"github.com/robertkrimen/otto"
+	"github.com/zond/gocui"
+)
+
+const (
+	defaultSessionName = "main"
+	readBufferSize     = 16 * 1024
+	partialTimeout     = 100 * time.Millisecond
+)
+
+// session is one named connection with its own scrollback of output lines. Its mutable fields are guarded by the client lock.
+type session struct {
+	client     *Client
+	name       string
+	host       string
+	connLock   sync.Mutex
+	connection *connection
+	// stats belong to the current connection, or the last one once it is gone. They are guarded by connLock.
+	stats         *connStats
+	telnet        unsafe.Pointer
+	echoOff       int32
+	loginPassword string
+	charset       string
+	// upload is closed to cancel the file being sent, if any. It is guarded by the client lock.
+	upload          chan struct{}
+	prompt          string
+	cancelDial      context.CancelFunc
+	cancelReconnect context.CancelFunc
+	msdp            map[string]interface{}
+	lines           []string
+	// evicted counts the lines trimmed off the top of lines.
+	evicted int
+	partial string
+	// batching is set while the lines of one read are processed, and batched holds the lines they displayed until all are done.
+	batching    bool
+	batched     []string
+	scroll      int
+	newLines    int
+	findPattern *regexp.Regexp
+	found       int
+	logger      *logger
+	autologPath string
+	logLock     sync.Mutex
+	queue       []queuedSend
+	dispatching bool
+	lastSent    time.Time
+	idleTimer   *time.Timer
+	unread      int
+	// more is set while paging holds the display, and paged counts the rows that arrived at pagedAt or shortly before since the user
+	// last had a look.
+	more    bool
+	paged   int
+	pagedAt time.Time
+	// urgent is set when a bell rings while the session is in the background or scrolled up.
+	urgent   bool
+	received int
+	recent   []string
+	pinger   pinger
+	// receive is the pipeline of the latest connection, guarded by connLock.
+	receive      *pipeline
+	sendPipeline *pipeline
+}
+
+func newSession(client *Client, name string) (result *session) {
+	result = &session{
+		client:  client,
+		name:    name,
+		msdp:    map[string]interface{}{},
+		found:   -1,
+		charset: charsetUTF8,
+	}
+	result.sendPipeline = newPipeline("send", client.stageDisabled,
+		newStage("charset", stageFunc(func(data []byte, emit func([]byte)) {
+			emit([]byte(encodeCharset(result.getCharset(), string(data))))
+		})),
+		fixedStage("telnet", stageFunc(func(data []byte, emit func([]byte)) {
+			emit(bytes.Replace(data, []byte{telnetIAC}, []byte{telnetIAC, telnetIAC}, -1))
+		})),
+	)
+	return
+}
+
+func (self *session) getReceivePipeline() *pipeline {
+	self.connLock.Lock()
+	defer self.connLock.Unlock()
+	return self.receive
+}
+
+func (self *Client) findSession(name string) *session {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	for _, sess := range self.sessions {
+		if sess.name == name {
+			return sess
+		}
+	}
+	return nil
+}
+
+func (self *Client) ensureSession(name string) *session {
+	if sess := self.findSession(name); sess != nil {
+		return sess
+	}
+	sess := newSession(self, name)
+	self.lock.Lock()
+	self.sessions = append(self.sessions, sess)
+	self.lock.Unlock()
+	return sess
+}
+
+func (self *Client) activeSession() *session {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.active
+}
+
+// target returns the session scripts currently act on: the one whose event is being handled, else the active one.
+func (self *Client) target() (result *session) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	if result = self.context; result == nil {
+		result = self.active
+	}
+	return
+}
+
+// within runs f in script context with sess as the target. The target is set under the lock, since output from other goroutines
+// looks it up too.
+func (self *Client) within(sess *session, f func()) {
+	self.lock.Lock()
+	old := self.context
+	self.context = sess
+	self.lock.Unlock()
+	defer func() {
+		self.lock.Lock()
+		self.context = old
+		self.lock.Unlock()
+	}()
+	f()
+}
+
+func (self *Client) switchSession(sess *session) {
+	self.lock.Lock()
+	self.active = sess
+	sess.unread, sess.urgent = 0, false
+	sess.paged = 0
+	self.lock.Unlock()
+	self.updateConnectionStatus()
+}
+
+// sessionTitle lists the sessions with the active one in brackets and unread line counts for the others.
+func (self *Client) sessionTitle() string {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	if len(self.sessions) < 2 {
+		return ""
+	}
+	parts := make([]string, len(self.sessions))
+	for i, sess := range self.sessions {
+		switch {
+		case sess == self.active:
+			parts[i] = fmt.Sprintf("[%v]", sess.name)
+		case sess.unread > 0:
+			parts[i] = fmt.Sprintf("%v (%v)", sess.name, sess.unread)
+		default:
+			parts[i] = sess.name
+		}
+	}
+	return " " + strings.Join(parts, " ") + " "
+}
+
+func (self *Client) sessionKey(n int) gocui.KeybindingHandler {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		self.lock.RLock()
+		var sess *session
+		if n < len(self.sessions) {
+			sess = self.sessions[n]
+		}
+		self.lock.RUnlock()
+		if sess != nil {
+			self.switchSession(sess)
+		}
+		return nil
+	}
+}
+
+func (self *session) schedule(f func()) {
+	self.client.schedule(func() {
+		self.client.within(self, f)
+	})
+}
+
+func (self *session) scheduleHook(event string, args ...interface{}) {
+	self.schedule(func() {
+		self.client.fireHook(event, args...)
+	})
+}
+
+func (self *session) outputf(format string, params ...interface{}) {
+	text := fmt.Sprintf(format, params...)
+	self.client.lock.Lock()
+	defer self.client.lock.Unlock()
+	self.appendOutput(text)
+	if self.client.active != self {
+		self.unread += strings.Count(text, "\n")
+	}
+	self.client.redraw()
+}
+
+// outputLines shows complete lines in the session, for received text that needs no formatting.
+func (self *session) outputLines(lines []string) {
+	self.client.lock.Lock()
+	defer self.client.lock.Unlock()
+	if self.client.active != self {
+		self.unread += len(lines)
+	}
+	if self.batching {
+		self.batched = append(self.batched, lines...)
+		return
+	}
+	self.appendLines(lines)
+	self.client.redraw()
+}
+
+// batch runs f, adding the lines it displays with outputLines to the line store together once it returns.
+func (self *session) batch(f func()) {
+	self.client.lock.Lock()
+	self.batching = true
+	self.client.lock.Unlock()
+	defer func() {
+		self.client.lock.Lock()
+		defer self.client.lock.Unlock()
+		self.batching = false
+		self.flushBatched()
+		self.client.redraw()
+	}()
+	f()
+}
+
+// outputBytes shows text in the session as it is, like outputf without the formatting.
+func (self *session) outputBytes(text []byte) {
+	self.client.lock.Lock()
+	defer self.client.lock.Unlock()
+	self.appendOutput(string(text))
+	if self.client.active != self {
+		self.unread += bytes.Count(text, []byte("\n"))
+	}
+	self.client.redraw()
+}
+
+func (self *session) setPrompt(prompt string) {
+	self.client.lock.Lock()
+	defer self.client.lock.Unlock()
+	self.prompt = strings.Replace(prompt, "\r", "", -1)
+	self.client.redraw()
+}
+
+func (self *session) getPrompt() string {
+	self.client.lock.RLock()
+	defer self.client.lock.RUnlock()
+	return self.prompt
+}
+
+func (self *session) getTelnet() *telnet {
+	return (*telnet)(atomic.LoadPointer(&self.telnet))
+}
+
+// connection is a dialed connection and a channel closed when it stops being the current connection of its session.
+type connection struct {
+	conn  Conn
+	done  chan struct{}
+	stats *connStats
+}
+
+// stop closes the connection. Only whoever removed it from the session slot may call it, so it happens exactly once.
+func (self *connection) stop() {
+	self.stats.finish(nil)
+	close(self.done)
+	self.conn.Close()
+}
+
+func (self *session) getConn() Conn {
+	self.connLock.Lock()
+	defer self.connLock.Unlock()
+	if self.connection != nil {
+		return self.connection.conn
+	}
+	return nil
+}
+
+// setLoginPassword makes the next time the server turns echo off send password.
+func (self *session) setLoginPassword(password string) {
+	self.client.lock.Lock()
+	defer self.client.lock.Unlock()
+	self.loginPassword = password
+}
+
+func (self *session) setPasswordMode(b bool) {
+	if b {
+		self.client.lock.Lock()
+		password := self.loginPassword
+		self.loginPassword = ""
+		self.client.lock.Unlock()
+		atomic.StoreInt32(&self.echoOff, 1)
+		if password != "" {
+			go self.write(password+"\n", originClient)
+		}
+	} else {
+		atomic.StoreInt32(&self.echoOff, 0)
+	}
+}
+
+func (self *session) passwordMode() bool {
+	return atomic.LoadInt32(&self.echoOff) == 1
+}
+
+func (self *session) send(text, origin string) (err error) {
+	if self.getConn() == nil {
+		return fmt.Errorf("Nowhere to send %#v", strings.TrimRight(text, "\n"))
+	}
+	self.echoSent(text, origin)
+	return self.write(text, origin)
+}
+
+func (self *session) writeConn(text string) (err error) {
+	conn := self.getConn()
+	if conn == nil {
+		return fmt.Errorf("Nowhere to send %#v", strings.TrimRight(text, "\n"))
+	}
+	var data []byte
+	self.sendPipeline.run([]byte(text), func(out []byte) {
+		data = append(data, out...)
+	})
+	if err = writeDeadlined(conn, data); err == nil {
+		self.countStats(func(stats *connStats) {
+			stats.sent += int64(len(data))
+		})
+	}
+	return
+}
+
+func (self *session) sendln(text, origin string) error {
+	return self.send(text+"\n", origin)
+}
+
+// setConn makes c the current connection, stopping the previous one.
+func (self *session) setConn(host string, c Conn) (result *connection) {
+	result = &connection{
+		conn:  c,
+		done:  make(chan struct{}),
+		stats: newConnStats(host),
+	}
+	self.connLock.Lock()
+	old := self.connection
+	self.connection = result
+	self.stats = result.stats
+	self.connLock.Unlock()
+	if old != nil {
+		old.stop()
+	}
+	return
+}
+
+// takeConn removes and returns the current connection if it is c, or any current connection if c is nil.
+func (self *session) takeConn(c *connection) (result *connection) {
+	self.connLock.Lock()
+	defer self.connLock.Unlock()
+	if self.connection != nil && (c == nil || self.connection == c) {
+		result, self.connection = self.connection, nil
+	}
+	return
+}
+
+func (self *session) disconnect() bool {
+	self.stopReconnect()
+	self.clearQueue()
+	self.client.lock.Lock()
+	if self.cancelDial != nil {
+		self.cancelDial()
+		self.cancelDial = nil
+	}
+	self.client.lock.Unlock()
+	c := self.takeConn(nil)
+	if c == nil {
+		return false
+	}
+	c.stop()
+	self.armAntiIdle()
+	self.client.updateConnectionStatus()
+	return true
+}
+
+func (self *session) connect(host string, opts dialOptions) (err error) {
+	host = parseScheme(host, &opts)
+	self.client.lock.Lock()
+	if opts.proxy == nil {
+		opts.proxy = self.client.proxy
+	}
+	port := self.client.defaultPort
+	if self.cancelDial != nil {
+		self.cancelDial()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	self.cancelDial = cancel
+	self.client.lock.Unlock()
+	defer cancel()
+	if host, err = normalizeAddress(host, port); err != nil {
+		return
+	}
+	var rawConn net.Conn
+	if opts.proxy != nil {
+		if rawConn, err = opts.proxy.dial(ctx, host, dialTimeout); err != nil {
+			return
+		}
+	} else {
+		var addr string
+		if rawConn, addr, err = dialDirect(ctx, host, dialTimeout); err != nil {
+			return
+		}
+		self.outputNoticef("Connected to %v via %v\n", host, addr)
+	}
+	enableKeepAlive(rawConn)
+	if ctx.Err() != nil {
+		rawConn.Close()
+		err = fmt.Errorf("Abandoned in favor of a newer connection")
+		return
+	}
+	conn := rawConn
+	if opts.tls {
+		if conn, err = dialTLS(ctx, rawConn, host, opts, dialTimeout); err != nil {
+			rawConn.Close()
+			return
+		}
+	}
+	self.attach(netConn{conn}, host, &opts)
+	return
+}
+
+// attach makes conn the current connection, naming it host. Unless opts is nil, it is redialed with opts when autoreconnect is on.
+func (self *session) attach(conn Conn, host string, opts *dialOptions) {
+	tn := newTelnet(self, conn)
+	tn.width, tn.height = self.client.gui.Size()
+	atomic.StorePointer(&self.telnet, unsafe.Pointer(tn))
+	go self.readLoop(host, opts, self.setConn(host, conn), tn)
+	self.client.lock.Lock()
+	self.host = host
+	self.client.lock.Unlock()
+	self.client.updateConnectionStatus()
+	self.rotateAutolog(true)
+	self.armAntiIdle()
+	self.scheduleHook("connect", host)
+	self.schedule(func() {
+		self.client.autoLogin(self, host)
+	})
+}
+
+func (self *session) stopReconnect() {
+	self.client.lock.Lock()
+	defer self.client.lock.Unlock()
+	if self.cancelReconnect != nil {
+		self.cancelReconnect()
+		self.cancelReconnect = nil
+	}
+}
+
+func (self *session) reconnect(host string, opts dialOptions) {
+	self.client.lock.Lock()
+	if self.cancelReconnect != nil {
+		self.cancelReconnect()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	self.cancelReconnect = cancel
+	max := self.client.reconnectMax
+	self.client.lock.Unlock()
+	delay := reconnectMin
+	for attempt := 1; ; attempt++ {
+		self.outputNoticef("Reconnecting to %#v in %v (attempt %v)\n", host, delay, attempt)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		err := self.connect(host, opts)
+		if err == nil {
+			self.outputNoticef("Reconnected to %#v\n", host)
+			self.stopReconnect()
+			return
+		}
+		self.outputErrorf("Error reconnecting to %#v: %v\n", host, err)
+		if delay *= 2; delay > max {
+			delay = max
+		}
+	}
+}
+
+type readChunk struct {
+	data  []byte
+	marks []int
+	err   error
+}
+
+// readChunks reads and decodes everything from conn, switching to and from decompression as the server asks, and closes chunks
+// after sending the error that ended the connection.
+func (self *session) readChunks(host string, c *connection, tn *telnet, receive *pipeline, chunks chan<- readChunk) {
+	defer close(chunks)
+	conn := c.conn
+	raw := bufio.NewReaderSize(countingReader{conn, c.stats}, readBufferSize)
+	var src io.Reader = raw
+	var inflater io.ReadCloser
+	buf := make([]byte, readBufferSize)
+	var err error
+	for err == nil {
+		var n int
+		self.refreshReadDeadline(conn)
+		var before int64
+		if inflater != nil {
+			c.stats.update(func() {
+				before = c.stats.received
+			})
+		}
+		n, err = src.Read(buf)
+		if inflater != nil {
+			c.stats.update(func() {
+				c.stats.inflated += int64(n)
+				receive.count("decompress", int(c.stats.received-before), n)
+			})
+		}
+		if n > 0 {
+			self.logData(buf[:n])
+			data, marks, rest := tn.decode(buf[:n])
+			receive.count("telnet", n, len(data))
+			select {
+			case chunks <- readChunk{data: data, marks: marks}:
+			case <-c.done:
+				return
+			}
+			if rest != nil {
+				raw = bufio.NewReaderSize(io.MultiReader(bytes.NewReader(rest), raw), readBufferSize)
+				if inflater, err = zlib.NewReader(raw); err != nil {
+					self.outputErrorf("Corrupt compressed stream from %#v: %v\n", host, err)
+					conn.Close()
+					break
+				}
+				src = inflater
+				c.stats.startCompression(len(rest))
+				tn.setCompressing(true)
+			}
+		}
+		if inflater != nil && err != nil {
+			inflater.Close()
+			inflater = nil
+			src = raw
+			c.stats.stopCompression()
+			tn.setCompressing(false)
+			if err == io.EOF {
+				err = nil
+			} else if _, corrupt := err.(flate.CorruptInputError); corrupt || err == zlib.ErrChecksum || err == zlib.ErrHeader {
+				self.outputErrorf("Corrupt compressed stream from %#v: %v\n", host, err)
+				conn.Close()
+			}
+		}
+	}
+	err = self.describeReadError(err)
+	c.stats.finish(err)
+	select {
+	case chunks <- readChunk{err: err}:
+	case <-c.done:
+	}
+}
+
+// readLoop splits what readChunks produces into lines and prompts. Incomplete lines are shown once the
+// connection has been idle for partialTimeout, unless the server marks its prompts. It stops as soon as c is replaced
+// or disconnected, and only reports the disconnection if c was still current.
+func (self *session) readLoop(host string, opts *dialOptions, c *connection, tn *telnet) {
+	chunks := make(chan readChunk, 16)
+	partial := []byte{}
+	pending := []byte{}
+	shown := 0
+	mxp := &mxpParser{}
+	receive := newPipeline("receive", self.client.stageDisabled,
+		externalStage("decompress"),
+		externalStage("telnet"),
+		newStage("charset", stageFunc(func(data []byte, emit func([]byte)) {
+			if charset := self.getCharset(); charset != charsetUTF8 {
+				if len(pending) > 0 {
+					emit(bytes.ToValidUTF8(pending, []byte(string(utf8.RuneError))))
+					pending = nil
+				}
+				emit(decodeCharset(charset, data))
+				return
+			}
+			data, pending = decodeUTF8(pending, data)
+			emit(data)
+		})),
+		newStage("bells", stageFunc(func(data []byte, emit func([]byte)) {
+			var rang bool
+			if data, rang = takeBells(data); rang {
+				self.ring()
+			}
+			emit(data)
+		})),
+		newStage("mxp", stageFunc(func(data []byte, emit func([]byte)) {
+			if tn.enabled(optMXP) {
+				data = mxp.filter(data)
+				for _, link := range mxp.takeLinks() {
+					link := link
+					self.schedule(func() {
+						self.client.rememberLink(link)
+					})
+				}
+			}
+			emit(data)
+		})),
+		fixedStage("lines", stageFunc(func(data []byte, emit func([]byte)) {
+			partial = append(partial, data...)
+			for i := bytes.IndexByte(partial, '\n'); i != -1; i = bytes.IndexByte(partial, '\n') {
+				emit(partial[:i])
+				partial, shown = partial[i+1:], 0
+			}
+		})),
+	)
+	self.connLock.Lock()
+	self.receive = receive
+	self.connLock.Unlock()
+	go self.readChunks(host, c, tn, receive, chunks)
+	// feed processes the lines completed by data together, and shown is only ever set for the first of them.
+	feed := func(data []byte) {
+		lines, first := []string{}, shown
+		receive.run(data, func(line []byte) {
+			lines = append(lines, string(line))
+		})
+		self.receiveLines(lines, first)
+	}
+	var idle <-chan time.Time
+	var err error
+	for done := false; !done; {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				done = true
+				break
+			}
+			if chunk.err != nil {
+				err = chunk.err
+				break
+			}
+			start := 0
+			for _, mark := range chunk.marks {
+				self.pong(pingPrompt)
+				feed(chunk.data[start:mark])
+				self.setPrompt(string(partial))
+				partial, shown = partial[:0], 0
+				start = mark
+			}
+			feed(chunk.data[start:])
+			idle = nil
+			if !tn.usesPrompts() && len(partial) > shown {
+				idle = time.After(partialTimeout)
+			}
+		case <-idle:
+			idle = nil
+			self.receivePartial(string(partial[shown:]), shown)
+			shown = len(partial)
+		case <-c.done:
+			done = true
+		}
+	}
+	tn.close()
+	atomic.CompareAndSwapPointer(&self.telnet, unsafe.Pointer(tn), nil)
+	current := self.takeConn(c) != nil
+	if current || self.getConn() == nil {
+		partial = append(partial, bytes.ToValidUTF8(pending, []byte(string(utf8.RuneError)))...)
+		if len(partial) > 0 {
+			self.receiveLine(string(partial), shown)
+		}
+		self.setPrompt("")
+		self.clearQueue()
+		self.cancelWaits()
+	}
+	if current {
+		c.stop()
+		self.armAntiIdle()
+		self.client.updateConnectionStatus()
+		self.schedule(func() {
+			self.outputNoticef("Disconnected from %#v: %v\n", host, err)
+		})
+		self.scheduleHook("disconnect", host, fmt.Sprint(err))
+		self.client.lock.RLock()
+		autoReconnect := self.client.autoReconnect
+		self.client.lock.RUnlock()
+		if autoReconnect && opts != nil {
+			go self.reconnect(host, *opts)
+		}
+	} else {
+		self.scheduleHook("disconnect", host, "")
+	}
+}
+
+func (self *Client) bindSessions() {
+	self.bind("session(name)", "Switch the input and output views to the session name, or return the name of the active session.", func(call otto.FunctionCall) (result otto.Value) {
+		arg := call.Argument(0)
+		if !arg.IsDefined() {
+			result, _ = otto.ToValue(self.activeSession().name)
+			return
+		}
+		sess := self.findSession(arg.String())
+		if sess == nil {
+			result, _ = otto.ToValue(fmt.Errorf("No session named %#v", arg.String()))
+			return
+		}
+		self.switchSession(sess)
+		return
+	})
+	self.bind("sessions()", "List sessions with their hosts and unread line counts.", func(call otto.FunctionCall) (result otto.Value) {
+		self.lock.RLock()
+		sessions := append([]*session{}, self.sessions...)
+		self.lock.RUnlock()
+		for i, sess := range sessions {
+			self.lock.RLock()
+			host, unread := sess.host, sess.unread
+			self.lock.RUnlock()
+			status := "not connected"
+			if sess.getConn() != nil {
+				status = "connected to " + host
+			}
+			self.Outputf("%v: %v, %v, %v unread\n", i+1, sess.name, status, unread)
+		}
+		return
+	})
+}
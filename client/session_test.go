@@ -0,0 +1,103 @@
+package client
+
+import (
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConnectRace(t *testing.T) {
+	c, _ := startHeadless(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	var acceptLock sync.Mutex
+	accepted := []net.Conn{}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			acceptLock.Lock()
+			accepted = append(accepted, conn)
+			acceptLock.Unlock()
+		}
+	}()
+	s := c.activeSession()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				switch (i + j) % 3 {
+				case 0, 1:
+					s.connect(listener.Addr().String(), dialOptions{})
+				case 2:
+					s.disconnect()
+				}
+				if conn := s.getConn(); conn != nil {
+					conn.Write([]byte("look\n"))
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	s.disconnect()
+	if s.getConn() != nil {
+		t.Fatal("Still connected after disconnecting")
+	}
+	// Every connection was closed, whether it was replaced, abandoned or disconnected.
+	acceptLock.Lock()
+	conns := append([]net.Conn{}, accepted...)
+	acceptLock.Unlock()
+	for i, conn := range conns {
+		conn.SetReadDeadline(time.Now().Add(headlessTimeout))
+		if _, err := io.Copy(io.Discard, conn); err != nil {
+			t.Errorf("Connection %v was left open: %v", i, err)
+		}
+		conn.Close()
+	}
+	if len(conns) == 0 {
+		t.Error("No connection was made")
+	}
+	// The readers of replaced and disconnected connections stopped without reporting them.
+	time.Sleep(partialTimeout)
+	if text := c.OutputText(); strings.Contains(text, "Disconnected from") {
+		t.Errorf("A replaced connection reported its disconnection: %q", text)
+	}
+}
+
+func TestAntiIdleIgnoresAutomaticSends(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := attachServer(t, c)
+	<-c.started
+	sess := c.activeSession()
+	c.lock.Lock()
+	c.antiIdleInterval, c.antiIdleCommand = 200*time.Millisecond, "look"
+	c.lock.Unlock()
+	sess.armAntiIdle()
+	// A trigger sending more often than the interval doesn't count as activity.
+	for i := 0; i < 10; i++ {
+		if err := sess.write("eat bread\n", originTrigger); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	for {
+		select {
+		case line := <-server.lines:
+			if line == "look" {
+				return
+			}
+		case <-time.After(100 * time.Millisecond):
+			t.Fatal("Sends from a trigger kept anti-idle from sending")
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// slashOperators are the characters that make slash input JavaScript rather than a command, when outside quotes.
+	slashOperators = "()=;+*!<>&|?{}[]"
+	// maxSuggestions is how many close matches an unknown command suggests.
+	maxSuggestions = 3
+)
+
+// literalPattern matches unquoted command arguments passed as the JavaScript values they spell rather than as strings.
+var literalPattern = regexp.MustCompile("^(true|false|null|-?[0-9]+(\\.[0-9]+)?)$")
+
+// slashKeywords are the words that start JavaScript statements and expressions rather than name commands, like /var x = 1 or
+// /typeof x.
+var slashKeywords = map[string]bool{
+	"await": true, "break": true, "case": true, "catch": true, "class": true, "const": true, "continue": true, "debugger": true,
+	"default": true, "delete": true, "do": true, "else": true, "export": true, "extends": true, "false": true, "finally": true,
+	"for": true, "function": true, "if": true, "import": true, "in": true, "instanceof": true, "let": true, "new": true, "null": true,
+	"return": true, "super": true, "switch": true, "this": true, "throw": true, "true": true, "try": true, "typeof": true,
+	"undefined": true, "var": true, "void": true, "while": true, "with": true, "yield": true,
+}
+
+// slashArg is a word of slash command input. A rest argument is the input from where the word starts, as it was typed.
+type slashArg struct {
+	text   string
+	quoted bool
+	rest   bool
+}
+
+// slashScript tells whether src has operators outside quotes, in which case it is JavaScript rather than command arguments. An
+// unterminated quote, like the apostrophe in "don't", quotes the rest of src.
+func slashScript(src string) bool {
+	var quote rune
+	escaped := false
+	for _, r := range src {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0 && r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case strings.ContainsRune(slashOperators, r):
+			return true
+		}
+	}
+	return false
+}
+
+// splitSlashArgs splits src into words at whitespace, where single or double quotes, with backslash escapes inside, keep spaces in a
+// word. With max above 0 it splits into at most max words, the last of which is a rest argument with the remaining input.
+func splitSlashArgs(src string, max int) (result []slashArg, err error) {
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+		if max > 0 && len(result) == max-1 {
+			rest := strings.TrimRightFunc(string(runes[i:]), unicode.IsSpace)
+			return append(result, slashArg{text: rest, rest: true}), nil
+		}
+		arg := slashArg{}
+		text := &strings.Builder{}
+		for ; i < len(runes) && !unicode.IsSpace(runes[i]); i++ {
+			r := runes[i]
+			if r != '"' && r != '\'' {
+				text.WriteRune(r)
+				continue
+			}
+			arg.quoted = true
+			closed := false
+			for i++; i < len(runes); i++ {
+				if runes[i] == r {
+					closed = true
+					break
+				}
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				text.WriteRune(runes[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("Unterminated %c quote", r)
+			}
+		}
+		arg.text = text.String()
+		result = append(result, arg)
+	}
+	return
+}
+
+// literal returns the JavaScript for passing arg, which is a string unless it is an unquoted number, boolean or null. A rest argument
+// is passed as it was typed, quotes included, unless it is a single word, like a number or a quoted string.
+func (self slashArg) literal() string {
+	if self.rest {
+		if words, err := splitSlashArgs(self.text, 0); err == nil && len(words) == 1 {
+			return words[0].literal()
+		}
+	}
+	if !self.quoted && !self.rest && literalPattern.MatchString(self.text) {
+		return self.text
+	}
+	encoded, _ := json.Marshal(self.text)
+	return string(encoded)
+}
+
+// signatureParams returns how many parameters signature has, and whether the last one takes any number of arguments.
+func signatureParams(signature string) (count int, variadic bool) {
+	open, close := strings.Index(signature, "("), strings.LastIndex(signature, ")")
+	if open == -1 || close < open {
+		return 0, true
+	}
+	params := strings.TrimSpace(signature[open+1 : close])
+	if params == "" {
+		return 0, false
+	}
+	parts := strings.Split(params, ",")
+	return len(parts), strings.HasSuffix(strings.TrimSpace(parts[len(parts)-1]), "...")
+}
+
+// slashCommand turns slash input like "connect mud.example.com:4000" into a call of the named function, with the words after it as
+// arguments. The last parameter gets the rest of the input as it was typed, so "echo don't do that" echoes all of it. Input with
+// parentheses or operators, or starting with a JavaScript keyword like var or typeof, is returned as it is, to run as JavaScript. It
+// must be called with the script lock held.
+func (self *Client) slashCommand(src string) (result string, err error) {
+	src = strings.TrimSpace(src)
+	name := src
+	if i := strings.IndexFunc(src, unicode.IsSpace); i != -1 {
+		name = src[:i]
+	}
+	if !bareCommandPattern.MatchString(name) || slashKeywords[name] || slashScript(src) {
+		return src, nil
+	}
+	count, variadic := 0, true
+	if b := self.findBinding(name); b != nil {
+		count, variadic = signatureParams(b.signature)
+	} else if kind, err := self.ot.Run("typeof " + name); err != nil || kind.String() == "undefined" {
+		return "", fmt.Errorf("Unknown command %#v%v", name, self.suggest(name))
+	} else if kind.String() != "function" {
+		return src, nil
+	}
+	if variadic {
+		count = 0
+	}
+	args, err := splitSlashArgs(src[len(name):], count)
+	if err != nil {
+		return "", err
+	}
+	literals := []string{}
+	for _, arg := range args {
+		literals = append(literals, arg.literal())
+	}
+	return fmt.Sprintf("%v(%v)", name, strings.Join(literals, ", ")), nil
+}
+
+// suggest describes the bindings with names close to name, if any.
+func (self *Client) suggest(name string) string {
+	type candidate struct {
+		name     string
+		distance int
+	}
+	candidates := []candidate{}
+	lower := strings.ToLower(name)
+	for _, b := range self.bindings {
+		other := strings.ToLower(b.name)
+		distance := editDistance(lower, other)
+		if strings.HasPrefix(other, lower) {
+			distance = 1
+		}
+		if distance <= 2 && distance < len(other) {
+			candidates = append(candidates, candidate{b.name, distance})
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+	names := []string{}
+	for i := 0; i < len(candidates) && i < maxSuggestions; i++ {
+		names = append(names, candidates[i].name)
+	}
+	return fmt.Sprintf(", did you mean %v?", strings.Join(names, " or "))
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current := make([]int, len(rb)+1)
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,155 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitSlashArgs(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		max  int
+		want []slashArg
+		err  bool
+	}{
+		{src: "", want: nil},
+		{src: "  a  b ", want: []slashArg{{text: "a"}, {text: "b"}}},
+		{src: `"hello world" x`, want: []slashArg{{text: "hello world", quoted: true}, {text: "x"}}},
+		{src: `'hello world'`, want: []slashArg{{text: "hello world", quoted: true}}},
+		{src: `"say \"hi\""`, want: []slashArg{{text: `say "hi"`, quoted: true}}},
+		{src: `"it's"`, want: []slashArg{{text: "it's", quoted: true}}},
+		{src: `pre"fix suf"fix`, want: []slashArg{{text: "prefix suffix", quoted: true}}},
+		{src: `don't`, err: true},
+		{src: `a b c d`, max: 2, want: []slashArg{{text: "a"}, {text: "b c d", rest: true}}},
+		{src: `a don't "x y`, max: 2, want: []slashArg{{text: "a"}, {text: `don't "x y`, rest: true}}},
+		{src: `"a b" c`, max: 1, want: []slashArg{{text: `"a b" c`, rest: true}}},
+	} {
+		got, err := splitSlashArgs(tc.src, tc.max)
+		if (err != nil) != tc.err {
+			t.Errorf("splitSlashArgs(%q, %v) returned error %v", tc.src, tc.max, err)
+			continue
+		}
+		if !tc.err && !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("splitSlashArgs(%q, %v) = %+v, want %+v", tc.src, tc.max, got, tc.want)
+		}
+	}
+}
+
+func TestSlashArgLiteral(t *testing.T) {
+	for _, tc := range []struct {
+		arg  slashArg
+		want string
+	}{
+		{slashArg{text: "mud.example.com:4000"}, `"mud.example.com:4000"`},
+		{slashArg{text: "42"}, "42"},
+		{slashArg{text: "-1.5"}, "-1.5"},
+		{slashArg{text: "true"}, "true"},
+		{slashArg{text: "42", quoted: true}, `"42"`},
+		{slashArg{text: "hello world", quoted: true}, `"hello world"`},
+		{slashArg{text: `say "hi"`, quoted: true}, `"say \"hi\""`},
+		{slashArg{text: `say "hi"`, rest: true}, `"say \"hi\""`},
+		{slashArg{text: "don't", rest: true}, `"don't"`},
+		{slashArg{text: `"hello world"`, rest: true}, `"hello world"`},
+		{slashArg{text: "false", rest: true}, "false"},
+		{slashArg{text: "1 2", rest: true}, `"1 2"`},
+	} {
+		if got := tc.arg.literal(); got != tc.want {
+			t.Errorf("%+v.literal() = %v, want %v", tc.arg, got, tc.want)
+		}
+	}
+}
+
+func TestSlashScript(t *testing.T) {
+	for src, want := range map[string]bool{
+		"connect mud.example.com:4000": false,
+		"echo don't":                   false,
+		"echo \"a = b\"":               false,
+		"echo 'a \\' = b'":             false,
+		"x = 1":                        true,
+		"print(1)":                     true,
+		"echo a; echo b":               true,
+		"echo \"a\" + x":               true,
+	} {
+		if got := slashScript(src); got != want {
+			t.Errorf("slashScript(%q) = %v, want %v", src, got, want)
+		}
+	}
+}
+
+func TestSignatureParams(t *testing.T) {
+	for signature, want := range map[string]struct {
+		count    int
+		variadic bool
+	}{
+		"disconnect()":                    {0, false},
+		"echo(text)":                      {1, false},
+		"connect(session, host, options)": {3, false},
+		"report(names...)":                {1, true},
+		"noparens":                        {0, true},
+	} {
+		count, variadic := signatureParams(signature)
+		if count != want.count || variadic != want.variadic {
+			t.Errorf("signatureParams(%q) = %v, %v, want %v, %v", signature, count, variadic, want.count, want.variadic)
+		}
+	}
+}
+
+func TestSlashCommand(t *testing.T) {
+	c := &Client{bindings: []*binding{
+		{name: "connect", signature: "connect(session, host, options)"},
+		{name: "echo", signature: "echo(text)"},
+		{name: "alias", signature: "alias(name, expansion)"},
+		{name: "disconnect", signature: "disconnect(session)"},
+		{name: "report", signature: "report(names...)"},
+	}}
+	for _, tc := range []struct {
+		src  string
+		want string
+		err  bool
+	}{
+		{src: "connect mud.example.com:4000", want: `connect("mud.example.com:4000")`},
+		{src: "connect main mud.example.com 4000", want: `connect("main", "mud.example.com", 4000)`},
+		{src: `connect "my session" mud.example.com:4000`, want: `connect("my session", "mud.example.com:4000")`},
+		{src: "disconnect", want: "disconnect()"},
+		{src: "echo hello world", want: `echo("hello world")`},
+		{src: "echo don't", want: `echo("don't")`},
+		{src: `echo say "hi there"`, want: `echo("say \"hi there\"")`},
+		{src: `echo "hi there"`, want: `echo("hi there")`},
+		{src: `alias k kill "big rat"`, want: `alias("k", "kill \"big rat\"")`},
+		{src: `alias "k r" kill rat`, want: `alias("k r", "kill rat")`},
+		{src: `alias k'x kill rat`, err: true},
+		{src: `report "a b" c`, want: `report("a b", "c")`},
+		{src: `echo("hi")`, want: `echo("hi")`},
+		{src: "x = 1", want: "x = 1"},
+		{src: "typeof x", want: "typeof x"},
+		{src: "delete x", want: "delete x"},
+		{src: "new Foo(1)", want: "new Foo(1)"},
+		{src: "var x = 1", want: "var x = 1"},
+		{src: "void 0", want: "void 0"},
+	} {
+		got, err := c.slashCommand(tc.src)
+		if (err != nil) != tc.err {
+			t.Errorf("slashCommand(%q) returned error %v", tc.src, err)
+			continue
+		}
+		if !tc.err && got != tc.want {
+			t.Errorf("slashCommand(%q) = %v, want %v", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"connect", "connect", 0},
+		{"conect", "connect", 1},
+		{"cnonect", "connect", 2},
+		{"", "abc", 3},
+	} {
+		if got := editDistance(tc.a, tc.b); got != tc.want {
+			t.Errorf("editDistance(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	socks5Version      = 5
+	socks5NoAuth       = 0
+	socks5UserPass     = 2
+	socks5NoAcceptable = 0xff
+	socks5Connect      = 1
+	socks5IPv4         = 1
+	socks5Domain       = 3
+	socks5IPv6         = 4
+)
+
+var socks5Replies = map[byte]string{
+	1: "general SOCKS server failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+type proxyError struct {
+	proxy string
+	err   error
+}
+
+func (self proxyError) Error() string {
+	return fmt.Sprintf("Proxy %v: %v", self.proxy, self.err)
+}
+
+type socks5Proxy struct {
+	addr     string
+	username string
+	password string
+}
+
+func (self *socks5Proxy) String() string {
+	return "socks5://" + self.addr
+}
+
+func parseProxy(uri string) (result *socks5Proxy, err error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return
+	}
+	if u.Scheme != "socks5" {
+		err = fmt.Errorf("Unsupported proxy scheme %#v", u.Scheme)
+		return
+	}
+	result = &socks5Proxy{
+		addr: u.Host,
+	}
+	if u.User != nil {
+		result.username = u.User.Username()
+		result.password, _ = u.User.Password()
+	}
+	return
+}
+
+// dial connects to target through the proxy, giving up after timeout or when ctx is done, both for reaching the proxy and for the
+// handshake with it.
+func (self *socks5Proxy) dial(ctx context.Context, target string, timeout time.Duration) (result net.Conn, err error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", self.addr)
+	if err != nil {
+		err = proxyError{self.addr, err}
+		return
+	}
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	if err = self.handshake(conn, target); err != nil {
+		conn.Close()
+		err = proxyError{self.addr, err}
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	result = conn
+	return
+}
+
+func (self *socks5Proxy) handshake(conn net.Conn, target string) (err error) {
+	methods := []byte{socks5NoAuth}
+	if self.username != "" {
+		methods = append(methods, socks5UserPass)
+	}
+	if _, err = conn.Write(append([]byte{socks5Version, byte(len(methods))}, methods...)); err != nil {
+		return
+	}
+	reply := make([]byte, 2)
+	if _, err = io.ReadFull(conn, reply); err != nil {
+		return
+	}
+	if reply[0] != socks5Version {
+		return fmt.Errorf("Unexpected SOCKS version %v", reply[0])
+	}
+	switch reply[1] {
+	case socks5NoAuth:
+	case socks5UserPass:
+		if err = self.authenticate(conn); err != nil {
+			return
+		}
+	case socks5NoAcceptable:
+		return fmt.Errorf("No acceptable authentication method")
+	default:
+		return fmt.Errorf("Unsupported authentication method %v", reply[1])
+	}
+	host, portString, err := net.SplitHostPort(target)
+	if err != nil {
+		return
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		return
+	}
+	req := []byte{socks5Version, socks5Connect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return fmt.Errorf("Host name %#v too long", host)
+		}
+		req = append(append(req, socks5Domain, byte(len(host))), host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(append(req, socks5IPv4), ip4...)
+	} else {
+		req = append(append(req, socks5IPv6), ip.To16()...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+	if _, err = conn.Write(req); err != nil {
+		return
+	}
+	header := make([]byte, 4)
+	if _, err = io.ReadFull(conn, header); err != nil {
+		return
+	}
+	if header[1] != 0 {
+		if msg, found := socks5Replies[header[1]]; found {
+			return fmt.Errorf("Connecting to %v: %v", target, msg)
+		}
+		return fmt.Errorf("Connecting to %v: reply code %v", target, header[1])
+	}
+	var skip int
+	switch header[3] {
+	case socks5IPv4:
+		skip = net.IPv4len
+	case socks5IPv6:
+		skip = net.IPv6len
+	case socks5Domain:
+		length := []byte{0}
+		if _, err = io.ReadFull(conn, length); err != nil {
+			return
+		}
+		skip = int(length[0])
+	default:
+		return fmt.Errorf("Unknown bound address type %v", header[3])
+	}
+	_, err = io.ReadFull(conn, make([]byte, skip+2))
+	return
+}
+
+func (self *socks5Proxy) authenticate(conn net.Conn) (err error) {
+	if len(self.username) > 255 || len(self.password) > 255 {
+		return fmt.Errorf("Username or password too long")
+	}
+	req := append([]byte{1, byte(len(self.username))}, self.username...)
+	req = append(append(req, byte(len(self.password))), self.password...)
+	if _, err = conn.Write(req); err != nil {
+		return
+	}
+	reply := make([]byte, 2)
+	if _, err = io.ReadFull(conn, reply); err != nil {
+		return
+	}
+	if reply[1] != 0 {
+		return fmt.Errorf("Authentication failed")
+	}
+	return
+}
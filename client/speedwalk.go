@@ -0,0 +1,75 @@
+package client
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+)
+
+const maxSpeedwalkSteps = 100
+
+var speedwalkPattern = regexp.MustCompile("^(\\d*(ne|nw|se|sw|n|s|e|w|u|d))+$")
+var speedwalkStepPattern = regexp.MustCompile("(\\d*)(ne|nw|se|sw|n|s|e|w|u|d)")
+
+// parseSpeedwalk expands run length directions like ".4n3e2nu", or "4n3e2nu" if bare is set. Bare speedwalks need at least one
+// count so that words like "new" are left alone.
+func parseSpeedwalk(cmd string, bare bool) (steps []string, ok bool, err error) {
+	cmd = strings.TrimSpace(cmd)
+	if strings.HasPrefix(cmd, ".") {
+		cmd = cmd[1:]
+	} else if !bare || !strings.ContainsAny(cmd, "0123456789") {
+		return
+	}
+	if !speedwalkPattern.MatchString(cmd) {
+		return
+	}
+	ok = true
+	for _, match := range speedwalkStepPattern.FindAllStringSubmatch(cmd, -1) {
+		count := 1
+		if match[1] != "" {
+			count, _ = strconv.Atoi(match[1])
+		}
+		if len(steps)+count > maxSpeedwalkSteps {
+			err = fmt.Errorf("Speedwalk %#v is longer than %v steps", cmd, maxSpeedwalkSteps)
+			return
+		}
+		for i := 0; i < count; i++ {
+			steps = append(steps, match[2])
+		}
+	}
+	return
+}
+
+// speedwalk sends steps in order, echoing them once as a whole.
+func (self *Client) speedwalk(steps []string) (err error) {
+	sess := self.target()
+	if sess.getConn() == nil {
+		return fmt.Errorf("Nowhere to send %#v", strings.Join(steps, " "))
+	}
+	origin := self.currentOrigin()
+	sess.echoSent(strings.Join(steps, " "), origin)
+	for _, step := range steps {
+		if err = sess.write(step+"\n", origin); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (self *Client) bindSpeedwalk() {
+	self.bind("speedwalk(bare)", "Get or set whether speedwalks like 4n3e are expanded without the leading dot, as in .4n3e.", func(call otto.FunctionCall) (result otto.Value) {
+		if arg := call.Argument(0); arg.IsDefined() {
+			bare, err := arg.ToBoolean()
+			if err != nil {
+				result, _ = otto.ToValue(err)
+				return
+			}
+			self.bareSpeedwalk = bare
+		}
+		result, _ = otto.ToValue(self.bareSpeedwalk)
+		return
+	})
+}
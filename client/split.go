@@ -0,0 +1,77 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+	"github.com/zond/gocui"
+)
+
+const (
+	defaultSplitRatio = 0.75
+	// minPaneRows is the fewest rows, frame included, either pane of a split output area gets. Smaller areas don't split.
+	minPaneRows = 4
+)
+
+// splitRow returns the row the output area between top and bottom is split at while sess is scrolled up, or 0 if it isn't split. Paging
+// doesn't split, since the live pane would show what paging holds back.
+func (self *Client) splitRow(sess *session, top, bottom int) int {
+	self.lock.RLock()
+	scrolled := sess.scroll > 0 && !sess.more
+	ratio := self.config.splitRatio
+	self.lock.RUnlock()
+	if !scrolled || ratio <= 0 || ratio >= 1 {
+		return 0
+	}
+	rows := bottom - top
+	if rows < 2*minPaneRows {
+		return 0
+	}
+	live := int(float64(rows)*(1-ratio) + 0.5)
+	if live < minPaneRows {
+		live = minPaneRows
+	}
+	if live > rows-minPaneRows {
+		live = rows - minPaneRows
+	}
+	return bottom - live
+}
+
+// layoutLive shows the bottom of the active session below the frozen scrollback between split and bottom, or removes the live pane
+// if split is 0.
+func (self *Client) layoutLive(g *gocui.Gui, maxX, split, bottom int) error {
+	if split == 0 {
+		if g.View("live") != nil {
+			return g.DeleteView("live")
+		}
+		return nil
+	}
+	v, err := g.SetView("live", 0, split, maxX-1, bottom)
+	if err != nil && err != gocui.ErrorUnkView {
+		return err
+	}
+	width, height := v.Size()
+	self.lock.RLock()
+	lines := self.active.visibleOutput(0, width, height, nil)
+	self.lock.RUnlock()
+	v.Clear()
+	v.Title = self.viewTitle("live", "Live (End to return)")
+	fmt.Fprint(v, strings.Join(lines, "\n"))
+	return nil
+}
+
+func (self *Client) bindSplit() {
+	self.bind("split(ratio)", "Get or set the part of the output area, between 0 and 1, the frozen scrollback takes while scrolled up. The rest shows live output, and 0 turns the split off.", func(call otto.FunctionCall) (result otto.Value) {
+		if arg := call.Argument(0); arg.IsDefined() {
+			ratio, err := arg.ToFloat()
+			if err != nil || ratio < 0 || ratio > 1 {
+				result, _ = otto.ToValue(fmt.Errorf("Invalid split ratio %#v", arg.String()))
+				return
+			}
+			self.Configure(WithSplitRatio(ratio))
+		}
+		result, _ = otto.ToValue(self.getConfig().splitRatio)
+		return
+	})
+}
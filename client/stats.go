@@ -0,0 +1,129 @@
+package client
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+// connStats counts what went over a connection. The stats of the last connection of a session are kept after it has ended.
+type connStats struct {
+	lock         sync.Mutex
+	host         string
+	connected    time.Time
+	disconnected time.Time
+	received     int64
+	sent         int64
+	lines        int64
+	// compressedStart is the number of bytes received when the server started compressing, or -1 while it isn't.
+	compressedStart int64
+	compressed      int64
+	inflated        int64
+	lastError       error
+}
+
+func newConnStats(host string) *connStats {
+	return &connStats{
+		host:            host,
+		connected:       time.Now(),
+		compressedStart: -1,
+	}
+}
+
+func (self *connStats) update(f func()) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	f()
+}
+
+// startCompression notes that the last buffered bytes of what was received are the start of a compressed stream.
+func (self *connStats) startCompression(buffered int) {
+	self.update(func() {
+		self.compressedStart = self.received - int64(buffered)
+	})
+}
+
+func (self *connStats) stopCompression() {
+	self.update(func() {
+		if self.compressedStart >= 0 {
+			self.compressed += self.received - self.compressedStart
+			self.compressedStart = -1
+		}
+	})
+}
+
+func (self *connStats) finish(err error) {
+	self.update(func() {
+		if self.disconnected.IsZero() {
+			self.disconnected = time.Now()
+		}
+		if err != nil && err != io.EOF {
+			self.lastError = err
+		}
+	})
+}
+
+// countingReader counts the bytes read from r as received on a connection.
+type countingReader struct {
+	r     io.Reader
+	stats *connStats
+}
+
+func (self countingReader) Read(b []byte) (n int, err error) {
+	n, err = self.r.Read(b)
+	self.stats.update(func() {
+		self.stats.received += int64(n)
+	})
+	return
+}
+
+func (self *session) getStats() *connStats {
+	self.connLock.Lock()
+	defer self.connLock.Unlock()
+	return self.stats
+}
+
+// countStats applies f to the stats of the current connection, if there is one.
+func (self *session) countStats(f func(*connStats)) {
+	if stats := self.getStats(); stats != nil {
+		stats.update(func() {
+			f(stats)
+		})
+	}
+}
+
+func (self *Client) bindStats() {
+	self.bind("stats()", "Return the counters of the current connection, or of the last one if disconnected.", func(call otto.FunctionCall) (result otto.Value) {
+		stats := self.target().getStats()
+		if stats == nil {
+			return
+		}
+		obj, _ := self.ot.Object("({})")
+		stats.update(func() {
+			end := time.Now()
+			if !stats.disconnected.IsZero() {
+				end = stats.disconnected
+			}
+			obj.Set("host", stats.host)
+			obj.Set("connected", stats.disconnected.IsZero())
+			obj.Set("connectedAt", stats.connected.Format(time.RFC3339))
+			obj.Set("uptime", end.Sub(stats.connected).Round(time.Second).String())
+			obj.Set("bytesReceived", stats.received)
+			obj.Set("bytesSent", stats.sent)
+			obj.Set("linesReceived", stats.lines)
+			compressed := stats.compressed
+			if stats.compressedStart >= 0 {
+				compressed += stats.received - stats.compressedStart
+			}
+			if compressed > 0 {
+				obj.Set("compressionRatio", float64(int64(float64(stats.inflated)/float64(compressed)*100))/100)
+			}
+			if stats.lastError != nil {
+				obj.Set("lastError", stats.lastError.Error())
+			}
+		})
+		return obj.Value()
+	})
+}
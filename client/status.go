@@ -0,0 +1,179 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robertkrimen/otto"
+	"github.com/zond/gocui"
+)
+
+const (
+	statusLeft = iota
+	statusRight
+)
+
+const (
+	statusClock      = "15:04"
+	statusFieldWidth = 24
+	statusDelimiter  = " | "
+)
+
+type statusField struct {
+	key   string
+	text  string
+	owner string
+}
+
+func (self *Client) setStatus(segment int, text string) {
+	self.lock.Lock()
+	self.status[segment] = text
+	self.lock.Unlock()
+	self.redraw()
+}
+
+// updateConnectionStatus shows the connection state of the active session in the left status segment.
+func (self *Client) updateConnectionStatus() {
+	sess := self.activeSession()
+	text := "disconnected"
+	if sess.getConn() != nil {
+		self.lock.RLock()
+		text = "connected " + sess.host
+		self.lock.RUnlock()
+	}
+	self.lock.RLock()
+	if len(self.sessions) > 1 {
+		text = sess.name + ": " + text
+	}
+	self.lock.RUnlock()
+	self.setStatus(statusLeft, text)
+}
+
+func truncateMiddle(s string, width int) string {
+	if stringWidth(s) <= width {
+		return s
+	}
+	if width < 1 {
+		return ""
+	}
+	head, used := truncateWidth(s, (width-1)/2)
+	tail, _ := truncateWidthLeft(s, width-1-used)
+	return head + "…" + tail
+}
+
+// fitStatus right aligns right against left within width, truncating the middle of left first and then of right.
+func fitStatus(left, right string, width int) string {
+	if stringWidth(right) > width {
+		return truncateMiddle(right, width)
+	}
+	room := width - stringWidth(right) - 1
+	if room < 0 {
+		room = 0
+	}
+	left = truncateMiddle(left, room)
+	return left + strings.Repeat(" ", width-stringWidth(left)-stringWidth(right)) + right
+}
+
+func (self *Client) renderStatus(width int) string {
+	self.lock.RLock()
+	left := self.status[statusLeft]
+	for _, field := range self.statusFields {
+		left += statusDelimiter + truncateMiddle(field.text, statusFieldWidth)
+	}
+	right := []string{}
+	if self.status[statusRight] != "" {
+		right = append(right, self.status[statusRight])
+	}
+	unread, urgent := 0, false
+	for _, sess := range self.sessions {
+		unread += sess.unseen()
+		urgent = urgent || sess.urgent
+	}
+	scroll, queued, more := self.active.scroll, len(self.active.queue), self.active.more
+	marker := self.active.moreMarker()
+	self.lock.RUnlock()
+	if queued > 0 {
+		right = append(right, fmt.Sprintf("%v queued", queued))
+	}
+	if more {
+		right = append(right, marker)
+	} else if scroll > 0 {
+		right = append(right, fmt.Sprintf("scrolled %v up", scroll))
+	}
+	if unread > 0 {
+		text := fmt.Sprintf("+%v new", unread)
+		if urgent {
+			text += " (bell)"
+		}
+		right = append(right, text)
+	}
+	right = append(right, time.Now().Format(statusClock))
+	return fitStatus(" "+left, strings.Join(right, statusDelimiter)+" ", width)
+}
+
+// layoutStatus places the frameless status line on row y, only redrawing it when its contents change.
+func (self *Client) layoutStatus(g *gocui.Gui, y int) error {
+	maxX, _ := self.gui.Size()
+	v, err := g.SetView("status", -1, y-1, maxX, y+1)
+	if err != nil {
+		if err != gocui.ErrorUnkView {
+			return err
+		}
+		v.Frame = false
+		self.statusRendered = ""
+	}
+	v.FgColor, v.BgColor = self.statusColors()
+	if self.flashing() {
+		v.BgColor = gocui.ColorRed
+	}
+	if text := self.renderStatus(maxX); text != self.statusRendered {
+		self.statusRendered = text
+		v.Clear()
+		fmt.Fprint(v, text)
+	}
+	return nil
+}
+
+func (self *Client) bindStatus() {
+	obj, _ := self.ot.Object("({})")
+	self.bindMethod(obj, "status", "set(key, text)", "Show text in the status line, replacing the field key if it is already shown.", func(call otto.FunctionCall) (result otto.Value) {
+		self.setStatusField(call.Argument(0).String(), call.Argument(1).String(), self.currentOwner())
+		return
+	})
+	self.bindMethod(obj, "status", "remove(key)", "Remove the field key from the status line.", func(call otto.FunctionCall) (result otto.Value) {
+		self.removeStatusFields(func(field *statusField) bool {
+			return field.key == call.Argument(0).String()
+		})
+		return
+	})
+	self.ot.Set("status", obj)
+}
+
+func (self *Client) setStatusField(key, text, owner string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for _, field := range self.statusFields {
+		if field.key == key {
+			field.text = text
+			return
+		}
+	}
+	self.statusFields = append(self.statusFields, &statusField{
+		key:   key,
+		text:  text,
+		owner: owner,
+	})
+}
+
+func (self *Client) removeStatusFields(match func(*statusField) bool) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	fields := []*statusField{}
+	for _, field := range self.statusFields {
+		if !match(field) {
+			fields = append(fields, field)
+		}
+	}
+	self.statusFields = fields
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+const storeFileName = "store.json"
+
+// store is the persistent key-value store of scripts, kept as JSON text per key so values survive exactly as JSON.stringify made them.
+// It is only used in script context.
+type store struct {
+	path   string
+	values map[string]json.RawMessage
+}
+
+// loadStore reads the store at path. A corrupt file is moved aside, and a warning returned along with an empty store.
+func loadStore(path string) (result *store, warning error) {
+	result = &store{
+		path:   path,
+		values: map[string]json.RawMessage{},
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			warning = fmt.Errorf("Unable to read %v, starting with an empty store: %v", path, err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &result.values); err != nil {
+		result.values = map[string]json.RawMessage{}
+		backup := fmt.Sprintf("%v.corrupt-%v", path, time.Now().Format("20060102-150405"))
+		if renameErr := os.Rename(path, backup); renameErr != nil {
+			warning = fmt.Errorf("Corrupt %v (%v), unable to move it aside: %v", path, err, renameErr)
+		} else {
+			warning = fmt.Errorf("Corrupt %v (%v), moved it to %v and started an empty store", path, err, backup)
+		}
+	}
+	return
+}
+
+func (self *store) save() (err error) {
+	data, err := json.MarshalIndent(self.values, "", "  ")
+	if err != nil {
+		return
+	}
+	return writeFileAtomic(self.path, data, 0600)
+}
+
+func (self *Client) bindStore() {
+	var warning error
+	self.store, warning = loadStore(filepath.Join(mugDir(), storeFileName))
+	if warning != nil {
+		self.OutputErrorf("%v\n", warning)
+	}
+	obj, _ := self.ot.Object("({})")
+	self.bindMethod(obj, "store", "set(key, value)", "Remember value, which has to be JSON serializable, under key across restarts.", func(call otto.FunctionCall) (result otto.Value) {
+		key := call.Argument(0).String()
+		encoded, err := self.ot.Call("JSON.stringify", nil, call.Argument(1))
+		if err != nil || !encoded.IsString() {
+			result, _ = otto.ToValue(fmt.Errorf("Unable to store %#v, its value is not JSON serializable", key))
+			return
+		}
+		self.store.values[key] = json.RawMessage(encoded.String())
+		if err := self.store.save(); err != nil {
+			result, _ = otto.ToValue(fmt.Errorf("Unable to save %v: %v", self.store.path, err))
+		}
+		return
+	})
+	self.bindMethod(obj, "store", "get(key, default)", "Return the value stored under key, or default if there is none.", func(call otto.FunctionCall) (result otto.Value) {
+		encoded, found := self.store.values[call.Argument(0).String()]
+		if !found {
+			return call.Argument(1)
+		}
+		result, err := self.ot.Call("JSON.parse", nil, string(encoded))
+		if err != nil {
+			return call.Argument(1)
+		}
+		return
+	})
+	self.bindMethod(obj, "store", "delete(key)", "Forget the value stored under key.", func(call otto.FunctionCall) (result otto.Value) {
+		key := call.Argument(0).String()
+		if _, found := self.store.values[key]; !found {
+			return
+		}
+		delete(self.store.values, key)
+		if err := self.store.save(); err != nil {
+			result, _ = otto.ToValue(fmt.Errorf("Unable to save %v: %v", self.store.path, err))
+		}
+		return
+	})
+	self.bindMethod(obj, "store", "keys()", "List the stored keys.", func(call otto.FunctionCall) (result otto.Value) {
+		keys := make([]string, 0, len(self.store.values))
+		for key := range self.store.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		arr, _ := self.ot.Object("[]")
+		for i, key := range keys {
+			arr.Set(strconv.Itoa(i), key)
+		}
+		return arr.Value()
+	})
+	self.ot.Set("store", obj)
+}
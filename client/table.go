@@ -0,0 +1,91 @@
+package client
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+)
+
+// formatResult shows arrays of objects, like the ones listing triggers or aliases return, as a table, plain objects as a table of their
+// fields, and anything else as is.
+func formatResult(result otto.Value) string {
+	if result.IsObject() && result.Class() == "Object" {
+		cells := [][]string{}
+		for _, key := range result.Object().Keys() {
+			v, _ := result.Object().Get(key)
+			cells = append(cells, []string{key, v.String()})
+		}
+		if len(cells) == 0 {
+			return "{}"
+		}
+		return formatTable(cells)
+	}
+	if !result.IsObject() || result.Class() != "Array" {
+		return result.String()
+	}
+	arr := result.Object()
+	rows := []*otto.Object{}
+	columns := []string{}
+	seen := map[string]bool{}
+	for _, key := range arr.Keys() {
+		item, _ := arr.Get(key)
+		if !item.IsObject() || item.Class() == "Array" {
+			return result.String()
+		}
+		rows = append(rows, item.Object())
+		for _, column := range item.Object().Keys() {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+	if len(rows) == 0 {
+		return "(none)"
+	}
+	sort.SliceStable(columns, func(i, j int) bool {
+		return columns[i] == "id" || (columns[j] != "id" && columns[i] < columns[j])
+	})
+	cells := [][]string{columns}
+	for _, row := range rows {
+		line := []string{}
+		for _, column := range columns {
+			v, _ := row.Get(column)
+			cell := ""
+			if v.IsDefined() {
+				cell = v.String()
+			}
+			line = append(line, cell)
+		}
+		cells = append(cells, line)
+	}
+	return formatTable(cells)
+}
+
+// formatTable lines up the cells of rows in columns.
+func formatTable(rows [][]string) string {
+	widths := []int{}
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			if w := stringWidth(cell); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+	lines := []string{}
+	for _, row := range rows {
+		line := ""
+		for i, cell := range row {
+			if i < len(row)-1 {
+				cell += strings.Repeat(" ", widths[i]-stringWidth(cell)+2)
+			}
+			line += cell
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
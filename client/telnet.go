@@ -0,0 +1,365 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+const (
+	telnetEOR  = 239
+	telnetSE   = 240
+	telnetNOP  = 241
+	telnetGA   = 249
+	telnetSB   = 250
+	telnetWILL = 251
+	telnetWONT = 252
+	telnetDO   = 253
+	telnetDONT = 254
+	telnetIAC  = 255
+)
+
+const (
+	telnetIS   = 0
+	telnetSEND = 1
+)
+
+const (
+	optEcho      = 1
+	optTType     = 24
+	optEOR       = 25
+	optNAWS      = 31
+	optMSDP      = 69
+	optCompress2 = 86
+	optGMCP      = 201
+)
+
+const (
+	telnetStateData = iota
+	telnetStateCommand
+	telnetStateOption
+	telnetStateSubOption
+	telnetStateSubData
+	telnetStateSubIAC
+)
+
+type telnet struct {
+	lock    sync.Mutex
+	client  *Client
+	session *session
+	conn    io.Writer
+	state   int
+	verb    byte
+	option  byte
+	sub     []byte
+	remote  map[byte]bool
+	local   map[byte]bool
+	// pendingRemote and pendingLocal have the options scripts asked for, while waiting for the answer.
+	pendingRemote map[byte]bool
+	pendingLocal  map[byte]bool
+	width         int
+	height        int
+	ttypes        int
+	// compressStart is set once the server has sent IAC SB COMPRESS2 IAC SE, everything after which is zlib data.
+	compressStart bool
+	compressing   bool
+	// prompts is set once the server has terminated a prompt with GA or EOR.
+	prompts bool
+	// events are the latest negotiations, guarded by eventLock since subnegotiations are also sent without the lock.
+	eventLock sync.Mutex
+	events    []telnetEvent
+}
+
+func newTelnet(sess *session, conn io.Writer) *telnet {
+	return &telnet{
+		client:        sess.client,
+		session:       sess,
+		conn:          conn,
+		remote:        map[byte]bool{},
+		local:         map[byte]bool{},
+		pendingRemote: map[byte]bool{},
+		pendingLocal:  map[byte]bool{},
+	}
+}
+
+// decode returns the data in in, the offsets in it where a prompt was terminated, and whatever followed the start of compression.
+func (self *telnet) decode(in []byte) (out []byte, marks []int, rest []byte) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for i, b := range in {
+		switch self.state {
+		case telnetStateData:
+			if b == telnetIAC {
+				self.state = telnetStateCommand
+			} else {
+				out = append(out, b)
+			}
+		case telnetStateCommand:
+			switch b {
+			case telnetIAC:
+				out = append(out, b)
+				self.state = telnetStateData
+			case telnetWILL, telnetWONT, telnetDO, telnetDONT:
+				self.verb = b
+				self.state = telnetStateOption
+			case telnetSB:
+				self.sub = self.sub[:0]
+				self.state = telnetStateSubOption
+			case telnetGA, telnetEOR:
+				self.prompts = true
+				marks = append(marks, len(out))
+				self.state = telnetStateData
+			default:
+				self.state = telnetStateData
+			}
+		case telnetStateOption:
+			self.negotiate(self.verb, b)
+			self.state = telnetStateData
+		case telnetStateSubOption:
+			self.option = b
+			self.state = telnetStateSubData
+		case telnetStateSubData:
+			if b == telnetIAC {
+				self.state = telnetStateSubIAC
+			} else {
+				self.sub = append(self.sub, b)
+			}
+		case telnetStateSubIAC:
+			switch b {
+			case telnetIAC:
+				self.sub = append(self.sub, b)
+				self.state = telnetStateSubData
+			case telnetSE:
+				self.subnegotiation(self.option, self.sub)
+				self.state = telnetStateData
+				if self.compressStart {
+					self.compressStart = false
+					rest = append([]byte{}, in[i+1:]...)
+					return
+				}
+			default:
+				self.state = telnetStateData
+			}
+		}
+	}
+	return
+}
+
+func (self *telnet) send(b ...byte) {
+	self.record(true, b[0], b[1], 0)
+	writeDeadlined(self.conn, append([]byte{telnetIAC}, b...))
+}
+
+func (self *telnet) sendSub(option byte, data []byte) error {
+	self.record(true, telnetSB, option, len(data))
+	buf := []byte{telnetIAC, telnetSB, option}
+	for _, b := range data {
+		buf = append(buf, b)
+		if b == telnetIAC {
+			buf = append(buf, telnetIAC)
+		}
+	}
+	return writeDeadlined(self.conn, append(buf, telnetIAC, telnetSE))
+}
+
+func (self *telnet) enabled(option byte) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.local[option] || self.remote[option]
+}
+
+func (self *telnet) resize(width, height int) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if width == self.width && height == self.height {
+		return
+	}
+	self.width, self.height = width, height
+	if self.local[optNAWS] {
+		self.sendNAWS()
+	}
+}
+
+func (self *telnet) sendNAWS() {
+	self.sendSub(optNAWS, []byte{
+		byte(self.width >> 8), byte(self.width),
+		byte(self.height >> 8), byte(self.height),
+	})
+}
+
+func (self *telnet) negotiate(verb, option byte) {
+	self.record(false, verb, option, 0)
+	if option == optTimingMark && (verb == telnetWILL || verb == telnetWONT) && self.session.pong(pingTimingMark) {
+		return
+	}
+	switch verb {
+	case telnetWILL:
+		if self.remote[option] {
+			return
+		}
+		if self.pendingRemote[option] {
+			delete(self.pendingRemote, option)
+			self.remote[option] = true
+			self.remoteChanged(option, true)
+			return
+		}
+		if self.acceptRemote(option) {
+			self.remote[option] = true
+			self.send(telnetDO, option)
+			self.remoteChanged(option, true)
+		} else {
+			self.send(telnetDONT, option)
+		}
+	case telnetWONT:
+		delete(self.pendingRemote, option)
+		if !self.remote[option] {
+			return
+		}
+		self.remote[option] = false
+		self.send(telnetDONT, option)
+		self.remoteChanged(option, false)
+	case telnetDO:
+		if self.local[option] {
+			return
+		}
+		if self.pendingLocal[option] {
+			delete(self.pendingLocal, option)
+			self.local[option] = true
+			self.localChanged(option, true)
+			return
+		}
+		if self.acceptLocal(option) {
+			self.local[option] = true
+			self.send(telnetWILL, option)
+			self.localChanged(option, true)
+		} else {
+			self.send(telnetWONT, option)
+		}
+	case telnetDONT:
+		delete(self.pendingLocal, option)
+		if !self.local[option] {
+			return
+		}
+		self.local[option] = false
+		self.send(telnetWONT, option)
+		self.localChanged(option, false)
+	}
+}
+
+func (self *telnet) acceptRemote(option byte) bool {
+	switch option {
+	case optEcho, optEOR, optCompress2, optGMCP, optMSDP, optCharset:
+		return true
+	case optMXP:
+		return self.client.mxpAllowed()
+	}
+	return false
+}
+
+func (self *telnet) acceptLocal(option byte) bool {
+	switch option {
+	case optNAWS, optTType, optCharset:
+		return true
+	}
+	return false
+}
+
+func (self *telnet) localChanged(option byte, enabled bool) {
+	switch option {
+	case optNAWS:
+		if enabled {
+			self.sendNAWS()
+		}
+	case optTType:
+		self.ttypes = 0
+	}
+}
+
+func (self *telnet) remoteChanged(option byte, enabled bool) {
+	switch option {
+	case optEcho:
+		self.session.setPasswordMode(enabled)
+	}
+}
+
+func (self *telnet) subnegotiation(option byte, data []byte) {
+	self.record(false, telnetSB, option, len(data))
+	switch option {
+	case optTType:
+		if self.local[optTType] && len(data) > 0 && data[0] == telnetSEND {
+			self.sendTType()
+		}
+	case optCompress2:
+		if self.remote[optCompress2] {
+			self.compressStart = true
+		}
+	case optGMCP:
+		if self.remote[optGMCP] {
+			self.session.receiveGMCP(append([]byte{}, data...))
+		}
+	case optMSDP:
+		if self.remote[optMSDP] {
+			self.session.receiveMSDP(data)
+		}
+	case optCharset:
+		if (self.local[optCharset] || self.remote[optCharset]) && len(data) > 0 && data[0] == charsetRequest {
+			self.charsetRequest(data[1:])
+		}
+	}
+}
+
+// refuse turns off an option the server has enabled.
+func (self *telnet) refuse(option byte) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.remote[option] {
+		self.remote[option] = false
+		self.send(telnetDONT, option)
+	}
+}
+
+func (self *telnet) usesPrompts() bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.prompts
+}
+
+func (self *telnet) setCompressing(b bool) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.compressing = b
+}
+
+func (self *telnet) isCompressing() bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.compressing
+}
+
+// sendTType answers successive SENDs with name, terminal type and MTTS bitfield, repeating the last once before cycling.
+func (self *telnet) sendTType() {
+	name, term, mtts := self.client.terminalType()
+	var reply string
+	switch self.ttypes {
+	case 0:
+		reply = name
+	case 1:
+		reply = term
+	default:
+		reply = fmt.Sprintf("MTTS %v", mtts)
+	}
+	self.ttypes++
+	if self.ttypes > 3 {
+		self.ttypes = 0
+	}
+	self.sendSub(optTType, append([]byte{telnetIS}, reply...))
+}
+
+func (self *telnet) close() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.remote[optEcho] {
+		self.remote[optEcho] = false
+		self.session.setPasswordMode(false)
+	}
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"bytes"
+	"testing"
+)
+
+// telnetExchange is one step of a negotiation transcript: what the server sent, and what the client must answer.
+type telnetExchange struct {
+	server []byte
+	client []byte
+}
+
+func ttypeSend() []byte {
+	return []byte{telnetIAC, telnetSB, optTType, telnetSEND, telnetIAC, telnetSE}
+}
+
+func ttypeIs(text string) []byte {
+	return append(append([]byte{telnetIAC, telnetSB, optTType, telnetIS}, text...), telnetIAC, telnetSE)
+}
+
+// replay feeds the server side of transcript to a telnet of its own, at once or a byte at a time, and checks the answers.
+func replay(t *testing.T, c *Client, transcript []telnetExchange, bytewise bool) {
+	t.Helper()
+	out := &bytes.Buffer{}
+	tn := newTelnet(c.activeSession(), out)
+	for i, step := range transcript {
+		if bytewise {
+			for _, b := range step.server {
+				tn.decode([]byte{b})
+			}
+		} else {
+			tn.decode(step.server)
+		}
+		if got := out.Bytes(); !bytes.Equal(got, step.client) {
+			t.Errorf("Step %v: answered %q to %q, want %q", i, got, step.server, step.client)
+		}
+		out.Reset()
+	}
+}
+
+func TestTTypeTranscript(t *testing.T) {
+	transcript := []telnetExchange{
+		{[]byte{telnetIAC, telnetDO, optTType}, []byte{telnetIAC, telnetWILL, optTType}},
+		{ttypeSend(), ttypeIs("MUG")},
+		{ttypeSend(), ttypeIs("XTERM-256COLOR")},
+		{ttypeSend(), ttypeIs("MTTS 13")},
+		// The last answer is repeated to tell the server the list is done, and then it starts over.
+		{ttypeSend(), ttypeIs("MTTS 13")},
+		{ttypeSend(), ttypeIs("MUG")},
+		{ttypeSend(), ttypeIs("XTERM-256COLOR")},
+		// Negotiating the option again starts over too.
+		{[]byte{telnetIAC, telnetDONT, optTType}, []byte{telnetIAC, telnetWONT, optTType}},
+		{ttypeSend(), nil},
+		{[]byte{telnetIAC, telnetDO, optTType}, []byte{telnetIAC, telnetWILL, optTType}},
+		{ttypeSend(), ttypeIs("MUG")},
+	}
+	for _, bytewise := range []bool{false, true} {
+		replay(t, New(), transcript, bytewise)
+	}
+}
+
+func TestTTypeConfigured(t *testing.T) {
+	c := New()
+	c.ttypeName, c.ttypeTerm, c.ttypeMTTS = "Tester", "ANSI", 1
+	replay(t, c, []telnetExchange{
+		{[]byte{telnetIAC, telnetDO, optTType}, []byte{telnetIAC, telnetWILL, optTType}},
+		{append([]byte("Welcome!\r\n"), ttypeSend()...), ttypeIs("Tester")},
+		{ttypeSend(), ttypeIs("ANSI")},
+		{ttypeSend(), ttypeIs("MTTS 1")},
+	}, false)
+}
+
+func TestTTypeUnrequested(t *testing.T) {
+	// A server that asks without having negotiated the option gets no answer.
+	replay(t, New(), []telnetExchange{
+		{ttypeSend(), nil},
+	}, false)
+}
+
+func TestScriptTType(t *testing.T) {
+	c, _ := startHeadless(t)
+	if err := c.Input(`/ttype("Tester", "ANSI", 1)`); err != nil {
+		t.Fatal(err)
+	}
+	if name, term, mtts := c.terminalType(); name != "Tester" || term != "ANSI" || mtts != 1 {
+		t.Errorf("ttype() set %q, %q, %v", name, term, mtts)
+	}
+	replay(t, c, []telnetExchange{
+		{[]byte{telnetIAC, telnetDO, optTType}, []byte{telnetIAC, telnetWILL, optTType}},
+		{ttypeSend(), ttypeIs("Tester")},
+	}, false)
+}
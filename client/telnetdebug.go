@@ -0,0 +1,249 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+const (
+	// maxTelnetEvents is how many negotiations each connection remembers.
+	maxTelnetEvents = 200
+	telnetLogFormat = "15:04:05.000"
+)
+
+var telnetVerbs = map[string]byte{
+	"will": telnetWILL,
+	"wont": telnetWONT,
+	"do":   telnetDO,
+	"dont": telnetDONT,
+}
+
+var telnetVerbNames = map[byte]string{
+	telnetWILL: "WILL",
+	telnetWONT: "WONT",
+	telnetDO:   "DO",
+	telnetDONT: "DONT",
+	telnetSB:   "SB",
+}
+
+var telnetOptionNames = map[byte]string{
+	optEcho:       "ECHO",
+	optTimingMark: "TIMING-MARK",
+	optTType:      "TTYPE",
+	optEOR:        "EOR",
+	optNAWS:       "NAWS",
+	optCharset:    "CHARSET",
+	optMSDP:       "MSDP",
+	optCompress2:  "MCCP2",
+	optMXP:        "MXP",
+	optGMCP:       "GMCP",
+}
+
+// telnetEvent is a negotiation or subnegotiation of size bytes, sent or received at at.
+type telnetEvent struct {
+	at     time.Time
+	sent   bool
+	verb   byte
+	option byte
+	size   int
+}
+
+func telnetOptionName(option byte) string {
+	if name, found := telnetOptionNames[option]; found {
+		return fmt.Sprintf("%v (%v)", name, option)
+	}
+	return fmt.Sprint(option)
+}
+
+func (self telnetEvent) String() string {
+	direction := "received"
+	if self.sent {
+		direction = "sent"
+	}
+	result := fmt.Sprintf("%v %v %v %v", self.at.Format(telnetLogFormat), direction, telnetVerbNames[self.verb], telnetOptionName(self.option))
+	if self.verb == telnetSB {
+		result += fmt.Sprintf(", %v bytes", self.size)
+	}
+	return result
+}
+
+func (self *telnet) record(sent bool, verb, option byte, size int) {
+	self.eventLock.Lock()
+	defer self.eventLock.Unlock()
+	self.events = append(self.events, telnetEvent{
+		at:     time.Now(),
+		sent:   sent,
+		verb:   verb,
+		option: option,
+		size:   size,
+	})
+	if len(self.events) > maxTelnetEvents {
+		self.events = self.events[len(self.events)-maxTelnetEvents:]
+	}
+}
+
+func (self *telnet) log() []telnetEvent {
+	self.eventLock.Lock()
+	defer self.eventLock.Unlock()
+	return append([]telnetEvent{}, self.events...)
+}
+
+// optionStates returns the state of each option that was negotiated or asked for, on our side and on the server side.
+func (self *telnet) optionStates() (options []byte, us, them []string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	seen := map[byte]bool{}
+	for _, states := range []map[byte]bool{self.local, self.remote, self.pendingLocal, self.pendingRemote} {
+		for option := range states {
+			if !seen[option] {
+				seen[option] = true
+				options = append(options, option)
+			}
+		}
+	}
+	sort.Slice(options, func(i, j int) bool {
+		return options[i] < options[j]
+	})
+	state := func(enabled, pending bool) string {
+		switch {
+		case pending:
+			return "pending"
+		case enabled:
+			return "enabled"
+		}
+		return "disabled"
+	}
+	for _, option := range options {
+		us = append(us, state(self.local[option], self.pendingLocal[option]))
+		them = append(them, state(self.remote[option], self.pendingRemote[option]))
+	}
+	return
+}
+
+// request sends verb for option, whether or not the client would agree to it if the server asked. Asking for an option makes it
+// pending until the server answers, and turning one off takes effect at once.
+func (self *telnet) request(verb, option byte) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	switch verb {
+	case telnetWILL:
+		if self.local[option] || self.pendingLocal[option] {
+			return
+		}
+		self.pendingLocal[option] = true
+		self.send(verb, option)
+	case telnetDO:
+		if self.remote[option] || self.pendingRemote[option] {
+			return
+		}
+		self.pendingRemote[option] = true
+		self.send(verb, option)
+	case telnetWONT:
+		delete(self.pendingLocal, option)
+		if self.local[option] {
+			self.local[option] = false
+			self.send(verb, option)
+			self.localChanged(option, false)
+		}
+	case telnetDONT:
+		delete(self.pendingRemote, option)
+		if self.remote[option] {
+			self.remote[option] = false
+			self.send(verb, option)
+			self.remoteChanged(option, false)
+		}
+	}
+}
+
+// describeTelnet shows the option states and the negotiation log of the current connection.
+func (self *Client) describeTelnet() string {
+	tn := self.getTelnet()
+	if tn == nil {
+		return "Not connected"
+	}
+	out := &strings.Builder{}
+	options, us, them := tn.optionStates()
+	fmt.Fprintf(out, "Options:\n")
+	if len(options) == 0 {
+		fmt.Fprintf(out, "  (none)\n")
+	} else {
+		rows := [][]string{{"option", "us", "them"}}
+		for i, option := range options {
+			rows = append(rows, []string{telnetOptionName(option), us[i], them[i]})
+		}
+		fmt.Fprintf(out, "  %v\n", strings.ReplaceAll(formatTable(rows), "\n", "\n  "))
+	}
+	events := tn.log()
+	fmt.Fprintf(out, "Last %v negotiations:\n", len(events))
+	for _, event := range events {
+		fmt.Fprintf(out, "  %v\n", event)
+	}
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+func (self *Client) bindTelnetDebug() {
+	self.bind("telnet()", "Show the telnet options of the current connection and their latest negotiations.", func(call otto.FunctionCall) (result otto.Value) {
+		self.Outputf("%v\n", self.describeTelnet())
+		return
+	})
+	fn, _ := self.ot.Get("telnet")
+	obj := fn.Object()
+	self.bindMethod(obj, "telnet", "options()", "List the {option, name, us, them} states, enabled, disabled or pending, of the telnet options negotiated on the current connection.", func(call otto.FunctionCall) (result otto.Value) {
+		items := []map[string]interface{}{}
+		if tn := self.getTelnet(); tn != nil {
+			options, us, them := tn.optionStates()
+			for i, option := range options {
+				items = append(items, map[string]interface{}{
+					"option": int(option),
+					"name":   telnetOptionNames[option],
+					"us":     us[i],
+					"them":   them[i],
+				})
+			}
+		}
+		return self.jsArray(items)
+	})
+	self.bindMethod(obj, "telnet", "log()", fmt.Sprintf("List the last %v {at, direction, verb, option, name, bytes} negotiations and subnegotiations of the current connection.", maxTelnetEvents), func(call otto.FunctionCall) (result otto.Value) {
+		items := []map[string]interface{}{}
+		if tn := self.getTelnet(); tn != nil {
+			for _, event := range tn.log() {
+				direction := "received"
+				if event.sent {
+					direction = "sent"
+				}
+				items = append(items, map[string]interface{}{
+					"at":        event.at.Format(telnetLogFormat),
+					"direction": direction,
+					"verb":      telnetVerbNames[event.verb],
+					"option":    int(event.option),
+					"name":      telnetOptionNames[event.option],
+					"bytes":     event.size,
+				})
+			}
+		}
+		return self.jsArray(items)
+	})
+	self.bindMethod(obj, "telnet", "request(verb, option)", "Send will, wont, do or dont for a telnet option number, to experiment with options the client doesn't ask for itself.", func(call otto.FunctionCall) (result otto.Value) {
+		verb, found := telnetVerbs[strings.ToLower(call.Argument(0).String())]
+		if !found {
+			result, _ = otto.ToValue(fmt.Errorf("Invalid telnet verb %#v, use will, wont, do or dont", call.Argument(0).String()))
+			return
+		}
+		option, err := call.Argument(1).ToInteger()
+		if err != nil || !call.Argument(1).IsNumber() || option < 0 || option > 255 {
+			result, _ = otto.ToValue(fmt.Errorf("Invalid telnet option %#v, it has to be a number from 0 to 255", call.Argument(1).String()))
+			return
+		}
+		tn := self.getTelnet()
+		if tn == nil {
+			result, _ = otto.ToValue(fmt.Errorf("Not connected"))
+			return
+		}
+		tn.request(verb, byte(option))
+		return
+	})
+}
@@ -0,0 +1,234 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+	"github.com/zond/gocui"
+)
+
+const (
+	themeError      = "error"
+	themeNotice     = "notice"
+	themeEcho       = "echo"
+	themeScriptEcho = "scriptEcho"
+	themeTimestamp  = "timestamp"
+	themeHighlight  = "highlight"
+	themeStatus     = "status"
+	// themeStoreKey is where theme overrides are kept in the store.
+	themeStoreKey = "theme"
+)
+
+// defaultTheme has the colors of the theme categories, as color specs like "yellow,bold". The first color of the status spec is the
+// text and the second the background.
+var defaultTheme = map[string]string{
+	themeError:      "red",
+	themeNotice:     "cyan",
+	themeEcho:       "yellow,dim",
+	themeScriptEcho: "white,dim",
+	themeTimestamp:  "dim",
+	themeHighlight:  "yellow,bold",
+	themeStatus:     "white,blue",
+}
+
+var gocuiColors = map[string]gocui.Attribute{
+	"black":   gocui.ColorBlack,
+	"red":     gocui.ColorRed,
+	"green":   gocui.ColorGreen,
+	"yellow":  gocui.ColorYellow,
+	"blue":    gocui.ColorBlue,
+	"magenta": gocui.ColorMagenta,
+	"cyan":    gocui.ColorCyan,
+	"white":   gocui.ColorWhite,
+}
+
+var gocuiAttributes = map[string]gocui.Attribute{
+	"bold":      gocui.AttrBold,
+	"underline": gocui.AttrUnderline,
+	"reverse":   gocui.AttrReverse,
+}
+
+// monochrome tells whether the terminal has said it can't show colors.
+func monochrome() bool {
+	if _, found := os.LookupEnv("NO_COLOR"); found {
+		return true
+	}
+	term := os.Getenv("TERM")
+	return term == "" || term == "dumb"
+}
+
+// parseStatusColors turns a status spec into the gocui text and background colors.
+func parseStatusColors(spec string) (fg, bg gocui.Attribute, err error) {
+	colors := 0
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+		if attr, found := gocuiAttributes[part]; found {
+			fg |= attr
+		} else if color, found := gocuiColors[part]; found && colors == 0 {
+			fg |= color
+			colors++
+		} else if found && colors == 1 {
+			bg = color
+			colors++
+		} else if found {
+			err = fmt.Errorf("Too many colors in %#v, give the text and the background color", spec)
+			return
+		} else {
+			err = fmt.Errorf("Unknown status color %#v", part)
+			return
+		}
+	}
+	return
+}
+
+// themeSpec returns the color spec of category, overridden or default.
+func (self *Client) themeSpec(category string) string {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	if spec, found := self.theme[category]; found {
+		return spec
+	}
+	return defaultTheme[category]
+}
+
+// themeColor returns the SGR sequence for category, or nothing in monochrome terminals.
+func (self *Client) themeColor(category string) string {
+	if self.monochrome {
+		return ""
+	}
+	sgr, _ := parseColor(self.themeSpec(category))
+	return sgr
+}
+
+// statusColors returns the gocui colors of the status bar, and the terminal defaults in monochrome terminals.
+func (self *Client) statusColors() (fg, bg gocui.Attribute) {
+	if self.monochrome {
+		return gocui.ColorDefault, gocui.ColorDefault
+	}
+	fg, bg, _ = parseStatusColors(self.themeSpec(themeStatus))
+	return
+}
+
+// themed wraps each line of text in the color of category.
+func (self *Client) themed(category, text string) string {
+	color := self.themeColor(category)
+	if color == "" {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = color + line + ansiReset
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// OutputErrorf shows a message about something that went wrong in the error color of the theme.
+func (self *Client) OutputErrorf(format string, params ...interface{}) {
+	self.target().outputErrorf(format, params...)
+}
+
+// OutputNoticef shows a client message, like a connection change, in the notice color of the theme.
+func (self *Client) OutputNoticef(format string, params ...interface{}) {
+	self.target().outputNoticef(format, params...)
+}
+
+func (self *session) outputErrorf(format string, params ...interface{}) {
+	self.outputf("%s", self.client.themed(themeError, fmt.Sprintf(format, params...)))
+}
+
+func (self *session) outputNoticef(format string, params ...interface{}) {
+	self.outputf("%s", self.client.themed(themeNotice, fmt.Sprintf(format, params...)))
+}
+
+// setTheme validates and applies spec for category, resetting it to the default if spec is empty.
+func (self *Client) setTheme(category, spec string) error {
+	if _, found := defaultTheme[category]; !found {
+		categories := []string{}
+		for name := range defaultTheme {
+			categories = append(categories, name)
+		}
+		sort.Strings(categories)
+		return fmt.Errorf("Unknown theme category %#v, use one of %v", category, strings.Join(categories, ", "))
+	}
+	if category == themeStatus {
+		if _, _, err := parseStatusColors(spec); err != nil {
+			return err
+		}
+	} else if _, err := parseColor(spec); err != nil {
+		return err
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if strings.TrimSpace(spec) == "" {
+		delete(self.theme, category)
+	} else {
+		self.theme[category] = spec
+	}
+	return nil
+}
+
+// saveTheme keeps the theme overrides in the store.
+func (self *Client) saveTheme() error {
+	self.lock.RLock()
+	data, err := json.Marshal(self.theme)
+	self.lock.RUnlock()
+	if err != nil {
+		return err
+	}
+	self.store.values[themeStoreKey] = json.RawMessage(data)
+	if err := self.store.save(); err != nil {
+		return fmt.Errorf("Unable to save %v: %v", self.store.path, err)
+	}
+	return nil
+}
+
+// loadTheme applies the theme overrides in the store, skipping ones no longer valid.
+func (self *Client) loadTheme() {
+	encoded, found := self.store.values[themeStoreKey]
+	if !found {
+		return
+	}
+	overrides := map[string]string{}
+	if err := json.Unmarshal(encoded, &overrides); err != nil {
+		self.OutputErrorf("Ignoring corrupt theme in %v: %v\n", self.store.path, err)
+		return
+	}
+	for category, spec := range overrides {
+		if err := self.setTheme(category, spec); err != nil {
+			self.OutputErrorf("Ignoring stored theme color for %v: %v\n", category, err)
+		}
+	}
+}
+
+func (self *Client) bindTheme() {
+	self.loadTheme()
+	obj, _ := self.ot.Object("({})")
+	self.bindMethod(obj, "theme", "set(category, color)", "Set the color of a category of client text, like \"red,bold\", and remember it across restarts. Categories are error, notice, echo, scriptEcho, timestamp, highlight (for highlights without a color) and status (text and background color). An empty color restores the default.", func(call otto.FunctionCall) (result otto.Value) {
+		if err := self.setTheme(call.Argument(0).String(), call.Argument(1).String()); err != nil {
+			result, _ = otto.ToValue(err)
+			return
+		}
+		if err := self.saveTheme(); err != nil {
+			result, _ = otto.ToValue(err)
+		}
+		return
+	})
+	self.bindMethod(obj, "theme", "get()", "Return the colors of all theme categories, and whether the terminal is treated as monochrome.", func(call otto.FunctionCall) (result otto.Value) {
+		colors, _ := self.ot.Object("({})")
+		for category := range defaultTheme {
+			colors.Set(category, self.themeSpec(category))
+		}
+		colors.Set("monochrome", self.monochrome)
+		return colors.Value()
+	})
+	self.ot.Set("theme", obj)
+}
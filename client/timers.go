@@ -0,0 +1,111 @@
+package client
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+const (
+	minInterval = 10 * time.Millisecond
+)
+
+type scriptTimer struct {
+	id       int
+	fn       otto.Value
+	args     []interface{}
+	interval time.Duration
+	timer    *time.Timer
+	owner    string
+	session  *session
+}
+
+// addTimer arms a timer whose callback is run by the job queue, never directly from the timer goroutine, in the session it was created in.
+func (self *Client) addTimer(call otto.FunctionCall, repeat bool) (result otto.Value) {
+	if !call.Argument(0).IsFunction() {
+		result, _ = otto.ToValue(fmt.Errorf("Timer callback is not a function"))
+		return
+	}
+	ms, _ := call.Argument(1).ToInteger()
+	delay := time.Duration(ms) * time.Millisecond
+	if delay < 0 {
+		delay = 0
+	}
+	if repeat && delay < minInterval {
+		delay = minInterval
+	}
+	self.nextTimerId++
+	t := &scriptTimer{
+		id:      self.nextTimerId,
+		fn:      call.Argument(0),
+		owner:   self.currentOwner(),
+		session: self.context,
+	}
+	if repeat {
+		t.interval = delay
+	}
+	for i := 2; i < len(call.ArgumentList); i++ {
+		t.args = append(t.args, call.ArgumentList[i])
+	}
+	t.timer = time.AfterFunc(delay, func() {
+		self.schedule(func() {
+			if t.session != nil {
+				self.within(t.session, func() {
+					self.fireTimer(t)
+				})
+			} else {
+				self.fireTimer(t)
+			}
+		})
+	})
+	self.timers[t.id] = t
+	result, _ = otto.ToValue(t.id)
+	return
+}
+
+func (self *Client) fireTimer(t *scriptTimer) {
+	if self.timers[t.id] != t {
+		return
+	}
+	if t.interval == 0 {
+		delete(self.timers, t.id)
+	}
+	self.withOrigin(originTimer, func() {
+		if _, err := self.callScript(t.fn, t.args...); err != nil {
+			self.reportError(fmt.Sprintf("timer %v%v", t.id, registeredIn(t.owner)), err)
+		}
+	})
+	if t.interval > 0 && self.timers[t.id] == t {
+		t.timer.Reset(t.interval)
+	}
+}
+
+func (self *Client) clearTimer(call otto.FunctionCall) (result otto.Value) {
+	id, _ := call.Argument(0).ToInteger()
+	if t, found := self.timers[int(id)]; found {
+		t.timer.Stop()
+		delete(self.timers, t.id)
+	}
+	return
+}
+
+func (self *Client) stopTimers() {
+	self.scriptLock.Lock()
+	defer self.scriptLock.Unlock()
+	for id, t := range self.timers {
+		t.timer.Stop()
+		delete(self.timers, id)
+	}
+}
+
+func (self *Client) bindTimers() {
+	self.bind("setTimeout(fn, ms, args...)", "Call fn(args...) after ms milliseconds and return the timer id.", func(call otto.FunctionCall) otto.Value {
+		return self.addTimer(call, false)
+	})
+	self.bind("setInterval(fn, ms, args...)", "Call fn(args...) every ms milliseconds and return the timer id.", func(call otto.FunctionCall) otto.Value {
+		return self.addTimer(call, true)
+	})
+	self.bind("clearTimeout(id)", "Cancel a timeout.", self.clearTimer)
+	self.bind("clearInterval(id)", "Cancel an interval.", self.clearTimer)
+}
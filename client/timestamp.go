@@ -0,0 +1,48 @@
+package client
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+const (
+	defaultTimestampFormat = "15:04:05"
+)
+
+// timestamp returns the timestamp prefix for a line received at, or nothing if timestamps are off.
+func (self *Client) timestamp(at time.Time) string {
+	self.lock.RLock()
+	format := self.config.timestampFormat
+	self.lock.RUnlock()
+	if format == "" {
+		return ""
+	}
+	return self.themed(themeTimestamp, at.Format(format)) + " "
+}
+
+func (self *Client) bindTimestamps() {
+	self.bind("timestamps(enabled)", "Get or set whether received lines are prefixed with the time they arrived. A string enables them with that Go time layout, e.g. \"15:04:05.000\".", func(call otto.FunctionCall) (result otto.Value) {
+		if arg := call.Argument(0); arg.IsString() {
+			self.Configure(WithTimestamps(arg.String()))
+		} else if arg.IsDefined() {
+			enabled, err := arg.ToBoolean()
+			if err != nil {
+				result, _ = otto.ToValue(err)
+				return
+			}
+			format := ""
+			if enabled {
+				format = defaultTimestampFormat
+			}
+			self.Configure(WithTimestamps(format))
+		}
+		if format := self.getConfig().timestampFormat; format == "" {
+			result, _ = otto.ToValue("Timestamps disabled")
+		} else {
+			result, _ = otto.ToValue(fmt.Sprintf("Timestamps enabled as %#v", format))
+		}
+		return
+	})
+}
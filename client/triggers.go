@@ -0,0 +1,279 @@
+package client
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/robertkrimen/otto"
+)
+
+type trigger struct {
+	id       int
+	pattern  *regexp.Regexp
+	callback otto.Value
+	owner    string
+	group    string
+	disabled bool
+	hits     int
+}
+
+type substitution struct {
+	id          int
+	pattern     *regexp.Regexp
+	replacement string
+	owner       string
+}
+
+type gag struct {
+	id      int
+	pattern *regexp.Regexp
+	hard    bool
+	owner   string
+}
+
+func (self *Client) gagged(line string, hard bool) bool {
+	for _, g := range self.gags {
+		if g.hard == hard && g.pattern.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// runTriggers runs all matching triggers, and returns the text of the last one returning a string to display instead of line, or whether one returned false to gag it.
+func (self *Client) runTriggers(line string) (text string, changed, gagged bool) {
+	text = line
+	for _, t := range append([]*trigger{}, self.triggers...) {
+		if t.disabled {
+			continue
+		}
+		match := t.pattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		t.hits++
+		result, err := self.callScript(t.callback, line, self.groups(t.pattern, match))
+		if err != nil {
+			self.reportError(fmt.Sprintf("trigger %v (%v)%v", t.id, t.pattern, registeredIn(t.owner)), err)
+			continue
+		}
+		if result.IsString() {
+			text, changed = result.String(), true
+		} else if result.IsBoolean() {
+			if b, _ := result.ToBoolean(); !b {
+				gagged = true
+			}
+		}
+	}
+	return
+}
+
+func (self *Client) substitute(line string) (result string, changed bool) {
+	result = line
+	for _, sub := range self.subs {
+		if sub.pattern.MatchString(result) {
+			result, changed = sub.pattern.ReplaceAllString(result, sub.replacement), true
+		}
+	}
+	return
+}
+
+// groups converts a regexp match into a JS array of all groups, with named groups also set as properties.
+func (self *Client) groups(pattern *regexp.Regexp, match []string) otto.Value {
+	obj, _ := self.ot.Object("[]")
+	for i, group := range match {
+		obj.Set(strconv.Itoa(i), group)
+	}
+	for i, name := range pattern.SubexpNames() {
+		if name != "" {
+			obj.Set(name, match[i])
+		}
+	}
+	return obj.Value()
+}
+
+func (self *Client) jsArray(items []map[string]interface{}) otto.Value {
+	arr, _ := self.ot.Object("[]")
+	for i, item := range items {
+		obj, _ := self.ot.Object("({})")
+		for key, value := range item {
+			obj.Set(key, value)
+		}
+		arr.Set(strconv.Itoa(i), obj)
+	}
+	return arr.Value()
+}
+
+func (self *Client) callScript(fn otto.Value, args ...interface{}) (result otto.Value, err error) {
+	err = self.guard(func() (err error) {
+		result, err = fn.Call(otto.NullValue(), args...)
+		return
+	})
+	return
+}
+
+func (self *Client) bindTriggers() {
+	self.bind("addTrigger(pattern, fn, options)", "Call fn(line, groups) for received lines matching pattern and return the trigger id. Returning a string from fn displays it instead of the line, returning false gags the line. Options: {group, enabled}.", func(call otto.FunctionCall) (result otto.Value) {
+		pattern, err := regexp.Compile(call.Argument(0).String())
+		if err != nil {
+			result, _ = otto.ToValue(fmt.Errorf("Invalid trigger pattern %#v: %v", call.Argument(0).String(), err))
+			return
+		}
+		if !call.Argument(1).IsFunction() {
+			result, _ = otto.ToValue(fmt.Errorf("Trigger callback is not a function"))
+			return
+		}
+		t := &trigger{
+			pattern:  pattern,
+			callback: call.Argument(1),
+			owner:    self.currentOwner(),
+		}
+		if opts := call.Argument(2); opts.IsObject() {
+			if v, _ := opts.Object().Get("group"); v.IsDefined() {
+				t.group = v.String()
+			}
+			if v, _ := opts.Object().Get("enabled"); v.IsDefined() {
+				enabled, _ := v.ToBoolean()
+				t.disabled = !enabled
+			}
+		}
+		self.nextTriggerId++
+		t.id = self.nextTriggerId
+		self.triggers = append(self.triggers, t)
+		result, _ = otto.ToValue(t.id)
+		return
+	})
+	self.bind("addSub(pattern, replacement)", "Rewrite matches of pattern in received lines, with $1 style group references and {color} markup, and return the substitution id.", func(call otto.FunctionCall) (result otto.Value) {
+		pattern, err := regexp.Compile(call.Argument(0).String())
+		if err != nil {
+			result, _ = otto.ToValue(fmt.Errorf("Invalid substitution pattern %#v: %v", call.Argument(0).String(), err))
+			return
+		}
+		self.nextTriggerId++
+		self.subs = append(self.subs, &substitution{
+			id:          self.nextTriggerId,
+			pattern:     pattern,
+			replacement: call.Argument(1).String(),
+			owner:       self.currentOwner(),
+		})
+		result, _ = otto.ToValue(self.nextTriggerId)
+		return
+	})
+	self.bind("removeSub(id)", "Remove a substitution.", func(call otto.FunctionCall) (result otto.Value) {
+		id, _ := call.Argument(0).ToInteger()
+		for i, sub := range self.subs {
+			if sub.id == int(id) {
+				self.subs = append(self.subs[:i], self.subs[i+1:]...)
+				result, _ = otto.ToValue(true)
+				return
+			}
+		}
+		result, _ = otto.ToValue(false)
+		return
+	})
+	self.bind("gag(pattern, options)", "Hide received lines matching pattern and return the gag id. With {hard:true} triggers will not see them either.", func(call otto.FunctionCall) (result otto.Value) {
+		pattern, err := regexp.Compile(call.Argument(0).String())
+		if err != nil {
+			result, _ = otto.ToValue(fmt.Errorf("Invalid gag pattern %#v: %v", call.Argument(0).String(), err))
+			return
+		}
+		hard := false
+		if opts := call.Argument(1); opts.IsObject() {
+			v, _ := opts.Object().Get("hard")
+			hard, _ = v.ToBoolean()
+		}
+		self.nextTriggerId++
+		self.gags = append(self.gags, &gag{
+			id:      self.nextTriggerId,
+			pattern: pattern,
+			hard:    hard,
+			owner:   self.currentOwner(),
+		})
+		result, _ = otto.ToValue(self.nextTriggerId)
+		return
+	})
+	self.bind("ungag(id)", "Remove a gag.", func(call otto.FunctionCall) (result otto.Value) {
+		id, _ := call.Argument(0).ToInteger()
+		for i, g := range self.gags {
+			if g.id == int(id) {
+				self.gags = append(self.gags[:i], self.gags[i+1:]...)
+				result, _ = otto.ToValue(true)
+				return
+			}
+		}
+		result, _ = otto.ToValue(false)
+		return
+	})
+	self.bind("gags()", "List gags.", func(call otto.FunctionCall) (result otto.Value) {
+		items := []map[string]interface{}{}
+		for _, g := range self.gags {
+			items = append(items, map[string]interface{}{
+				"id":      g.id,
+				"pattern": g.pattern.String(),
+				"hard":    g.hard,
+			})
+		}
+		return self.jsArray(items)
+	})
+	self.bind("removeTrigger(id)", "Remove a trigger.", func(call otto.FunctionCall) (result otto.Value) {
+		id, _ := call.Argument(0).ToInteger()
+		for i, t := range self.triggers {
+			if t.id == int(id) {
+				self.triggers = append(self.triggers[:i], self.triggers[i+1:]...)
+				result, _ = otto.ToValue(true)
+				return
+			}
+		}
+		for i, t := range self.multiTriggers {
+			if t.id == int(id) {
+				self.multiTriggers = append(self.multiTriggers[:i], self.multiTriggers[i+1:]...)
+				result, _ = otto.ToValue(true)
+				return
+			}
+		}
+		result, _ = otto.ToValue(false)
+		return
+	})
+	self.bind("triggers()", "List triggers in the order they run, with their groups and hit counts.", func(call otto.FunctionCall) (result otto.Value) {
+		items := []map[string]interface{}{}
+		for _, t := range self.triggers {
+			items = append(items, map[string]interface{}{
+				"id":      t.id,
+				"pattern": t.pattern.String(),
+				"group":   t.group,
+				"enabled": !t.disabled,
+				"hits":    t.hits,
+			})
+		}
+		return self.jsArray(items)
+	})
+	self.bind("enableTrigger(id)", "Make a disabled trigger run again.", func(call otto.FunctionCall) otto.Value {
+		return self.enableTriggers(call, false, true)
+	})
+	self.bind("disableTrigger(id)", "Skip a trigger until it is enabled again, keeping its hit count.", func(call otto.FunctionCall) otto.Value {
+		return self.enableTriggers(call, false, false)
+	})
+	self.bind("enableGroup(group)", "Enable the triggers in group.", func(call otto.FunctionCall) otto.Value {
+		return self.enableTriggers(call, true, true)
+	})
+	self.bind("disableGroup(group)", "Disable the triggers in group.", func(call otto.FunctionCall) otto.Value {
+		return self.enableTriggers(call, true, false)
+	})
+}
+
+// enableTriggers enables or disables the trigger with the id, or the triggers in the group, given as the first argument, and returns
+// how many there were.
+func (self *Client) enableTriggers(call otto.FunctionCall, group bool, enabled bool) (result otto.Value) {
+	arg := call.Argument(0)
+	id, _ := arg.ToInteger()
+	count := 0
+	for _, t := range self.triggers {
+		if (group && t.group == arg.String()) || (!group && t.id == int(id)) {
+			t.disabled = !enabled
+			count++
+		}
+	}
+	result, _ = otto.ToValue(count)
+	return
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+// The tests below evaluate JavaScript, and need the real script engine.
+
+func TestScriptTriggerGroups(t *testing.T) {
+	c := New()
+	for _, tc := range []struct {
+		pattern string
+		line    string
+		want    []string
+		named   map[string]string
+	}{
+		{
+			pattern: `^(\w+) tells you '(.*)'$`,
+			line:    "Bob tells you 'hello'",
+			want:    []string{"Bob tells you 'hello'", "Bob", "hello"},
+		},
+		{
+			pattern: `^((\w+) (\w+)) says '(.*)'$`,
+			line:    "Big Bob says 'hi'",
+			want:    []string{"Big Bob says 'hi'", "Big Bob", "Big", "Bob", "hi"},
+		},
+		{
+			pattern: `^(?:You|We) (hit|miss)(?: the)? (\w+)\.$`,
+			line:    "You miss the rat.",
+			want:    []string{"You miss the rat.", "miss", "rat"},
+		},
+		{
+			pattern: `^(\w+)(?: \((\w+)\))?( glowing)?$`,
+			line:    "sword",
+			want:    []string{"sword", "sword", "", ""},
+		},
+		{
+			pattern: `^(?P<who>\w+) tells you '(?P<what>.*)'$`,
+			line:    "Bob tells you 'hello'",
+			want:    []string{"Bob tells you 'hello'", "Bob", "hello"},
+			named:   map[string]string{"who": "Bob", "what": "hello"},
+		},
+	} {
+		pattern := regexp.MustCompile(tc.pattern)
+		groups := c.groups(pattern, pattern.FindStringSubmatch(tc.line)).Object()
+		if length, _ := groups.Get("length"); length.String() != strconv.Itoa(len(tc.want)) {
+			t.Errorf("%v on %q: got %v groups, want %v", tc.pattern, tc.line, length, len(tc.want))
+		}
+		for i, want := range tc.want {
+			if got, _ := groups.Get(strconv.Itoa(i)); !got.IsString() || got.String() != want {
+				t.Errorf("%v on %q: group %v is %v, want %q", tc.pattern, tc.line, i, got, want)
+			}
+		}
+		for name, want := range tc.named {
+			if got, _ := groups.Get(name); got.String() != want {
+				t.Errorf("%v on %q: group %v is %v, want %q", tc.pattern, tc.line, name, got, want)
+			}
+		}
+	}
+}
+
+func TestScriptTriggerCallbackGroups(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := attachServer(t, c)
+	if err := c.Input(`/addTrigger("^(\\w+) tells you '(.*)'$", function(line, m) { sendln("tell " + m[1] + " thanks for " + m[2]); })`); err != nil {
+		t.Fatal(err)
+	}
+	server.send("Bob tells you 'the sword'")
+	server.expect("tell Bob thanks for the sword")
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"github.com/robertkrimen/otto"
+	"github.com/zond/gocui"
+)
+
+// unseen returns how many lines arrived while the session was in the background or scrolled up. It must be called with the client
+// lock held.
+func (self *session) unseen() int {
+	return self.unread + self.newLines
+}
+
+// markRead forgets the unseen lines of the session without scrolling it. It must be called with the client lock held.
+func (self *session) markRead() {
+	self.unread, self.newLines, self.urgent = 0, 0, false
+}
+
+// unreadSummary returns the unseen lines of all sessions, and whether a bell rang in any of them.
+func (self *Client) unreadSummary() (lines int, urgent bool) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	for _, sess := range self.sessions {
+		lines += sess.unseen()
+		urgent = urgent || sess.urgent
+	}
+	return
+}
+
+// layoutUnread colors the view frames while there are unseen lines, red if a bell rang among them.
+func (self *Client) layoutUnread(g *gocui.Gui) {
+	switch lines, urgent := self.unreadSummary(); {
+	case urgent:
+		g.FgColor = gocui.ColorRed
+	case lines > 0:
+		g.FgColor = gocui.ColorYellow
+	default:
+		g.FgColor = gocui.ColorDefault
+	}
+}
+
+func (self *Client) bindUnread() {
+	self.bind("unread()", "Return the {lines, urgent} that arrived in the current session while it was in the background or scrolled up, where urgent tells if a bell rang, along with the total lines of all sessions.", func(call otto.FunctionCall) (result otto.Value) {
+		total, _ := self.unreadSummary()
+		sess := self.target()
+		self.lock.RLock()
+		lines, urgent := sess.unseen(), sess.urgent
+		self.lock.RUnlock()
+		obj, _ := self.ot.Object("({})")
+		obj.Set("lines", lines)
+		obj.Set("urgent", urgent)
+		obj.Set("total", total)
+		return obj.Value()
+	})
+	self.bind("markRead()", "Forget the unread lines of the current session without scrolling it.", func(call otto.FunctionCall) (result otto.Value) {
+		sess := self.target()
+		self.lock.Lock()
+		sess.markRead()
+		self.lock.Unlock()
+		self.redraw()
+		return
+	})
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+const maxURLs = 50
+
+// urlPattern matches http and https URLs, leaving out trailing punctuation that more likely ends the sentence than the URL.
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"']*[^\s<>"'.,;:!?)\]]`)
+
+// urlHighlight underlines URLs in received lines, before the highlight rules of scripts so they can override it.
+var urlHighlight = &highlightRule{
+	pattern: urlPattern,
+	color:   "underline",
+	sgr:     "\033[4m",
+}
+
+// seenURL is a URL seen in a received line, or with command set an MXP link sending url as a command.
+type seenURL struct {
+	url     string
+	line    string
+	at      time.Time
+	command bool
+}
+
+// rememberURLs adds the URLs in line to the most recently seen ones, moving URLs seen before to the front. It is only used in script
+// context.
+func (self *Client) rememberURLs(line string, at time.Time) {
+	for _, url := range urlPattern.FindAllString(line, -1) {
+		self.rememberURL(&seenURL{
+			url:  url,
+			line: line,
+			at:   at,
+		})
+	}
+}
+
+// rememberLink adds an MXP link to the most recently seen URLs.
+func (self *Client) rememberLink(link mxpLink) {
+	self.rememberURL(&seenURL{
+		url:     link.target,
+		line:    link.text,
+		at:      time.Now(),
+		command: link.command,
+	})
+}
+
+func (self *Client) rememberURL(url *seenURL) {
+	for i, seen := range self.urls {
+		if seen.url == url.url && seen.command == url.command {
+			self.urls = append(self.urls[:i], self.urls[i+1:]...)
+			break
+		}
+	}
+	self.urls = append(self.urls, url)
+	if over := len(self.urls) - maxURLs; over > 0 {
+		self.urls = append([]*seenURL{}, self.urls[over:]...)
+	}
+}
+
+// copyToClipboard asks the terminal to put text in the system clipboard with OSC 52, which terminals not supporting it ignore.
+func (self *Client) copyToClipboard(text string) {
+	fmt.Fprintf(self.gui, "\033]52;c;%v\a", base64.StdEncoding.EncodeToString([]byte(text)))
+}
+
+func (self *Client) bindURLs() {
+	self.bind("urls()", "List the URLs and MXP links seen most recently in received lines, newest first.", func(call otto.FunctionCall) (result otto.Value) {
+		items := []map[string]interface{}{}
+		for i := len(self.urls) - 1; i >= 0; i-- {
+			seen := self.urls[i]
+			items = append(items, map[string]interface{}{
+				"n":    len(self.urls) - i,
+				"url":  seen.url,
+				"at":   seen.at.Format("15:04:05"),
+				"line": seen.line,
+				"send": seen.command,
+			})
+		}
+		return self.jsArray(items)
+	})
+	self.bind("openurl(n)", "Copy URL number n of urls() to the clipboard, and show it on a line of its own for terminals that can't. MXP send links send their command instead.", func(call otto.FunctionCall) (result otto.Value) {
+		n, err := call.Argument(0).ToInteger()
+		if err != nil || n < 1 || int(n) > len(self.urls) {
+			result, _ = otto.ToValue(fmt.Errorf("No URL number %#v, see urls()", call.Argument(0).String()))
+			return
+		}
+		seen := self.urls[len(self.urls)-int(n)]
+		if seen.command {
+			if err := self.scriptCommand(seen.url); err != nil {
+				result, _ = otto.ToValue(err)
+			}
+			return
+		}
+		self.copyToClipboard(seen.url)
+		self.Outputf("%v\n", seen.url)
+		return
+	})
+}
@@ -0,0 +1,22 @@
+package client
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// decodeUTF8 returns pending followed by data with invalid sequences replaced by U+FFFD, holding back an incomplete sequence at
+// the end as rest, to be completed by the next read.
+func decodeUTF8(pending, data []byte) (valid, rest []byte) {
+	buf := append(pending, data...)
+	cut := len(buf)
+	for i := len(buf) - 1; i >= 0 && i >= len(buf)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(buf[i]) {
+			if !utf8.FullRune(buf[i:]) {
+				cut = i
+			}
+			break
+		}
+	}
+	return bytes.ToValidUTF8(buf[:cut], []byte(string(utf8.RuneError))), append([]byte{}, buf[cut:]...)
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"unicode/utf8"
+)
+
+const utf8Sample = "Åsa säger: ╔══╗ 日本語 👍!"
+
+// decodeReads decodes the reads one after the other like the read loop does, flushing what is left pending at the end.
+func decodeReads(reads ...[]byte) string {
+	out, pending := []byte{}, []byte{}
+	for _, read := range reads {
+		var valid []byte
+		valid, pending = decodeUTF8(pending, read)
+		out = append(out, valid...)
+	}
+	return string(append(out, bytes.ToValidUTF8(pending, []byte(string(utf8.RuneError)))...))
+}
+
+func TestDecodeUTF8Splits(t *testing.T) {
+	data := []byte(utf8Sample)
+	for i := 0; i <= len(data); i++ {
+		if got := decodeReads(data[:i], data[i:]); got != utf8Sample {
+			t.Errorf("Split at %v: got %q", i, got)
+		}
+		for j := i; j <= len(data); j++ {
+			if got := decodeReads(data[:i], data[i:j], data[j:]); got != utf8Sample {
+				t.Errorf("Split at %v and %v: got %q", i, j, got)
+			}
+		}
+	}
+	reads := [][]byte{}
+	for _, b := range data {
+		reads = append(reads, []byte{b})
+	}
+	if got := decodeReads(reads...); got != utf8Sample {
+		t.Errorf("A byte at a time: got %q", got)
+	}
+}
+
+func TestDecodeUTF8Invalid(t *testing.T) {
+	for _, tc := range []struct {
+		reads []string
+		want  string
+	}{
+		{[]string{"a\xffb"}, "a�b"},
+		{[]string{"caf\xe9 au lait"}, "caf� au lait"},
+		{[]string{"\xe6\x97", "x"}, "�x"},
+		{[]string{"\xe6", "\x97", "\xa5"}, "日"},
+		{[]string{"\xc0\xaf"}, "�"},
+		{[]string{"held back \xe6\x97"}, "held back �"},
+	} {
+		reads := [][]byte{}
+		for _, read := range tc.reads {
+			reads = append(reads, []byte(read))
+		}
+		if got := decodeReads(reads...); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.reads, got, tc.want)
+		}
+	}
+}
+
+func TestHeadlessUTF8Splits(t *testing.T) {
+	c, _ := startHeadless(t)
+	server := attachServer(t, c)
+	data := []byte(utf8Sample)
+	for i := 1; i < len(data); i++ {
+		line := fmt.Sprintf("%v %v", i, utf8Sample)
+		split := len(line) - len(data) + i
+		if _, err := server.conn.Write([]byte(line[:split])); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := server.conn.Write([]byte(line[split:] + "\r\n")); err != nil {
+			t.Fatal(err)
+		}
+		waitOutput(t, c, line)
+	}
+}
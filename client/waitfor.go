@@ -0,0 +1,116 @@
+package client
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+// wait is a one-shot trigger registered by waitFor, in the session it was registered in.
+type wait struct {
+	id       int
+	pattern  *regexp.Regexp
+	callback otto.Value
+	timer    *time.Timer
+	session  *session
+	owner    string
+}
+
+// removeWait forgets w and returns whether it was still pending. It must run in script context.
+func (self *Client) removeWait(w *wait) bool {
+	for i, pending := range self.waits {
+		if pending == w {
+			self.waits = append(self.waits[:i:i], self.waits[i+1:]...)
+			if w.timer != nil {
+				w.timer.Stop()
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// runWaits consumes the waits of the context session matching line.
+func (self *Client) runWaits(line string) {
+	for _, w := range append([]*wait{}, self.waits...) {
+		if w.session != self.target() {
+			continue
+		}
+		match := w.pattern.FindStringSubmatch(line)
+		if match == nil || !self.removeWait(w) {
+			continue
+		}
+		if _, err := self.callScript(w.callback, line, self.groups(w.pattern, match)); err != nil {
+			self.reportError(fmt.Sprintf("wait %v (%v)%v", w.id, w.pattern, registeredIn(w.owner)), err)
+		}
+	}
+}
+
+// failWait calls the callback of w with an error if it is still pending. It must run in script context.
+func (self *Client) failWait(w *wait, reason error) {
+	if !self.removeWait(w) {
+		return
+	}
+	errValue, _ := otto.ToValue(reason)
+	self.within(w.session, func() {
+		if _, err := self.callScript(w.callback, otto.NullValue(), otto.NullValue(), errValue); err != nil {
+			self.reportError(fmt.Sprintf("wait %v (%v)%v", w.id, w.pattern, registeredIn(w.owner)), err)
+		}
+	})
+}
+
+// cancelWaits fails the pending waits of the session, because its connection is gone.
+func (self *session) cancelWaits() {
+	self.client.schedule(func() {
+		for _, w := range append([]*wait{}, self.client.waits...) {
+			if w.session == self {
+				self.client.failWait(w, fmt.Errorf("Disconnected while waiting for %v", w.pattern))
+			}
+		}
+	})
+}
+
+func (self *Client) bindWaitFor() {
+	self.bind("waitFor(pattern, fn, timeoutMs)", "Call fn(line, groups) once for the next received line matching pattern, or fn(null, null, error) if none arrives within timeoutMs or the connection closes, and return the wait id. This doesn't block: a script continues right away, and what should happen after the line arrives goes in fn.", func(call otto.FunctionCall) (result otto.Value) {
+		pattern, err := regexp.Compile(call.Argument(0).String())
+		if err != nil {
+			result, _ = otto.ToValue(fmt.Errorf("Invalid wait pattern %#v: %v", call.Argument(0).String(), err))
+			return
+		}
+		if !call.Argument(1).IsFunction() {
+			result, _ = otto.ToValue(fmt.Errorf("Wait callback is not a function"))
+			return
+		}
+		self.nextWaitId++
+		w := &wait{
+			id:       self.nextWaitId,
+			pattern:  pattern,
+			callback: call.Argument(1),
+			session:  self.target(),
+			owner:    self.currentOwner(),
+		}
+		if ms, _ := call.Argument(2).ToInteger(); ms > 0 {
+			timeout := time.Duration(ms) * time.Millisecond
+			w.timer = time.AfterFunc(timeout, func() {
+				self.schedule(func() {
+					self.failWait(w, fmt.Errorf("Timed out after %v waiting for %v", timeout, pattern))
+				})
+			})
+		}
+		self.waits = append(self.waits, w)
+		result, _ = otto.ToValue(w.id)
+		return
+	})
+	self.bind("cancelWait(id)", "Forget a wait without calling its callback.", func(call otto.FunctionCall) (result otto.Value) {
+		id, _ := call.Argument(0).ToInteger()
+		for _, w := range self.waits {
+			if w.id == int(id) {
+				self.removeWait(w)
+				break
+			}
+		}
+		return
+	})
+}
@@ -0,0 +1,281 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+	"github.com/zond/gocui"
+)
+
+const (
+	windowTop           = "top"
+	windowRight         = "right"
+	defaultWindowHeight = 6
+	defaultWindowWidth  = 30
+	// minOutputColumns is how many columns the main output keeps however many windows are open at its right.
+	minOutputColumns = 20
+)
+
+// scriptWindow is a view scripts create and write to, carved from the top or the right of the output area. Its fields are guarded by
+// the client lock, and its view is only touched in layout, so scripts never touch the gui.
+type scriptWindow struct {
+	name     string
+	title    string
+	position string
+	// size is the rows of top windows and the columns of right windows, frame excluded.
+	size  int
+	lines []string
+	// scroll is how many lines from the bottom the window is scrolled up, and rows how many it showed at the last layout.
+	scroll int
+	rows   int
+	owner  string
+}
+
+func (self *scriptWindow) viewName() string {
+	return "window-" + self.name
+}
+
+// findWindow returns the script window called name, or nil. It must be called with the client lock held.
+func (self *Client) findWindow(name string) *scriptWindow {
+	for _, w := range self.windows {
+		if w.name == name {
+			return w
+		}
+	}
+	return nil
+}
+
+// layoutWindows places the script windows that fit, top ones stacked down from top and right ones side by side from the right edge
+// down to bottom, deletes the views of closed ones, and returns the row and column the output area is left between.
+func (self *Client) layoutWindows(g *gocui.Gui, maxX, top, bottom int) (outputTop, outputRight int, err error) {
+	self.lock.RLock()
+	windows := append([]*scriptWindow{}, self.windows...)
+	self.lock.RUnlock()
+	outputTop, outputRight = top, maxX-1
+	shown := map[string]bool{}
+	place := func(w *scriptWindow, x0, y0, x1, y1 int) error {
+		v, err := g.SetView(w.viewName(), x0, y0, x1, y1)
+		if err != nil && err != gocui.ErrorUnkView {
+			return err
+		}
+		v.Title = w.title
+		if w.name == self.windowFocus {
+			v.Title = fmt.Sprintf("[%v]", w.title)
+		}
+		self.renderWindow(v, w)
+		shown[w.viewName()] = true
+		return nil
+	}
+	for _, w := range windows {
+		if w.position != windowTop {
+			continue
+		}
+		windowBottom := outputTop + w.size + 1
+		if bottom-windowBottom-1 < minOutputRows {
+			break
+		}
+		if err = place(w, 0, outputTop, maxX-1, windowBottom); err != nil {
+			return
+		}
+		outputTop = windowBottom + 1
+	}
+	for _, w := range windows {
+		if w.position != windowRight {
+			continue
+		}
+		left := outputRight - w.size - 1
+		if left < minOutputColumns {
+			break
+		}
+		if err = place(w, left, outputTop, outputRight, bottom); err != nil {
+			return
+		}
+		outputRight = left - 1
+	}
+	for name := range self.windowViews {
+		if !shown[name] {
+			if err = g.DeleteView(name); err != nil {
+				return
+			}
+		}
+	}
+	self.windowViews = shown
+	if !shown["window-"+self.windowFocus] {
+		self.windowFocus = ""
+	}
+	return
+}
+
+// renderWindow shows the lines of w that fit, scroll lines up from the bottom.
+func (self *Client) renderWindow(v *gocui.View, w *scriptWindow) {
+	width, height := v.Size()
+	result := []string{}
+	self.lock.Lock()
+	if w.scroll > len(w.lines)-1 {
+		w.scroll = len(w.lines) - 1
+	}
+	if w.scroll < 0 {
+		w.scroll = 0
+	}
+	w.rows = height
+	for i := len(w.lines) - 1 - w.scroll; i >= 0 && len(result) < height; i-- {
+		result = append(wrapLine(w.lines[i], width, self.wrapIndent), result...)
+	}
+	self.lock.Unlock()
+	if len(result) > height {
+		result = result[len(result)-height:]
+	}
+	v.Clear()
+	fmt.Fprint(v, strings.Join(result, "\n"))
+}
+
+// nextWindow moves the focus, which PgUp and PgDn scroll, from the output through the shown script windows and back.
+func (self *Client) nextWindow(g *gocui.Gui, v *gocui.View) error {
+	names := []string{""}
+	self.lock.RLock()
+	for _, w := range self.windows {
+		if self.windowViews[w.viewName()] {
+			names = append(names, w.name)
+		}
+	}
+	self.lock.RUnlock()
+	for i, name := range names {
+		if name == self.windowFocus {
+			self.windowFocus = names[(i+1)%len(names)]
+			return nil
+		}
+	}
+	self.windowFocus = ""
+	return nil
+}
+
+// windowScroller runs handler unless a script window has the focus, in which case it scrolls that window pages pages up.
+func (self *Client) windowScroller(handler gocui.KeybindingHandler, pages int) gocui.KeybindingHandler {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if self.windowFocus == "" {
+			return handler(g, v)
+		}
+		self.lock.Lock()
+		defer self.lock.Unlock()
+		if w := self.findWindow(self.windowFocus); w != nil {
+			step := w.rows - 1
+			if step < 1 {
+				step = 1
+			}
+			w.scroll += pages * step
+		}
+		return nil
+	}
+}
+
+func (self *Client) bindWindows() {
+	obj, _ := self.ot.Object("({})")
+	self.bindMethod(obj, "window", "create(name, options)", "Create a window for scripts to write to. Options are {height: rows} for windows at the top, {position: \"right\", width: columns} for windows at the right of the output, and {title}. Ctrl-O focuses a window for PgUp and PgDn to scroll.", func(call otto.FunctionCall) (result otto.Value) {
+		name := call.Argument(0).String()
+		if name == "" {
+			result, _ = otto.ToValue(fmt.Errorf("No window name given"))
+			return
+		}
+		w := &scriptWindow{
+			name:     name,
+			title:    name,
+			position: windowTop,
+			size:     defaultWindowHeight,
+			owner:    self.currentOwner(),
+		}
+		if opts := call.Argument(1); opts.IsObject() {
+			if v, _ := opts.Object().Get("position"); v.IsDefined() {
+				if w.position = v.String(); w.position != windowTop && w.position != windowRight {
+					result, _ = otto.ToValue(fmt.Errorf("Invalid window position %#v, use %#v or %#v", w.position, windowTop, windowRight))
+					return
+				}
+			}
+			key := "height"
+			if w.position == windowRight {
+				key, w.size = "width", defaultWindowWidth
+			}
+			if v, _ := opts.Object().Get(key); v.IsDefined() {
+				size, err := v.ToInteger()
+				if err != nil || size < 1 {
+					result, _ = otto.ToValue(fmt.Errorf("Invalid window %v %#v", key, v.String()))
+					return
+				}
+				w.size = int(size)
+			}
+			if v, _ := opts.Object().Get("title"); v.IsDefined() {
+				w.title = v.String()
+			}
+		}
+		self.lock.Lock()
+		defer self.lock.Unlock()
+		if self.findWindow(name) != nil {
+			result, _ = otto.ToValue(fmt.Errorf("Window %#v already exists", name))
+			return
+		}
+		self.windows = append(self.windows, w)
+		self.redraw()
+		return
+	})
+	self.bindMethod(obj, "window", "write(name, text)", "Add text as lines at the bottom of a window.", func(call otto.FunctionCall) (result otto.Value) {
+		self.lock.Lock()
+		defer self.lock.Unlock()
+		w := self.findWindow(call.Argument(0).String())
+		if w == nil {
+			result, _ = otto.ToValue(fmt.Errorf("No window named %#v", call.Argument(0).String()))
+			return
+		}
+		added := strings.Split(strings.TrimSuffix(call.Argument(1).String(), "\n"), "\n")
+		w.lines = append(w.lines, added...)
+		if w.scroll > 0 {
+			w.scroll += len(added)
+		}
+		if over := len(w.lines) - self.config.scrollback; over > 0 {
+			w.lines = append([]string{}, w.lines[over:]...)
+		}
+		self.redraw()
+		return
+	})
+	self.bindMethod(obj, "window", "clear(name)", "Remove all lines from a window.", func(call otto.FunctionCall) (result otto.Value) {
+		self.lock.Lock()
+		defer self.lock.Unlock()
+		w := self.findWindow(call.Argument(0).String())
+		if w == nil {
+			result, _ = otto.ToValue(fmt.Errorf("No window named %#v", call.Argument(0).String()))
+			return
+		}
+		w.lines, w.scroll = nil, 0
+		self.redraw()
+		return
+	})
+	self.bindMethod(obj, "window", "close(name)", "Close a window and return whether there was one.", func(call otto.FunctionCall) (result otto.Value) {
+		self.lock.Lock()
+		defer self.lock.Unlock()
+		for i, w := range self.windows {
+			if w.name == call.Argument(0).String() {
+				self.windows = append(self.windows[:i:i], self.windows[i+1:]...)
+				self.redraw()
+				result, _ = otto.ToValue(true)
+				return
+			}
+		}
+		result, _ = otto.ToValue(false)
+		return
+	})
+	self.bindMethod(obj, "window", "list()", "List the windows with their {name, title, position, size, lines}.", func(call otto.FunctionCall) (result otto.Value) {
+		self.lock.RLock()
+		items := []map[string]interface{}{}
+		for _, w := range self.windows {
+			items = append(items, map[string]interface{}{
+				"name":     w.name,
+				"title":    w.title,
+				"position": w.position,
+				"size":     w.size,
+				"lines":    len(w.lines),
+			})
+		}
+		self.lock.RUnlock()
+		return self.jsArray(items)
+	})
+	self.ot.Set("window", obj)
+}
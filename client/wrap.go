@@ -0,0 +1,100 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/robertkrimen/otto"
+)
+
+const defaultWrapIndent = 2
+
+func closeRow(row []byte, sgr string) string {
+	if sgr != "" {
+		return string(row) + ansiReset
+	}
+	return string(row)
+}
+
+// wrapLine breaks line into rows of at most width columns at spaces, hard breaking words wider than a row. Continuation rows
+// are indented and start in the color state the previous row ended in.
+func wrapLine(line string, width, indent int) (rows []string) {
+	if width < 1 {
+		width = 1
+	}
+	if indent >= width {
+		indent = width - 1
+	}
+	pad := strings.Repeat(" ", indent)
+	row := []byte{}
+	rowStart, rowWidth := 0, 0
+	sgr := ""
+	breakAt, breakWidth, breakSGR := -1, 0, ""
+	newRow := func(state string) {
+		row = append([]byte(pad), state...)
+		rowStart, rowWidth = len(row), indent
+		breakAt = -1
+	}
+	for i := 0; i < len(line); {
+		if line[i] == '\033' {
+			if loc := ansiPattern.FindStringIndex(line[i:]); loc != nil && loc[0] == 0 {
+				escape := line[i : i+loc[1]]
+				row = append(row, escape...)
+				if isSGR(escape) {
+					if isReset(escape) {
+						sgr = ""
+					} else {
+						sgr += escape
+					}
+				}
+				i += loc[1]
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(line[i:])
+		w := runeWidth(r)
+		if rowWidth+w > width {
+			if r == ' ' {
+				rows = append(rows, closeRow(row, sgr))
+				newRow(sgr)
+				i += size
+				continue
+			}
+			if restWidth := rowWidth - breakWidth - 1; breakAt > rowStart && indent+restWidth+w <= width {
+				rest := append([]byte{}, row[breakAt+1:]...)
+				rows = append(rows, closeRow(row[:breakAt], breakSGR))
+				newRow(breakSGR)
+				row = append(row, rest...)
+				rowWidth += restWidth
+			} else {
+				rows = append(rows, closeRow(row, sgr))
+				newRow(sgr)
+			}
+		}
+		if r == ' ' {
+			breakAt, breakWidth, breakSGR = len(row), rowWidth, sgr
+		}
+		row = append(row, line[i:i+size]...)
+		rowWidth += w
+		i += size
+	}
+	return append(rows, string(row))
+}
+
+func (self *Client) bindWrap() {
+	self.bind("wrapIndent(columns)", "Get or set how far continuation rows of wrapped lines are indented.", func(call otto.FunctionCall) (result otto.Value) {
+		self.lock.Lock()
+		defer self.lock.Unlock()
+		if arg := call.Argument(0); arg.IsDefined() {
+			columns, err := arg.ToInteger()
+			if err != nil || columns < 0 {
+				result, _ = otto.ToValue(fmt.Errorf("Invalid indent %#v", arg.String()))
+				return
+			}
+			self.wrapIndent = int(columns)
+		}
+		result, _ = otto.ToValue(self.wrapIndent)
+		return
+	})
+}
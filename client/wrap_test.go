@@ -0,0 +1,54 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWrapLine(t *testing.T) {
+	for _, tc := range []struct {
+		line  string
+		width int
+		want  []string
+	}{
+		{"", 20, []string{""}},
+		{"The rat bites you.", 1, []string{"T", "h", "e", "r", "a", "t", "b", "i", "t", "e", "s", "y", "o", "u", "."}},
+		{"\033[31mred\033[0m ok", 1, []string{"\033[31mr\033[0m", "\033[31me\033[0m", "\033[31md\033[0m", "o", "k"}},
+		{"The quick brown fox jumps over the lazy dog.", 20, []string{"The quick brown fox", "  jumps over the", "  lazy dog."}},
+		{"\033[1;31mThe quick brown fox\033[0m jumps over the lazy dog.", 20, []string{"\033[1;31mThe quick brown fox\033[0m", "  jumps over the", "  lazy dog."}},
+		{"A \033[32mgreen goblin attacks you\033[0m fiercely.", 20, []string{"A \033[32mgreen goblin\033[0m", "  \033[32mattacks you\033[0m", "  fiercely."}},
+		{"Supercalifragilisticexpialidocious!", 20, []string{"Supercalifragilistic", "  expialidocious!"}},
+		{"日本語のテキストを折り返すテストです", 20, []string{"日本語のテキストを折", "  り返すテストです"}},
+		{"The quick brown fox jumps over the lazy dog.", 200, []string{"The quick brown fox jumps over the lazy dog."}},
+		{"\033[32mgreen\033[0m", 200, []string{"\033[32mgreen\033[0m"}},
+	} {
+		got := wrapLine(tc.line, tc.width, 2)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("wrapLine(%q, %v) = %#v, want %#v", tc.line, tc.width, got, tc.want)
+		}
+		for _, row := range got {
+			if width := stringWidth(stripANSI(row)); width > tc.width && tc.width > 1 {
+				t.Errorf("wrapLine(%q, %v) has the %v columns wide row %q", tc.line, tc.width, width, row)
+			}
+		}
+	}
+}
+
+func TestReflowKeepsScroll(t *testing.T) {
+	c := New()
+	s := c.activeSession()
+	s.outputLines([]string{
+		"The quick brown fox jumps over the lazy dog.",
+		"The rat bites you.",
+		"You hit the rat.",
+		"The rat dies.",
+	})
+	// Scrolled up past one line, the bottom row shows the end of the line before it whatever the width.
+	for _, width := range []int{1, 20, 200} {
+		rows := s.visibleOutput(1, width, 3, nil)
+		wrapped := wrapLine("You hit the rat.", width, 2)
+		if len(rows) != 3 || rows[2] != wrapped[len(wrapped)-1] {
+			t.Errorf("At width %v the rows are %q, want them to end with %q", width, rows, wrapped[len(wrapped)-1])
+		}
+	}
+}
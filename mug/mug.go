@@ -1,11 +1,44 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"os"
+
 	client "github.com/zond/mug/client"
 )
 
 func main() {
-	m := client.New()
+	script := flag.String("script", "", "Run this script at startup instead of ~/.mug.js.")
+	logFile := flag.String("log", "", "Log the session to this file.")
+	scrollback := flag.Int("scrollback", 0, "Keep this many output lines for scrolling and searching.")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %v [flags] [host:port | telnet://host:port | tls://host:port]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	// Flags may come after the host as well as before it.
+	host := ""
+	args := os.Args[1:]
+	for {
+		flag.CommandLine.Parse(args)
+		if args = flag.Args(); len(args) == 0 {
+			break
+		}
+		if host != "" {
+			flag.Usage()
+			os.Exit(2)
+		}
+		host, args = args[0], args[1:]
+	}
+	opts := []client.Option{
+		client.WithHost(host),
+		client.WithLogFile(*logFile),
+		client.WithScrollback(*scrollback),
+	}
+	if *script != "" {
+		opts = append(opts, client.WithStartupScript(*script))
+	}
+	m := client.New(opts...)
 	defer m.Close()
 	m.Run()
 }